@@ -0,0 +1,92 @@
+// Package builder builds parameterized SELECT, INSERT, UPDATE and DELETE statements
+// with the placeholders and pagination of the database dialect.
+//
+// The values are always bound as arguments, the table and column names are written as they are
+// and must not come from user input.
+package builder
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+
+	"github.com/lengocson131002/go-clean-core/database"
+)
+
+var (
+	ErrInvalidStatement = errors.New("invalid statement")
+	// ErrNoCondition prevents UPDATE and DELETE statements from changing all the rows by mistake
+	ErrNoCondition = errors.New("statement has no WHERE condition")
+)
+
+// Statement is a statement rendered for a dialect
+type Statement interface {
+	Build() (string, []interface{}, error)
+}
+
+// Builder starts the statements of a dialect
+type Builder struct {
+	dialect Dialect
+}
+
+func New(dialect Dialect) *Builder {
+	return &Builder{dialect: dialect}
+}
+
+func (b *Builder) Dialect() Dialect {
+	return b.dialect
+}
+
+// Select starts a SELECT of the columns, all the columns when none
+func (b *Builder) Select(columns ...string) *SelectBuilder {
+	return &SelectBuilder{dialect: b.dialect, columns: columns}
+}
+
+func (b *Builder) Insert(table string) *InsertBuilder {
+	return &InsertBuilder{dialect: b.dialect, table: table}
+}
+
+func (b *Builder) Update(table string) *UpdateBuilder {
+	return &UpdateBuilder{dialect: b.dialect, table: table}
+}
+
+func (b *Builder) Delete(table string) *DeleteBuilder {
+	return &DeleteBuilder{dialect: b.dialect, table: table}
+}
+
+// Exec runs the statement on the database
+func Exec(ctx context.Context, g *database.Gdbc, stmt Statement) (sql.Result, error) {
+	query, args, err := stmt.Build()
+	if err != nil {
+		return nil, err
+	}
+	return g.Exec(ctx, query, args...)
+}
+
+// QueryOne returns the row of the statement scanned into a T, see database.QueryOne
+func QueryOne[T any](ctx context.Context, g *database.Gdbc, stmt Statement) (T, error) {
+	query, args, err := stmt.Build()
+	if err != nil {
+		var zero T
+		return zero, err
+	}
+	return database.QueryOne[T](ctx, g, query, args...)
+}
+
+// QueryAll returns the rows of the statement scanned into Ts, see database.QueryAll
+func QueryAll[T any](ctx context.Context, g *database.Gdbc, stmt Statement) ([]T, error) {
+	query, args, err := stmt.Build()
+	if err != nil {
+		return nil, err
+	}
+	return database.QueryAll[T](ctx, g, query, args...)
+}
+
+// renderWhere writes the WHERE clause of the conditions joined with AND
+func renderWhere(r *renderer, keyword string, conds []Condition) {
+	if len(conds) == 0 {
+		return
+	}
+	r.write(" " + keyword + " ")
+	And(conds...).render(r)
+}
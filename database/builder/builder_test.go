@@ -0,0 +1,113 @@
+package builder
+
+import (
+	"context"
+	"testing"
+
+	"github.com/lengocson131002/go-clean-core/database/sqlx/sqlxtest"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSelectDialects(t *testing.T) {
+	build := func(d Dialect) *SelectBuilder {
+		return New(d).Select("u.id", "u.name").
+			From("users u").
+			LeftJoin("orders o", Expr("o.user_id = u.id AND o.status = ?", "paid")).
+			Where(Eq("u.active", true), Or(Like("u.name", "a%"), In("u.role", []string{"admin", "owner"}))).
+			OrderBy("u.name").
+			Page(3, 20)
+	}
+
+	tests := map[Dialect]string{
+		Postgres:  "SELECT u.id, u.name FROM users u LEFT JOIN orders o ON o.user_id = u.id AND o.status = $1 WHERE (u.active = $2 AND (u.name LIKE $3 OR u.role IN ($4, $5))) ORDER BY u.name LIMIT $6 OFFSET $7",
+		MySQL:     "SELECT u.id, u.name FROM users u LEFT JOIN orders o ON o.user_id = u.id AND o.status = ? WHERE (u.active = ? AND (u.name LIKE ? OR u.role IN (?, ?))) ORDER BY u.name LIMIT ? OFFSET ?",
+		Oracle:    "SELECT u.id, u.name FROM users u LEFT JOIN orders o ON o.user_id = u.id AND o.status = :1 WHERE (u.active = :2 AND (u.name LIKE :3 OR u.role IN (:4, :5))) ORDER BY u.name OFFSET :6 ROWS FETCH NEXT :7 ROWS ONLY",
+		SQLServer: "SELECT u.id, u.name FROM users u LEFT JOIN orders o ON o.user_id = u.id AND o.status = @p1 WHERE (u.active = @p2 AND (u.name LIKE @p3 OR u.role IN (@p4, @p5))) ORDER BY u.name OFFSET @p6 ROWS FETCH NEXT @p7 ROWS ONLY",
+	}
+
+	for d, expected := range tests {
+		query, args, err := build(d).Build()
+		require.NoError(t, err)
+		assert.Equal(t, expected, query, d.Name())
+
+		if d == Oracle || d == SQLServer {
+			assert.Equal(t, []interface{}{"paid", true, "a%", "admin", "owner", uint64(40), uint64(20)}, args)
+		} else {
+			assert.Equal(t, []interface{}{"paid", true, "a%", "admin", "owner", uint64(20), uint64(40)}, args)
+		}
+	}
+}
+
+func TestPaginationWithoutLimitOrOrder(t *testing.T) {
+	query, _, err := New(SQLServer).Select().From("users").Offset(10).Build()
+	require.NoError(t, err)
+	assert.Equal(t, "SELECT * FROM users ORDER BY (SELECT NULL) OFFSET @p1 ROWS", query)
+
+	query, _, err = New(SQLite).Select().From("users").Offset(10).Build()
+	require.NoError(t, err)
+	assert.Equal(t, "SELECT * FROM users LIMIT -1 OFFSET ?", query)
+}
+
+func TestCount(t *testing.T) {
+	b := New(Postgres).Select("name").From("users").Where(Gt("age", 18)).OrderBy("name").Limit(10)
+	query, args, err := b.Count().Build()
+	require.NoError(t, err)
+	assert.Equal(t, "SELECT COUNT(*) FROM users WHERE age > $1", query)
+	assert.Equal(t, []interface{}{18}, args)
+
+	query, _, err = New(Postgres).Select("city").From("users").Where(Gt("age", 18)).GroupBy("city").Count().Build()
+	require.NoError(t, err)
+	assert.Equal(t, "SELECT COUNT(*) FROM (SELECT city FROM users WHERE age > $1 GROUP BY city) counted", query)
+}
+
+func TestMutations(t *testing.T) {
+	b := New(Postgres)
+
+	query, args, err := b.Insert("users").Columns("name", "age").Values("alice", 30).Values("bob", 25).Build()
+	require.NoError(t, err)
+	assert.Equal(t, "INSERT INTO users (name, age) VALUES ($1, $2), ($3, $4)", query)
+	assert.Equal(t, []interface{}{"alice", 30, "bob", 25}, args)
+
+	query, args, err = b.Update("users").Set("visits", Expr("visits + ?", 1)).SetMap(map[string]interface{}{"name": "carol"}).
+		Where(Eq("id", 7), Eq("deleted_at", nil)).Build()
+	require.NoError(t, err)
+	assert.Equal(t, "UPDATE users SET visits = visits + $1, name = $2 WHERE (id = $3 AND deleted_at IS NULL)", query)
+	assert.Equal(t, []interface{}{1, "carol", 7}, args)
+
+	query, _, err = b.Delete("users").Where(NotIn("id")).Build()
+	require.NoError(t, err)
+	assert.Equal(t, "DELETE FROM users WHERE 1 = 1", query)
+
+	_, _, err = b.Delete("users").Build()
+	assert.ErrorIs(t, err, ErrNoCondition)
+
+	_, _, err = b.Insert("users").Columns("name", "age").Values("alice").Build()
+	assert.ErrorIs(t, err, ErrInvalidStatement)
+
+	_, _, err = b.Select().From("users").Where(Expr("a = ? AND b = ?", 1)).Build()
+	assert.ErrorIs(t, err, ErrInvalidStatement)
+}
+
+func TestExecute(t *testing.T) {
+	g := sqlxtest.Open(t, `CREATE TABLE users (id INTEGER PRIMARY KEY, name TEXT, age INTEGER)`)
+	ctx := context.Background()
+
+	d, ok := DialectOf(sqlxtest.DriverName)
+	require.True(t, ok)
+	b := New(d)
+
+	_, err := Exec(ctx, g, b.Insert("users").Columns("id", "name", "age").Values(1, "alice", 30).Values(2, "bob", 17).Values(3, "carol", 45))
+	require.NoError(t, err)
+
+	_, err = Exec(ctx, g, b.Update("users").Set("age", Expr("age + ?", 1)).Where(Eq("id", 2)))
+	require.NoError(t, err)
+
+	adults, err := QueryAll[string](ctx, g, b.Select("name").From("users").Where(Gte("age", 18)).OrderBy("age DESC"))
+	require.NoError(t, err)
+	assert.Equal(t, []string{"carol", "alice", "bob"}, adults)
+
+	count, err := QueryOne[int](ctx, g, b.Select().From("users").Where(Between("age", 20, 40)).Count())
+	require.NoError(t, err)
+	assert.Equal(t, 1, count)
+}
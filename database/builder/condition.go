@@ -0,0 +1,244 @@
+package builder
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+)
+
+// Condition is a boolean expression of a WHERE or HAVING clause
+type Condition interface {
+	render(r *renderer)
+}
+
+type exprCondition struct {
+	sql  string
+	args []interface{}
+}
+
+// Expr is a raw condition, its ? placeholders are bound to the arguments, e.g. Expr("age BETWEEN ? AND ?", 18, 65)
+func Expr(sql string, args ...interface{}) Condition {
+	return &exprCondition{sql: sql, args: args}
+}
+
+func (c *exprCondition) render(r *renderer) {
+	r.writeExpr(c.sql, c.args)
+}
+
+type compareCondition struct {
+	column string
+	op     string
+	value  interface{}
+}
+
+func (c *compareCondition) render(r *renderer) {
+	r.write(c.column)
+	r.write(" ")
+	r.write(c.op)
+	r.write(" ")
+	r.write(r.bind(c.value))
+}
+
+func Eq(column string, value interface{}) Condition {
+	if value == nil {
+		return IsNull(column)
+	}
+	return &compareCondition{column: column, op: "=", value: value}
+}
+
+func Ne(column string, value interface{}) Condition {
+	if value == nil {
+		return NotNull(column)
+	}
+	return &compareCondition{column: column, op: "<>", value: value}
+}
+
+func Gt(column string, value interface{}) Condition {
+	return &compareCondition{column: column, op: ">", value: value}
+}
+
+func Gte(column string, value interface{}) Condition {
+	return &compareCondition{column: column, op: ">=", value: value}
+}
+
+func Lt(column string, value interface{}) Condition {
+	return &compareCondition{column: column, op: "<", value: value}
+}
+
+func Lte(column string, value interface{}) Condition {
+	return &compareCondition{column: column, op: "<=", value: value}
+}
+
+func Like(column string, pattern string) Condition {
+	return &compareCondition{column: column, op: "LIKE", value: pattern}
+}
+
+func IsNull(column string) Condition {
+	return Expr(column + " IS NULL")
+}
+
+func NotNull(column string) Condition {
+	return Expr(column + " IS NOT NULL")
+}
+
+func Between(column string, from interface{}, to interface{}) Condition {
+	return Expr(column+" BETWEEN ? AND ?", from, to)
+}
+
+type inCondition struct {
+	column string
+	not    bool
+	values []interface{}
+}
+
+// In matches the column to the values, a single slice argument is expanded to its elements.
+// In without values is false.
+func In(column string, values ...interface{}) Condition {
+	return &inCondition{column: column, values: expand(values)}
+}
+
+// NotIn is the negation of In, NotIn without values is true
+func NotIn(column string, values ...interface{}) Condition {
+	return &inCondition{column: column, not: true, values: expand(values)}
+}
+
+func (c *inCondition) render(r *renderer) {
+	if len(c.values) == 0 {
+		if c.not {
+			r.write("1 = 1")
+		} else {
+			r.write("1 = 0")
+		}
+		return
+	}
+
+	r.write(c.column)
+	if c.not {
+		r.write(" NOT")
+	}
+	r.write(" IN (")
+	for i, v := range c.values {
+		if i > 0 {
+			r.write(", ")
+		}
+		r.write(r.bind(v))
+	}
+	r.write(")")
+}
+
+// expand returns the elements of a single slice argument, []byte is a value
+func expand(values []interface{}) []interface{} {
+	if len(values) != 1 {
+		return values
+	}
+	v := reflect.ValueOf(values[0])
+	if v.Kind() != reflect.Slice || v.Type().Elem().Kind() == reflect.Uint8 {
+		return values
+	}
+
+	expanded := make([]interface{}, v.Len())
+	for i := range expanded {
+		expanded[i] = v.Index(i).Interface()
+	}
+	return expanded
+}
+
+type junction struct {
+	op    string
+	conds []Condition
+}
+
+// And is true when all the conditions are, And without conditions is true
+func And(conds ...Condition) Condition {
+	return &junction{op: "AND", conds: conds}
+}
+
+// Or is true when one of the conditions is, Or without conditions is false
+func Or(conds ...Condition) Condition {
+	return &junction{op: "OR", conds: conds}
+}
+
+func (j *junction) render(r *renderer) {
+	switch len(j.conds) {
+	case 0:
+		if j.op == "AND" {
+			r.write("1 = 1")
+		} else {
+			r.write("1 = 0")
+		}
+		return
+	case 1:
+		j.conds[0].render(r)
+		return
+	}
+
+	r.write("(")
+	for i, c := range j.conds {
+		if i > 0 {
+			r.write(" " + j.op + " ")
+		}
+		c.render(r)
+	}
+	r.write(")")
+}
+
+type notCondition struct {
+	cond Condition
+}
+
+func Not(cond Condition) Condition {
+	return &notCondition{cond: cond}
+}
+
+func (c *notCondition) render(r *renderer) {
+	r.write("NOT (")
+	c.cond.render(r)
+	r.write(")")
+}
+
+// renderer writes the statement and binds its arguments to the placeholders of the dialect
+type renderer struct {
+	dialect Dialect
+	sb      strings.Builder
+	args    []interface{}
+	err     error
+}
+
+func (r *renderer) write(s string) {
+	r.sb.WriteString(s)
+}
+
+func (r *renderer) bind(v interface{}) string {
+	r.args = append(r.args, v)
+	return r.dialect.Placeholder(len(r.args))
+}
+
+// writeExpr writes the expression with its ? placeholders bound to the arguments, ?? is a literal ?
+func (r *renderer) writeExpr(sql string, args []interface{}) {
+	for i := 0; i < len(sql); i++ {
+		if sql[i] != '?' {
+			r.sb.WriteByte(sql[i])
+			continue
+		}
+		if i+1 < len(sql) && sql[i+1] == '?' {
+			r.sb.WriteByte('?')
+			i++
+			continue
+		}
+		if len(args) == 0 {
+			r.fail(fmt.Errorf("%w: %q has more placeholders than arguments", ErrInvalidStatement, sql))
+			return
+		}
+		r.write(r.bind(args[0]))
+		args = args[1:]
+	}
+	if len(args) > 0 {
+		r.fail(fmt.Errorf("%w: %q has more arguments than placeholders", ErrInvalidStatement, sql))
+	}
+}
+
+func (r *renderer) fail(err error) {
+	if r.err == nil {
+		r.err = err
+	}
+}
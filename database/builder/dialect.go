@@ -0,0 +1,124 @@
+package builder
+
+import "strconv"
+
+// Dialect renders the parts of the statements which differ between databases
+type Dialect interface {
+	Name() string
+	// Placeholder returns the placeholder of the n-th argument, starting from 1
+	Placeholder(n int) string
+	// Paginate returns the clause skipping offset rows and limiting the rows to limit, nil when not set.
+	// bind adds an argument and returns its placeholder, ordered tells whether the statement has an ORDER BY.
+	Paginate(limit *uint64, offset *uint64, ordered bool, bind func(interface{}) string) string
+}
+
+var (
+	// Postgres uses $1 placeholders
+	Postgres Dialect = &limitOffsetDialect{name: "postgres", placeholder: numbered("$")}
+	// MySQL uses ? placeholders
+	MySQL Dialect = &limitOffsetDialect{name: "mysql", placeholder: question, noLimit: "18446744073709551615"}
+	// SQLite uses ? placeholders
+	SQLite Dialect = &limitOffsetDialect{name: "sqlite", placeholder: question, noLimit: "-1"}
+	// Oracle uses :1 placeholders, pagination needs Oracle 12c
+	Oracle Dialect = &fetchDialect{name: "oracle", placeholder: numbered(":")}
+	// SQLServer uses @p1 placeholders, pagination needs SQL Server 2012
+	SQLServer Dialect = &fetchDialect{name: "sqlserver", placeholder: numbered("@p"), orderRequired: true}
+)
+
+// DialectOf returns the dialect of the database driver
+func DialectOf(driverName string) (Dialect, bool) {
+	switch driverName {
+	case "postgres", "pgx", "cockroach":
+		return Postgres, true
+	case "mysql":
+		return MySQL, true
+	case "sqlite", "sqlite3":
+		return SQLite, true
+	case "godror", "oracle", "oci8":
+		return Oracle, true
+	case "sqlserver", "mssql":
+		return SQLServer, true
+	}
+	return nil, false
+}
+
+func question(int) string {
+	return "?"
+}
+
+func numbered(prefix string) func(int) string {
+	return func(n int) string {
+		return prefix + strconv.Itoa(n)
+	}
+}
+
+// limitOffsetDialect paginates with LIMIT and OFFSET
+type limitOffsetDialect struct {
+	name        string
+	placeholder func(int) string
+	// noLimit is the limit of an offset without limit, when the database requires one
+	noLimit string
+}
+
+func (d *limitOffsetDialect) Name() string {
+	return d.name
+}
+
+func (d *limitOffsetDialect) Placeholder(n int) string {
+	return d.placeholder(n)
+}
+
+func (d *limitOffsetDialect) Paginate(limit *uint64, offset *uint64, ordered bool, bind func(interface{}) string) string {
+	clause := ""
+	if limit != nil {
+		clause = "LIMIT " + bind(*limit)
+	} else if offset != nil && len(d.noLimit) > 0 {
+		clause = "LIMIT " + d.noLimit
+	}
+
+	if offset != nil {
+		if len(clause) > 0 {
+			clause += " "
+		}
+		clause += "OFFSET " + bind(*offset)
+	}
+	return clause
+}
+
+// fetchDialect paginates with OFFSET ROWS and FETCH NEXT ROWS ONLY
+type fetchDialect struct {
+	name        string
+	placeholder func(int) string
+	// orderRequired adds an ORDER BY to the statements without one, OFFSET requires it
+	orderRequired bool
+}
+
+func (d *fetchDialect) Name() string {
+	return d.name
+}
+
+func (d *fetchDialect) Placeholder(n int) string {
+	return d.placeholder(n)
+}
+
+func (d *fetchDialect) Paginate(limit *uint64, offset *uint64, ordered bool, bind func(interface{}) string) string {
+	if limit == nil && offset == nil {
+		return ""
+	}
+
+	clause := ""
+	if !ordered && d.orderRequired {
+		clause = "ORDER BY (SELECT NULL) "
+	}
+
+	var skip interface{} = uint64(0)
+	if offset != nil {
+		skip = *offset
+	}
+	clause += "OFFSET " + bind(skip) + " ROWS"
+
+	if limit != nil {
+		clause += " FETCH NEXT " + bind(*limit) + " ROWS ONLY"
+	}
+	return clause
+}
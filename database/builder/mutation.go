@@ -0,0 +1,166 @@
+package builder
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// InsertBuilder builds an INSERT statement of one or more rows
+type InsertBuilder struct {
+	dialect Dialect
+	table   string
+	columns []string
+	rows    [][]interface{}
+}
+
+func (b *InsertBuilder) Columns(columns ...string) *InsertBuilder {
+	b.columns = append(b.columns, columns...)
+	return b
+}
+
+// Values adds a row with the values of the columns
+func (b *InsertBuilder) Values(values ...interface{}) *InsertBuilder {
+	b.rows = append(b.rows, values)
+	return b
+}
+
+// SetMap sets the columns and the values of a single row, the columns are sorted by name
+func (b *InsertBuilder) SetMap(values map[string]interface{}) *InsertBuilder {
+	columns := sortedKeys(values)
+	row := make([]interface{}, 0, len(columns))
+	for _, c := range columns {
+		row = append(row, values[c])
+	}
+	b.columns = columns
+	b.rows = [][]interface{}{row}
+	return b
+}
+
+// Build implements Statement.
+func (b *InsertBuilder) Build() (string, []interface{}, error) {
+	if len(b.columns) == 0 || len(b.rows) == 0 {
+		return "", nil, fmt.Errorf("%w: insert into %s has no values", ErrInvalidStatement, b.table)
+	}
+
+	r := &renderer{dialect: b.dialect}
+	r.write("INSERT INTO " + b.table + " (" + strings.Join(b.columns, ", ") + ") VALUES ")
+	for i, row := range b.rows {
+		if len(row) != len(b.columns) {
+			return "", nil, fmt.Errorf("%w: row %d has %d values for %d columns", ErrInvalidStatement, i, len(row), len(b.columns))
+		}
+		if i > 0 {
+			r.write(", ")
+		}
+		r.write("(")
+		for j, v := range row {
+			if j > 0 {
+				r.write(", ")
+			}
+			r.write(r.bind(v))
+		}
+		r.write(")")
+	}
+	return r.sb.String(), r.args, nil
+}
+
+type assignment struct {
+	column string
+	value  interface{}
+}
+
+// UpdateBuilder builds an UPDATE statement
+type UpdateBuilder struct {
+	dialect Dialect
+	table   string
+	sets    []assignment
+	where   []Condition
+}
+
+// Set sets the column to the value, an Expr value is written as an expression, e.g. Set("count", Expr("count + ?", 1))
+func (b *UpdateBuilder) Set(column string, value interface{}) *UpdateBuilder {
+	b.sets = append(b.sets, assignment{column: column, value: value})
+	return b
+}
+
+// SetMap sets the columns to the values, the columns are sorted by name
+func (b *UpdateBuilder) SetMap(values map[string]interface{}) *UpdateBuilder {
+	for _, c := range sortedKeys(values) {
+		b.Set(c, values[c])
+	}
+	return b
+}
+
+// Where adds conditions, the conditions of all the calls are joined with AND
+func (b *UpdateBuilder) Where(conds ...Condition) *UpdateBuilder {
+	b.where = append(b.where, conds...)
+	return b
+}
+
+// Build implements Statement.
+func (b *UpdateBuilder) Build() (string, []interface{}, error) {
+	if len(b.sets) == 0 {
+		return "", nil, fmt.Errorf("%w: update of %s sets no columns", ErrInvalidStatement, b.table)
+	}
+	if len(b.where) == 0 {
+		return "", nil, ErrNoCondition
+	}
+
+	r := &renderer{dialect: b.dialect}
+	r.write("UPDATE " + b.table + " SET ")
+	for i, s := range b.sets {
+		if i > 0 {
+			r.write(", ")
+		}
+		r.write(s.column + " = ")
+		if expr, ok := s.value.(*exprCondition); ok {
+			expr.render(r)
+		} else {
+			r.write(r.bind(s.value))
+		}
+	}
+	renderWhere(r, "WHERE", b.where)
+
+	if r.err != nil {
+		return "", nil, r.err
+	}
+	return r.sb.String(), r.args, nil
+}
+
+// DeleteBuilder builds a DELETE statement
+type DeleteBuilder struct {
+	dialect Dialect
+	table   string
+	where   []Condition
+}
+
+// Where adds conditions, the conditions of all the calls are joined with AND
+func (b *DeleteBuilder) Where(conds ...Condition) *DeleteBuilder {
+	b.where = append(b.where, conds...)
+	return b
+}
+
+// Build implements Statement.
+func (b *DeleteBuilder) Build() (string, []interface{}, error) {
+	if len(b.where) == 0 {
+		return "", nil, ErrNoCondition
+	}
+
+	r := &renderer{dialect: b.dialect}
+	r.write("DELETE FROM " + b.table)
+	renderWhere(r, "WHERE", b.where)
+
+	if r.err != nil {
+		return "", nil, r.err
+	}
+	return r.sb.String(), r.args, nil
+}
+
+func sortedKeys(values map[string]interface{}) []string {
+	keys := make([]string, 0, len(values))
+	for k := range values {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
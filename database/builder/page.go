@@ -0,0 +1,110 @@
+package builder
+
+import (
+	"context"
+	"strings"
+
+	"github.com/lengocson131002/go-clean-core/database"
+	"github.com/lengocson131002/go-clean-core/pagination"
+)
+
+// QueryPage returns the page of the rows of the select, the rows are counted when the request asks for the total
+func QueryPage[T any](ctx context.Context, g *database.Gdbc, sel *SelectBuilder, req pagination.PageRequest) (pagination.Page[T], error) {
+	req = req.Normalize()
+	page := pagination.Page[T]{
+		Page: req.Page,
+		Size: req.Size,
+	}
+
+	// fetch a row more to know whether there is a next page
+	paged := *sel
+	items, err := QueryAll[T](ctx, g, paged.Limit(uint64(req.Size+1)).Offset(uint64(req.Offset())))
+	if err != nil {
+		return page, err
+	}
+
+	if len(items) > req.Size {
+		items = items[:req.Size]
+		page.HasNext = true
+	}
+	page.Items = items
+
+	if req.WithTotal {
+		total, err := QueryOne[int64](ctx, g, sel.Count())
+		if err != nil {
+			return page, err
+		}
+		page.Total = &total
+	}
+
+	return page, nil
+}
+
+// QueryCursor returns the page of the rows of the select after the cursor of the request, ordered by the keyset
+func QueryCursor[T any](ctx context.Context, g *database.Gdbc, sel *SelectBuilder, req pagination.CursorRequest, keyset pagination.Keyset[T]) (pagination.CursorPage[T], error) {
+	req = req.Normalize()
+	page := pagination.CursorPage[T]{
+		Size: req.Size,
+	}
+
+	paged := *sel
+	paged.where = append([]Condition(nil), sel.where...)
+	paged.orderBy = nil
+
+	if len(req.Cursor) > 0 {
+		after, err := keyset.After(req.Cursor)
+		if err != nil {
+			return page, err
+		}
+		paged.Where(KeysetAfter(keyset.Columns, after, keyset.Desc))
+	}
+
+	direction := ""
+	if keyset.Desc {
+		direction = " DESC"
+	}
+	for _, c := range keyset.Columns {
+		paged.OrderBy(c + direction)
+	}
+
+	// fetch a row more to know whether there is a next page
+	items, err := QueryAll[T](ctx, g, paged.Limit(uint64(req.Size+1)))
+	if err != nil {
+		return page, err
+	}
+
+	if len(items) > req.Size {
+		items = items[:req.Size]
+		page.HasNext = true
+		if page.NextCursor, err = keyset.Cursor(items[len(items)-1]); err != nil {
+			return page, err
+		}
+	}
+	page.Items = items
+
+	return page, nil
+}
+
+// KeysetAfter is true for the rows ordered after the values of the columns,
+// e.g. (a > ? OR (a = ? AND b > ?)) for the ascending columns a and b
+func KeysetAfter(columns []string, values []interface{}, desc bool) Condition {
+	op := ">"
+	if desc {
+		op = "<"
+	}
+
+	conds := make([]Condition, 0, len(columns))
+	for i := range columns {
+		parts := make([]string, 0, i+1)
+		for j := 0; j < i; j++ {
+			parts = append(parts, columns[j]+" = ?")
+		}
+		parts = append(parts, columns[i]+" "+op+" ?")
+		expr := strings.Join(parts, " AND ")
+		if i > 0 {
+			expr = "(" + expr + ")"
+		}
+		conds = append(conds, Expr(expr, values[:i+1]...))
+	}
+	return Or(conds...)
+}
@@ -0,0 +1,119 @@
+package builder
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/lengocson131002/go-clean-core/database"
+	"github.com/lengocson131002/go-clean-core/database/sqlx/sqlxtest"
+	"github.com/lengocson131002/go-clean-core/pagination"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type event struct {
+	Id        int64     `db:"id"`
+	Name      string    `db:"name"`
+	CreatedAt time.Time `db:"created_at"`
+}
+
+var eventKeyset = pagination.Keyset[event]{
+	Columns: []string{"created_at", "id"},
+	Desc:    true,
+	Key: func(e event) []interface{} {
+		return []interface{}{e.CreatedAt, e.Id}
+	},
+	Types: []reflect.Type{reflect.TypeOf(time.Time{}), reflect.TypeOf(int64(0))},
+}
+
+func newEvents(t *testing.T, n int) *database.Gdbc {
+	g := sqlxtest.Open(t, `CREATE TABLE events (id INTEGER PRIMARY KEY, name TEXT, created_at TIMESTAMP)`)
+	ctx := context.Background()
+
+	// two events share each creation time
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	insert := New(SQLite).Insert("events").Columns("id", "name", "created_at")
+	for i := 1; i <= n; i++ {
+		insert.Values(i, fmt.Sprintf("event %d", i), start.Add(time.Duration(i/2)*time.Minute))
+	}
+	_, err := Exec(ctx, g, insert)
+	require.NoError(t, err)
+
+	return g
+}
+
+func TestQueryPage(t *testing.T) {
+	g := newEvents(t, 7)
+	sel := New(SQLite).Select("id").From("events").Where(Gt("id", 1)).OrderBy("id")
+
+	page, err := QueryPage[int64](context.Background(), g, sel, pagination.PageRequest{Page: 2, Size: 4, WithTotal: true})
+	require.NoError(t, err)
+	assert.Equal(t, []int64{6, 7}, page.Items)
+	assert.False(t, page.HasNext)
+	require.NotNil(t, page.Total)
+	assert.Equal(t, int64(6), *page.Total)
+	pages, _ := page.TotalPages()
+	assert.Equal(t, int64(2), pages)
+
+	page, err = QueryPage[int64](context.Background(), g, sel, pagination.PageRequest{Size: 4})
+	require.NoError(t, err)
+	assert.Equal(t, []int64{2, 3, 4, 5}, page.Items)
+	assert.True(t, page.HasNext)
+	assert.Nil(t, page.Total)
+}
+
+func TestQueryCursor(t *testing.T) {
+	g := newEvents(t, 7)
+	sel := New(SQLite).Select("id", "name", "created_at").From("events")
+
+	var (
+		ids []int64
+		req = pagination.CursorRequest{Size: 3}
+	)
+	for {
+		page, err := QueryCursor(context.Background(), g, sel, req, eventKeyset)
+		require.NoError(t, err)
+		for _, e := range page.Items {
+			ids = append(ids, e.Id)
+		}
+		if !page.HasNext {
+			assert.Empty(t, page.NextCursor)
+			break
+		}
+		req.Cursor = page.NextCursor
+	}
+
+	// newest first, the events created at the same time by descending id
+	assert.Equal(t, []int64{7, 6, 5, 4, 3, 2, 1}, ids)
+
+	_, err := QueryCursor(context.Background(), g, sel, pagination.CursorRequest{Cursor: "not a cursor"}, eventKeyset)
+	assert.ErrorIs(t, err, pagination.ErrInvalidCursor)
+}
+
+func TestKeysetPointerItems(t *testing.T) {
+	created := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	keyset := pagination.Keyset[*event]{
+		Columns: []string{"created_at", "id"},
+		Key: func(e *event) []interface{} {
+			return []interface{}{e.CreatedAt, e.Id}
+		},
+	}
+
+	cursor, err := keyset.Cursor(&event{Id: 3, CreatedAt: created})
+	require.NoError(t, err)
+
+	// the types are taken from the key of a zero event
+	after, err := keyset.After(cursor)
+	require.NoError(t, err)
+	assert.Equal(t, []interface{}{created, int64(3)}, after)
+}
+
+func TestKeysetAfter(t *testing.T) {
+	query, args, err := New(Postgres).Select().From("t").Where(KeysetAfter([]string{"a", "b"}, []interface{}{1, 2}, false)).Build()
+	require.NoError(t, err)
+	assert.Equal(t, "SELECT * FROM t WHERE (a > $1 OR (a = $2 AND b > $3))", query)
+	assert.Equal(t, []interface{}{1, 1, 2}, args)
+}
@@ -0,0 +1,178 @@
+package builder
+
+import (
+	"fmt"
+	"strings"
+)
+
+type join struct {
+	kind  string
+	table string
+	on    Condition
+}
+
+// SelectBuilder builds a SELECT statement
+type SelectBuilder struct {
+	dialect    Dialect
+	distinct   bool
+	columns    []string
+	from       string
+	fromSelect *SelectBuilder
+	joins      []join
+	where      []Condition
+	groupBy    []string
+	having     []Condition
+	orderBy    []string
+	limit      *uint64
+	offset     *uint64
+}
+
+func (b *SelectBuilder) Distinct() *SelectBuilder {
+	b.distinct = true
+	return b
+}
+
+func (b *SelectBuilder) From(table string) *SelectBuilder {
+	b.from = table
+	return b
+}
+
+// Join adds an INNER JOIN of the table on the condition, e.g. Join("orders o", Expr("o.user_id = u.id"))
+func (b *SelectBuilder) Join(table string, on Condition) *SelectBuilder {
+	return b.join("JOIN", table, on)
+}
+
+func (b *SelectBuilder) LeftJoin(table string, on Condition) *SelectBuilder {
+	return b.join("LEFT JOIN", table, on)
+}
+
+func (b *SelectBuilder) RightJoin(table string, on Condition) *SelectBuilder {
+	return b.join("RIGHT JOIN", table, on)
+}
+
+func (b *SelectBuilder) join(kind string, table string, on Condition) *SelectBuilder {
+	b.joins = append(b.joins, join{kind: kind, table: table, on: on})
+	return b
+}
+
+// Where adds conditions, the conditions of all the calls are joined with AND
+func (b *SelectBuilder) Where(conds ...Condition) *SelectBuilder {
+	b.where = append(b.where, conds...)
+	return b
+}
+
+func (b *SelectBuilder) GroupBy(columns ...string) *SelectBuilder {
+	b.groupBy = append(b.groupBy, columns...)
+	return b
+}
+
+func (b *SelectBuilder) Having(conds ...Condition) *SelectBuilder {
+	b.having = append(b.having, conds...)
+	return b
+}
+
+// OrderBy adds the orderings, e.g. OrderBy("created_at DESC", "id")
+func (b *SelectBuilder) OrderBy(orderings ...string) *SelectBuilder {
+	b.orderBy = append(b.orderBy, orderings...)
+	return b
+}
+
+func (b *SelectBuilder) Limit(limit uint64) *SelectBuilder {
+	b.limit = &limit
+	return b
+}
+
+func (b *SelectBuilder) Offset(offset uint64) *SelectBuilder {
+	b.offset = &offset
+	return b
+}
+
+// Page limits the rows to the page, pages start from 1
+func (b *SelectBuilder) Page(page uint64, size uint64) *SelectBuilder {
+	if page < 1 {
+		page = 1
+	}
+	return b.Limit(size).Offset((page - 1) * size)
+}
+
+// Count returns the statement counting the rows of the select, without its ordering and pagination
+func (b *SelectBuilder) Count() *SelectBuilder {
+	count := *b
+	count.orderBy = nil
+	count.limit = nil
+	count.offset = nil
+
+	// count the distinct rows and the groups in a subquery
+	if b.distinct || len(b.groupBy) > 0 {
+		return (&SelectBuilder{dialect: b.dialect, columns: []string{"COUNT(*)"}}).FromSelect(&count, "counted")
+	}
+
+	count.columns = []string{"COUNT(*)"}
+	return &count
+}
+
+// FromSelect selects from the rows of the subquery
+func (b *SelectBuilder) FromSelect(sub *SelectBuilder, alias string) *SelectBuilder {
+	b.from = alias
+	b.fromSelect = sub
+	return b
+}
+
+// Build implements Statement.
+func (b *SelectBuilder) Build() (string, []interface{}, error) {
+	r := &renderer{dialect: b.dialect}
+	b.render(r)
+	if r.err != nil {
+		return "", nil, r.err
+	}
+	return r.sb.String(), r.args, nil
+}
+
+func (b *SelectBuilder) render(r *renderer) {
+	if len(b.from) == 0 {
+		r.fail(fmt.Errorf("%w: select has no table", ErrInvalidStatement))
+		return
+	}
+
+	r.write("SELECT ")
+	if b.distinct {
+		r.write("DISTINCT ")
+	}
+	if len(b.columns) == 0 {
+		r.write("*")
+	} else {
+		r.write(strings.Join(b.columns, ", "))
+	}
+
+	r.write(" FROM ")
+	if b.fromSelect != nil {
+		r.write("(")
+		b.fromSelect.render(r)
+		r.write(") ")
+	}
+	r.write(b.from)
+
+	for _, j := range b.joins {
+		r.write(" " + j.kind + " " + j.table)
+		if j.on != nil {
+			r.write(" ON ")
+			j.on.render(r)
+		}
+	}
+
+	renderWhere(r, "WHERE", b.where)
+
+	if len(b.groupBy) > 0 {
+		r.write(" GROUP BY " + strings.Join(b.groupBy, ", "))
+	}
+
+	renderWhere(r, "HAVING", b.having)
+
+	if len(b.orderBy) > 0 {
+		r.write(" ORDER BY " + strings.Join(b.orderBy, ", "))
+	}
+
+	if page := b.dialect.Paginate(b.limit, b.offset, len(b.orderBy) > 0, r.bind); len(page) > 0 {
+		r.write(" " + page)
+	}
+}
@@ -0,0 +1,74 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+)
+
+var (
+	DefaultConnectBackoff    = time.Millisecond * 500
+	DefaultMaxConnectBackoff = time.Second * 10
+)
+
+// StatsProvider is implemented by the executors having a connection pool
+type StatsProvider interface {
+	Stats() sql.DBStats
+}
+
+// RetryConnect pings the database until it succeeds, the attempts of the options are spent or the context is done
+func RetryConnect(ctx context.Context, options Options, ping func(ctx context.Context) error) error {
+	attempts := options.ConnectAttempts
+	if attempts < 1 {
+		attempts = 1
+	}
+
+	var err error
+	for attempt := 1; ; attempt++ {
+		if err = ping(ctx); err == nil {
+			return nil
+		}
+
+		if attempt >= attempts {
+			return fmt.Errorf("connect failed after %d attempts: %w", attempt, err)
+		}
+
+		backoff := exponentialBackoff(options.ConnectBackoff, options.MaxConnectBackoff, attempt)
+		if options.Logger != nil {
+			options.Logger.Warnf(ctx, "[database] connect attempt %d failed, retrying in %s: %v", attempt, backoff, err)
+		}
+
+		select {
+		case <-time.After(backoff):
+		case <-ctx.Done():
+			return fmt.Errorf("connect failed after %d attempts: %w", attempt, errors.Join(ctx.Err(), err))
+		}
+	}
+}
+
+// PingContext verifies the connection to the database, see Pinger
+func (g *Gdbc) PingContext(ctx context.Context) error {
+	if p, ok := g.Executor.(Pinger); ok {
+		return p.PingContext(ctx)
+	}
+	return nil
+}
+
+// Stats returns the statistics of the connection pool, zero when the executor has no pool
+func (g *Gdbc) Stats() sql.DBStats {
+	if s, ok := g.Executor.(StatsProvider); ok {
+		return s.Stats()
+	}
+	return sql.DBStats{}
+}
+
+// Close closes the connections of the executor, the Gdbc cannot be used afterwards
+func (g *Gdbc) Close() error {
+	if c, ok := g.Executor.(io.Closer); ok {
+		return c.Close()
+	}
+	return nil
+}
@@ -5,5 +5,6 @@ type Database interface {
 	// 1. drivername
 	// 2. dsn(connection string)
 	// 3. poolOptions: nil if no need to configure pool
-	Connect(drivername string, dsn string, poolOptions *PoolOptions) (*Gdbc, error)
+	// 4. opts: tracing, logging and metrics of the statements
+	Connect(drivername string, dsn string, poolOptions *PoolOptions, opts ...Option) (*Gdbc, error)
 }
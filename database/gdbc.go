@@ -15,41 +15,105 @@ type SqlGdbc interface {
 	QueryRow(query string, args ...interface{}) *sql.Row
 	Get(dest interface{}, query string, args ...interface{}) error
 	Select(dest interface{}, query string, args ...interface{}) error
+
+	// Context-aware variants, the context cancels the query and carries its deadline to the driver
+	ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error)
+	PrepareContext(ctx context.Context, query string) (*sql.Stmt, error)
+	QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error)
+	QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row
+	GetContext(ctx context.Context, dest interface{}, query string, args ...interface{}) error
+	SelectContext(ctx context.Context, dest interface{}, query string, args ...interface{}) error
+
+	// Rebind replaces the ? placeholders of the query with the placeholders of the driver
+	Rebind(query string) string
 }
 
 // Used this in repositories
 type Gdbc struct {
 	Executor SqlGdbc
+
+	opts Options
+}
+
+// NewGdbc returns the Gdbc running the statements on the executor, the options trace, log and observe the statements
+func NewGdbc(executor SqlGdbc, opts ...Option) *Gdbc {
+	return &Gdbc{
+		Executor: executor,
+		opts:     NewOptions(opts...),
+	}
 }
 
 // Exec implements SqlGdbc.
 func (g *Gdbc) Exec(ctx context.Context, query string, args ...interface{}) (sql.Result, error) {
-	return g.getConnection(ctx).Exec(query, args...)
+	var result sql.Result
+	err := g.observe(ctx, OperationExec, query, func(ctx context.Context) (int64, error) {
+		var err error
+		if result, err = g.getConnection(ctx).ExecContext(ctx, query, args...); err != nil {
+			return -1, err
+		}
+		if rows, err := result.RowsAffected(); err == nil {
+			return rows, nil
+		}
+		return -1, nil
+	})
+	return result, err
 }
 
 // Get implements SqlGdbc.
 func (g *Gdbc) Get(ctx context.Context, dest interface{}, query string, args ...interface{}) error {
-	return g.getConnection(ctx).Get(dest, query, args...)
+	return g.observe(ctx, OperationGet, query, func(ctx context.Context) (int64, error) {
+		if err := g.getConnection(ctx).GetContext(ctx, dest, query, args...); err != nil {
+			return 0, err
+		}
+		return 1, nil
+	})
 }
 
 // Prepare implements SqlGdbc.
 func (g *Gdbc) Prepare(ctx context.Context, query string) (*sql.Stmt, error) {
-	return g.getConnection(ctx).Prepare(query)
+	var stmt *sql.Stmt
+	err := g.observe(ctx, OperationPrepare, query, func(ctx context.Context) (int64, error) {
+		var err error
+		stmt, err = g.getConnection(ctx).PrepareContext(ctx, query)
+		return -1, err
+	})
+	return stmt, err
 }
 
 // Query implements SqlGdbc.
 func (g *Gdbc) Query(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error) {
-	return g.getConnection(ctx).Query(query, args...)
+	var rows *sql.Rows
+	err := g.observe(ctx, OperationQuery, query, func(ctx context.Context) (int64, error) {
+		var err error
+		rows, err = g.getConnection(ctx).QueryContext(ctx, query, args...)
+		return -1, err
+	})
+	return rows, err
 }
 
 // QueryRow implements SqlGdbc.
 func (g *Gdbc) QueryRow(ctx context.Context, query string, args ...interface{}) *sql.Row {
-	return g.getConnection(ctx).QueryRow(query, args...)
+	var row *sql.Row
+	g.observe(ctx, OperationQueryRow, query, func(ctx context.Context) (int64, error) {
+		row = g.getConnection(ctx).QueryRowContext(ctx, query, args...)
+		return -1, row.Err()
+	})
+	return row
 }
 
 // Select implements SqlGdbc.
 func (g *Gdbc) Select(ctx context.Context, dest interface{}, query string, args ...interface{}) error {
-	return g.getConnection(ctx).Select(dest, query, args...)
+	return g.observe(ctx, OperationSelect, query, func(ctx context.Context) (int64, error) {
+		if err := g.getConnection(ctx).SelectContext(ctx, dest, query, args...); err != nil {
+			return -1, err
+		}
+		return sliceLen(dest), nil
+	})
+}
+
+// Rebind implements SqlGdbc.
+func (g *Gdbc) Rebind(query string) string {
+	return g.Executor.Rebind(query)
 }
 
 func (g *Gdbc) getConnection(ctx context.Context) SqlGdbc {
@@ -60,10 +124,10 @@ func (g *Gdbc) getConnection(ctx context.Context) SqlGdbc {
 	return g.Executor
 }
 
-func (g *Gdbc) WithinTransaction(ctx context.Context, txFunc func(ctx context.Context) error) error {
-	return g.Executor.WithinTransaction(ctx, txFunc)
+func (g *Gdbc) WithinTransaction(ctx context.Context, txFunc func(ctx context.Context) error, opts ...TxOption) error {
+	return g.Executor.WithinTransaction(ctx, txFunc, opts...)
 }
 
-func (g *Gdbc) WithinTransactionOptions(ctx context.Context, txFunc func(ctx context.Context) error, txOptions *sql.TxOptions) error {
-	return g.Executor.WithinTransactionOptions(ctx, txFunc, txOptions)
+func (g *Gdbc) WithinTransactionOptions(ctx context.Context, txFunc func(ctx context.Context) error, txOptions *sql.TxOptions, opts ...TxOption) error {
+	return g.Executor.WithinTransactionOptions(ctx, txFunc, txOptions, opts...)
 }
@@ -0,0 +1,149 @@
+package database
+
+import (
+	"errors"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+const (
+	MetricLabelOperation = "operation"
+	MetricLabelStatus    = "status"
+
+	metricStatusSuccess = "success"
+	metricStatusError   = "error"
+)
+
+type Metrics struct {
+	QueryHistogram *prometheus.HistogramVec
+	SlowQueries    *prometheus.CounterVec
+}
+
+// NewPrometheusMetrics registers the database collectors to the registerer, the default prometheus registerer when nil.
+// Collectors already registered to the registerer are reused.
+func NewPrometheusMetrics(registerer prometheus.Registerer) (*Metrics, error) {
+	if registerer == nil {
+		registerer = prometheus.DefaultRegisterer
+	}
+
+	queryHistogram := prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Name: "db_query_duration_seconds",
+		Help: "Statement time in seconds, partitioned by operation and status",
+	}, []string{MetricLabelOperation, MetricLabelStatus})
+
+	slowQueries := prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "db_slow_queries_total",
+		Help: "Total statements slower than the slow threshold, partitioned by operation",
+	}, []string{MetricLabelOperation})
+
+	m := &Metrics{}
+	var err error
+	if m.QueryHistogram, err = register(registerer, queryHistogram); err != nil {
+		return nil, err
+	}
+	if m.SlowQueries, err = register(registerer, slowQueries); err != nil {
+		return nil, err
+	}
+
+	return m, nil
+}
+
+// register returns the already registered collector when the same one was registered before
+func register[T prometheus.Collector](registerer prometheus.Registerer, collector T) (T, error) {
+	if err := registerer.Register(collector); err != nil {
+		var are prometheus.AlreadyRegisteredError
+		if errors.As(err, &are) {
+			if existing, ok := are.ExistingCollector.(T); ok {
+				return existing, nil
+			}
+		}
+		return collector, err
+	}
+	return collector, nil
+}
+
+func (m *Metrics) observe(operation string, elapsed time.Duration, slow bool, err error) {
+	status := metricStatusSuccess
+	if err != nil {
+		status = metricStatusError
+	}
+	m.QueryHistogram.WithLabelValues(operation, status).Observe(elapsed.Seconds())
+
+	if slow {
+		m.SlowQueries.WithLabelValues(operation).Inc()
+	}
+}
+
+const MetricLabelDB = "db"
+
+// PoolCollector exports the statistics of a connection pool to prometheus
+type PoolCollector struct {
+	name  string
+	stats StatsProvider
+
+	maxOpen           *prometheus.Desc
+	open              *prometheus.Desc
+	inUse             *prometheus.Desc
+	idle              *prometheus.Desc
+	waitCount         *prometheus.Desc
+	waitDuration      *prometheus.Desc
+	maxIdleClosed     *prometheus.Desc
+	maxIdleTimeClosed *prometheus.Desc
+	maxLifetimeClosed *prometheus.Desc
+}
+
+// NewPoolCollector returns the collector of the pool statistics, labelled with the name of the database
+func NewPoolCollector(name string, stats StatsProvider) *PoolCollector {
+	labels := prometheus.Labels{MetricLabelDB: name}
+	desc := func(metric string, help string) *prometheus.Desc {
+		return prometheus.NewDesc("db_pool_"+metric, help, nil, labels)
+	}
+
+	return &PoolCollector{
+		name:              name,
+		stats:             stats,
+		maxOpen:           desc("max_open_connections", "Maximum number of open connections"),
+		open:              desc("open_connections", "Number of established connections, in use and idle"),
+		inUse:             desc("in_use_connections", "Number of connections in use"),
+		idle:              desc("idle_connections", "Number of idle connections"),
+		waitCount:         desc("wait_count_total", "Total connections waited for"),
+		waitDuration:      desc("wait_duration_seconds_total", "Total time blocked waiting for a connection in seconds"),
+		maxIdleClosed:     desc("max_idle_closed_total", "Total connections closed due to the maximum idle connections"),
+		maxIdleTimeClosed: desc("max_idle_time_closed_total", "Total connections closed due to the maximum idle time"),
+		maxLifetimeClosed: desc("max_lifetime_closed_total", "Total connections closed due to the maximum lifetime"),
+	}
+}
+
+// RegisterPoolCollector registers the collector of the pool statistics to the default prometheus registerer
+func RegisterPoolCollector(name string, stats StatsProvider) (*PoolCollector, error) {
+	return register(prometheus.DefaultRegisterer, NewPoolCollector(name, stats))
+}
+
+// Describe implements prometheus.Collector.
+func (c *PoolCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- c.maxOpen
+	ch <- c.open
+	ch <- c.inUse
+	ch <- c.idle
+	ch <- c.waitCount
+	ch <- c.waitDuration
+	ch <- c.maxIdleClosed
+	ch <- c.maxIdleTimeClosed
+	ch <- c.maxLifetimeClosed
+}
+
+// Collect implements prometheus.Collector.
+func (c *PoolCollector) Collect(ch chan<- prometheus.Metric) {
+	s := c.stats.Stats()
+
+	ch <- prometheus.MustNewConstMetric(c.maxOpen, prometheus.GaugeValue, float64(s.MaxOpenConnections))
+	ch <- prometheus.MustNewConstMetric(c.open, prometheus.GaugeValue, float64(s.OpenConnections))
+	ch <- prometheus.MustNewConstMetric(c.inUse, prometheus.GaugeValue, float64(s.InUse))
+	ch <- prometheus.MustNewConstMetric(c.idle, prometheus.GaugeValue, float64(s.Idle))
+	ch <- prometheus.MustNewConstMetric(c.waitCount, prometheus.CounterValue, float64(s.WaitCount))
+	ch <- prometheus.MustNewConstMetric(c.waitDuration, prometheus.CounterValue, s.WaitDuration.Seconds())
+	ch <- prometheus.MustNewConstMetric(c.maxIdleClosed, prometheus.CounterValue, float64(s.MaxIdleClosed))
+	ch <- prometheus.MustNewConstMetric(c.maxIdleTimeClosed, prometheus.CounterValue, float64(s.MaxIdleTimeClosed))
+	ch <- prometheus.MustNewConstMetric(c.maxLifetimeClosed, prometheus.CounterValue, float64(s.MaxLifetimeClosed))
+}
@@ -0,0 +1,130 @@
+package migration
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"hash/fnv"
+
+	"github.com/lengocson131002/go-clean-core/database"
+)
+
+// Locker takes a lock so a single instance applies each migration. The lock is taken and released within
+// the transaction of the migration, the transaction holds the connection of session locks.
+type Locker interface {
+	Lock(ctx context.Context, db *database.Gdbc) error
+	Unlock(ctx context.Context, db *database.Gdbc) error
+}
+
+var ErrUnsupportedDriver = errors.New("unsupported migration driver")
+
+// LockerOf returns the advisory lock of the driver named after the migration table. SQLite needs no lock,
+// its transactions are serialized by the lock of the database file. It fails with ErrUnsupportedDriver for
+// the other drivers, use WithLocker to migrate them.
+func LockerOf(driverName string, table string) (Locker, error) {
+	switch driverName {
+	case "postgres", "pgx":
+		return &postgresLocker{key: lockKey(table)}, nil
+	case "mysql":
+		return &mysqlLocker{name: table}, nil
+	case "sqlserver", "mssql":
+		return &sqlServerLocker{resource: table}, nil
+	case "oracle", "godror", "oci8":
+		return &oracleLocker{id: lockKey(table) & oracleMaxLockId}, nil
+	case "sqlite", "sqlite3":
+		return noLocker{}, nil
+	}
+	return nil, fmt.Errorf("%w: %q", ErrUnsupportedDriver, driverName)
+}
+
+func lockKey(name string) int64 {
+	h := fnv.New64a()
+	h.Write([]byte(name))
+	return int64(h.Sum64())
+}
+
+// postgresLocker takes a transaction advisory lock, released when the transaction ends
+type postgresLocker struct {
+	key int64
+}
+
+func (l *postgresLocker) Lock(ctx context.Context, db *database.Gdbc) error {
+	_, err := db.Exec(ctx, "SELECT pg_advisory_xact_lock($1)", l.key)
+	return err
+}
+
+func (l *postgresLocker) Unlock(ctx context.Context, db *database.Gdbc) error {
+	return nil
+}
+
+// mysqlLocker takes a named session lock of the connection of the transaction
+type mysqlLocker struct {
+	name string
+}
+
+func (l *mysqlLocker) Lock(ctx context.Context, db *database.Gdbc) error {
+	var locked *int
+	if err := db.Get(ctx, &locked, "SELECT GET_LOCK(?, -1)", l.name); err != nil {
+		return err
+	}
+	if locked == nil || *locked != 1 {
+		return fmt.Errorf("failed to lock %s", l.name)
+	}
+	return nil
+}
+
+func (l *mysqlLocker) Unlock(ctx context.Context, db *database.Gdbc) error {
+	_, err := db.Exec(ctx, "SELECT RELEASE_LOCK(?)", l.name)
+	return err
+}
+
+// sqlServerLocker takes an application lock owned by the transaction
+type sqlServerLocker struct {
+	resource string
+}
+
+func (l *sqlServerLocker) Lock(ctx context.Context, db *database.Gdbc) error {
+	_, err := db.Exec(ctx, "EXEC sp_getapplock @Resource = @p1, @LockMode = 'Exclusive', @LockOwner = 'Transaction'", l.resource)
+	return err
+}
+
+func (l *sqlServerLocker) Unlock(ctx context.Context, db *database.Gdbc) error {
+	return nil
+}
+
+// oracleMaxLockId is the greatest id of a user lock of DBMS_LOCK
+const oracleMaxLockId = 1<<30 - 1
+
+// oracleLocker takes a DBMS_LOCK user lock of the session of the transaction, the user needs the EXECUTE privilege
+// on DBMS_LOCK. The lock is not released on commit, Oracle commits each DDL statement implicitly.
+type oracleLocker struct {
+	id int64
+}
+
+func (l *oracleLocker) Lock(ctx context.Context, db *database.Gdbc) error {
+	// 0 is success, 4 is a lock already owned by the session
+	_, err := db.Exec(ctx, `DECLARE
+	result INTEGER;
+BEGIN
+	result := DBMS_LOCK.REQUEST(id => :1, lockmode => DBMS_LOCK.X_MODE, timeout => DBMS_LOCK.MAXWAIT, release_on_commit => FALSE);
+	IF result NOT IN (0, 4) THEN
+		RAISE_APPLICATION_ERROR(-20000, 'DBMS_LOCK.REQUEST failed with ' || result);
+	END IF;
+END;`, l.id)
+	return err
+}
+
+func (l *oracleLocker) Unlock(ctx context.Context, db *database.Gdbc) error {
+	_, err := db.Exec(ctx, "DECLARE result INTEGER; BEGIN result := DBMS_LOCK.RELEASE(id => :1); END;", l.id)
+	return err
+}
+
+type noLocker struct{}
+
+func (noLocker) Lock(ctx context.Context, db *database.Gdbc) error {
+	return nil
+}
+
+func (noLocker) Unlock(ctx context.Context, db *database.Gdbc) error {
+	return nil
+}
@@ -0,0 +1,386 @@
+// Package migration applies versioned SQL migrations to a database.Gdbc and records them in a version table.
+package migration
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/lengocson131002/go-clean-core/database"
+	"github.com/lengocson131002/go-clean-core/logger"
+)
+
+const DefaultTable = "schema_migrations"
+
+var (
+	ErrNoDownMigration = errors.New("migration has no down file")
+	ErrNoDriverName    = errors.New("migration driver name is required")
+)
+
+// ChecksumMismatchError reports an applied migration whose file changed since
+type ChecksumMismatchError struct {
+	Version  int64
+	Name     string
+	Applied  string
+	Checksum string
+}
+
+func (e *ChecksumMismatchError) Error() string {
+	return fmt.Sprintf("migration %d_%s changed since it was applied, checksum %s instead of %s", e.Version, e.Name, e.Checksum, e.Applied)
+}
+
+// MissingMigrationError reports an applied migration which has no file
+type MissingMigrationError struct {
+	Version int64
+}
+
+func (e *MissingMigrationError) Error() string {
+	return fmt.Sprintf("applied migration %d has no file", e.Version)
+}
+
+// Status is the state of a migration in the database
+type Status struct {
+	Migration
+	Applied   bool
+	AppliedAt time.Time
+}
+
+type record struct {
+	Version   int64  `db:"version"`
+	Name      string `db:"name"`
+	Checksum  string `db:"checksum"`
+	AppliedAt int64  `db:"applied_at"`
+}
+
+// Migrator applies the migrations of a source. Each migration runs in its own transaction holding the lock
+// of the Locker, the databases without transactional DDL (e.g. MySQL and Oracle) cannot roll back a failed migration.
+// A migration file runs as a single statement, MySQL needs the multiStatements DSN parameter for files of several statements.
+type Migrator struct {
+	db         *database.Gdbc
+	source     Source
+	table      string
+	driverName string
+	locker     Locker
+	dryRun     io.Writer
+	logger     logger.Logger
+	now        func() time.Time
+}
+
+type Option func(*Migrator)
+
+// WithTable sets the version table, defaults to schema_migrations
+func WithTable(table string) Option {
+	return func(m *Migrator) {
+		m.table = table
+	}
+}
+
+// WithDriverName sets the driver of the database, it is required. The migrator takes the advisory lock of the driver, see LockerOf
+func WithDriverName(driverName string) Option {
+	return func(m *Migrator) {
+		m.driverName = driverName
+	}
+}
+
+// WithLocker replaces the advisory lock of the driver, e.g. to migrate a driver LockerOf does not support
+func WithLocker(locker Locker) Option {
+	return func(m *Migrator) {
+		m.locker = locker
+	}
+}
+
+// WithDryRun writes the statements of the migrations to w instead of running them. A dry run is read-only,
+// it does not create the version table and reports no migration as applied or reverted.
+func WithDryRun(w io.Writer) Option {
+	return func(m *Migrator) {
+		m.dryRun = w
+	}
+}
+
+func WithLogger(log logger.Logger) Option {
+	return func(m *Migrator) {
+		m.logger = log
+	}
+}
+
+// New returns the migrator of the source. It fails when no driver name is given, and when the driver has no
+// advisory lock and no Locker is given, so that concurrent instances never migrate without a lock.
+func New(db *database.Gdbc, source Source, opts ...Option) (*Migrator, error) {
+	m := &Migrator{
+		db:     db,
+		source: source,
+		table:  DefaultTable,
+		now:    time.Now,
+	}
+
+	for _, opt := range opts {
+		opt(m)
+	}
+
+	if len(m.driverName) == 0 {
+		return nil, ErrNoDriverName
+	}
+	if m.locker == nil {
+		locker, err := LockerOf(m.driverName, m.table)
+		if err != nil {
+			return nil, err
+		}
+		m.locker = locker
+	}
+
+	return m, nil
+}
+
+func (m *Migrator) query(query string) string {
+	return m.db.Rebind(fmt.Sprintf(query, m.table))
+}
+
+// CreateTable creates the version table when it does not exist
+func (m *Migrator) CreateTable(ctx context.Context) error {
+	_, err := m.db.Exec(ctx, m.query("CREATE TABLE IF NOT EXISTS %s (version BIGINT NOT NULL PRIMARY KEY, name VARCHAR(255) NOT NULL, checksum VARCHAR(64) NOT NULL, applied_at BIGINT NOT NULL)"))
+	return err
+}
+
+// tableExists reports whether the version table exists
+func (m *Migrator) tableExists(ctx context.Context) (bool, error) {
+	var query string
+	switch m.driverName {
+	case "postgres", "pgx":
+		query = "SELECT COUNT(*) WHERE to_regclass(?) IS NOT NULL"
+	case "mysql":
+		query = "SELECT COUNT(*) FROM information_schema.tables WHERE table_schema = DATABASE() AND table_name = ?"
+	case "sqlserver", "mssql":
+		query = "SELECT COUNT(*) WHERE OBJECT_ID(?, 'U') IS NOT NULL"
+	case "oracle", "godror", "oci8":
+		query = "SELECT COUNT(*) FROM user_tables WHERE table_name = UPPER(?)"
+	case "sqlite", "sqlite3":
+		query = "SELECT COUNT(*) FROM sqlite_master WHERE type = 'table' AND name = ?"
+	default:
+		query = "SELECT COUNT(*) FROM information_schema.tables WHERE table_name = ?"
+	}
+
+	var count int
+	if err := m.db.Get(ctx, &count, m.db.Rebind(query), m.table); err != nil {
+		return false, err
+	}
+	return count > 0, nil
+}
+
+// applied returns the applied migrations by version, none when a dry run finds no version table
+func (m *Migrator) applied(ctx context.Context) (map[int64]record, error) {
+	if m.dryRun != nil {
+		exists, err := m.tableExists(ctx)
+		if err != nil {
+			return nil, err
+		}
+		if !exists {
+			return map[int64]record{}, nil
+		}
+	}
+
+	var records []record
+	if err := m.db.Select(ctx, &records, m.query("SELECT version, name, checksum, applied_at FROM %s")); err != nil {
+		return nil, err
+	}
+
+	applied := make(map[int64]record, len(records))
+	for _, r := range records {
+		applied[r.Version] = r
+	}
+	return applied, nil
+}
+
+// Status returns the migrations of the source and whether they are applied, oldest first
+func (m *Migrator) Status(ctx context.Context) ([]Status, error) {
+	migrations, err := m.source.Migrations()
+	if err != nil {
+		return nil, err
+	}
+	applied, err := m.applied(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	statuses := make([]Status, 0, len(migrations))
+	for _, mg := range migrations {
+		s := Status{Migration: mg}
+		if r, ok := applied[mg.Version]; ok {
+			s.Applied = true
+			s.AppliedAt = time.Unix(0, r.AppliedAt)
+		}
+		statuses = append(statuses, s)
+	}
+	return statuses, nil
+}
+
+// Verify checks that the applied migrations have a file which did not change since
+func (m *Migrator) Verify(ctx context.Context) error {
+	migrations, err := m.source.Migrations()
+	if err != nil {
+		return err
+	}
+	applied, err := m.applied(ctx)
+	if err != nil {
+		return err
+	}
+	return verify(migrations, applied)
+}
+
+func verify(migrations []Migration, applied map[int64]record) error {
+	byVersion := make(map[int64]Migration, len(migrations))
+	for _, mg := range migrations {
+		byVersion[mg.Version] = mg
+	}
+
+	var errs []error
+	for version, r := range applied {
+		mg, ok := byVersion[version]
+		if !ok {
+			errs = append(errs, &MissingMigrationError{Version: version})
+			continue
+		}
+		if mg.Checksum != r.Checksum {
+			errs = append(errs, &ChecksumMismatchError{Version: version, Name: mg.Name, Applied: r.Checksum, Checksum: mg.Checksum})
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// Up applies the pending migrations, see UpTo
+func (m *Migrator) Up(ctx context.Context) ([]Migration, error) {
+	return m.UpTo(ctx, -1)
+}
+
+// UpTo applies the pending migrations up to the version, all of them when the version is negative.
+// It fails before applying any migration when Verify fails, and returns the migrations it applied, none on a dry run.
+func (m *Migrator) UpTo(ctx context.Context, version int64) ([]Migration, error) {
+	migrations, err := m.source.Migrations()
+	if err != nil {
+		return nil, err
+	}
+	if m.dryRun == nil {
+		if err := m.CreateTable(ctx); err != nil {
+			return nil, err
+		}
+	}
+	applied, err := m.applied(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if err := verify(migrations, applied); err != nil {
+		return nil, err
+	}
+
+	var done []Migration
+	for _, mg := range migrations {
+		if version >= 0 && mg.Version > version {
+			break
+		}
+		if _, ok := applied[mg.Version]; ok {
+			continue
+		}
+
+		ran, err := m.run(ctx, mg, mg.Up, true)
+		if err != nil {
+			return done, fmt.Errorf("migration %d_%s: %w", mg.Version, mg.Name, err)
+		}
+		if ran {
+			done = append(done, mg)
+		}
+	}
+	return done, nil
+}
+
+// Down reverts the last steps applied migrations, newest first, and returns the migrations it reverted, none on a dry run
+func (m *Migrator) Down(ctx context.Context, steps int) ([]Migration, error) {
+	statuses, err := m.Status(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var done []Migration
+	for i := len(statuses) - 1; i >= 0 && steps > 0; i-- {
+		mg := statuses[i]
+		if !mg.Applied {
+			continue
+		}
+		steps--
+		if len(mg.Down) == 0 {
+			return done, fmt.Errorf("migration %d_%s: %w", mg.Version, mg.Name, ErrNoDownMigration)
+		}
+
+		ran, err := m.run(ctx, mg.Migration, mg.Down, false)
+		if err != nil {
+			return done, fmt.Errorf("migration %d_%s: %w", mg.Version, mg.Name, err)
+		}
+		if ran {
+			done = append(done, mg.Migration)
+		}
+	}
+	return done, nil
+}
+
+// run runs the statements of the migration and records it in a transaction holding the lock. It returns false
+// when another instance applied or reverted the migration meanwhile, and on a dry run.
+func (m *Migrator) run(ctx context.Context, mg Migration, stmts string, up bool) (bool, error) {
+	if m.dryRun != nil {
+		direction := "up"
+		if !up {
+			direction = "down"
+		}
+		_, err := fmt.Fprintf(m.dryRun, "-- %d_%s (%s)\n%s\n", mg.Version, mg.Name, direction, stmts)
+		return false, err
+	}
+
+	ran := false
+	err := m.db.WithinTransaction(ctx, func(ctx context.Context) (err error) {
+		if err := m.locker.Lock(ctx, m.db); err != nil {
+			return fmt.Errorf("lock: %w", err)
+		}
+		defer func() {
+			if unlockErr := m.locker.Unlock(ctx, m.db); unlockErr != nil && err == nil {
+				err = fmt.Errorf("unlock: %w", unlockErr)
+			}
+		}()
+
+		// another instance may have run the migration while this one waited for the lock
+		var count int
+		if err := m.db.Get(ctx, &count, m.query("SELECT COUNT(*) FROM %s WHERE version = ?"), mg.Version); err != nil {
+			return err
+		}
+		if (count > 0) == up {
+			return nil
+		}
+
+		if _, err := m.db.Exec(ctx, stmts); err != nil {
+			return err
+		}
+
+		if up {
+			_, err = m.db.Exec(ctx, m.query("INSERT INTO %s (version, name, checksum, applied_at) VALUES (?, ?, ?, ?)"),
+				mg.Version, mg.Name, mg.Checksum, m.now().UnixNano())
+		} else {
+			_, err = m.db.Exec(ctx, m.query("DELETE FROM %s WHERE version = ?"), mg.Version)
+		}
+		if err != nil {
+			return err
+		}
+
+		ran = true
+		return nil
+	}, database.WithPropagation(database.PropagationRequiresNew))
+	if err != nil {
+		return false, err
+	}
+
+	if ran && m.logger != nil {
+		if up {
+			m.logger.Infof(ctx, "[migration] applied %d_%s", mg.Version, mg.Name)
+		} else {
+			m.logger.Infof(ctx, "[migration] reverted %d_%s", mg.Version, mg.Name)
+		}
+	}
+	return ran, nil
+}
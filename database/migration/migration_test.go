@@ -0,0 +1,185 @@
+package migration
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"testing"
+	"testing/fstest"
+
+	"github.com/lengocson131002/go-clean-core/database"
+	"github.com/lengocson131002/go-clean-core/database/sqlx/sqlxtest"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newTestMigrator(t *testing.T, db *database.Gdbc, fsys fstest.MapFS, opts ...Option) *Migrator {
+	m, err := New(db, FromFS(fsys, "migrations"), append([]Option{WithDriverName(sqlxtest.DriverName)}, opts...)...)
+	require.NoError(t, err)
+	return m
+}
+
+func testFS() fstest.MapFS {
+	return fstest.MapFS{
+		"migrations/0001_create_users.up.sql":    {Data: []byte("CREATE TABLE users (id INTEGER PRIMARY KEY, name TEXT)")},
+		"migrations/0001_create_users.down.sql":  {Data: []byte("DROP TABLE users")},
+		"migrations/0002_add_email.up.sql":       {Data: []byte("ALTER TABLE users ADD COLUMN email TEXT")},
+		"migrations/0002_add_email.down.sql":     {Data: []byte("ALTER TABLE users DROP COLUMN email")},
+		"migrations/0010_create_orders.up.sql":   {Data: []byte("CREATE TABLE orders (id INTEGER PRIMARY KEY); CREATE INDEX orders_id ON orders (id)")},
+		"migrations/0010_create_orders.down.sql": {Data: []byte("DROP TABLE orders")},
+		"migrations/README.md":                   {Data: []byte("ignored")},
+	}
+}
+
+func tables(t *testing.T, db *database.Gdbc) []string {
+	var names []string
+	require.NoError(t, db.Select(context.Background(), &names,
+		`SELECT name FROM sqlite_master WHERE type = 'table' AND name <> 'schema_migrations' ORDER BY name`))
+	return names
+}
+
+func versions(migrations []Migration) []int64 {
+	var v []int64
+	for _, m := range migrations {
+		v = append(v, m.Version)
+	}
+	return v
+}
+
+func TestSource(t *testing.T) {
+	migrations, err := FromFS(testFS(), "migrations").Migrations()
+	require.NoError(t, err)
+	assert.Equal(t, []int64{1, 2, 10}, versions(migrations))
+	assert.Equal(t, "create_users", migrations[0].Name)
+	assert.Equal(t, Checksum("CREATE TABLE users (id INTEGER PRIMARY KEY, name TEXT)", "DROP TABLE users"), migrations[0].Checksum)
+	assert.NotEqual(t, Checksum("CREATE TABLE users (id INTEGER PRIMARY KEY, name TEXT)", ""), migrations[0].Checksum)
+
+	fsys := testFS()
+	delete(fsys, "migrations/0002_add_email.up.sql")
+	_, err = FromFS(fsys, "migrations").Migrations()
+	assert.Error(t, err)
+}
+
+func TestUpAndDown(t *testing.T) {
+	db := sqlxtest.Open(t)
+	ctx := context.Background()
+	m := newTestMigrator(t, db, testFS())
+
+	done, err := m.UpTo(ctx, 2)
+	require.NoError(t, err)
+	assert.Equal(t, []int64{1, 2}, versions(done))
+
+	done, err = m.Up(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, []int64{10}, versions(done))
+	assert.Equal(t, []string{"orders", "users"}, tables(t, db))
+
+	// applied migrations are skipped
+	done, err = m.Up(ctx)
+	require.NoError(t, err)
+	assert.Empty(t, done)
+
+	statuses, err := m.Status(ctx)
+	require.NoError(t, err)
+	require.Len(t, statuses, 3)
+	assert.True(t, statuses[2].Applied)
+	assert.False(t, statuses[2].AppliedAt.IsZero())
+
+	done, err = m.Down(ctx, 2)
+	require.NoError(t, err)
+	assert.Equal(t, []int64{10, 2}, versions(done))
+	assert.Equal(t, []string{"users"}, tables(t, db))
+
+	statuses, err = m.Status(ctx)
+	require.NoError(t, err)
+	assert.True(t, statuses[0].Applied)
+	assert.False(t, statuses[1].Applied)
+}
+
+func TestFailedMigrationRollsBack(t *testing.T) {
+	db := sqlxtest.Open(t)
+	fsys := testFS()
+	fsys["migrations/0002_add_email.up.sql"] = &fstest.MapFile{Data: []byte("ALTER TABLE users ADD COLUMN email TEXT; INSERT INTO missing VALUES (1)")}
+
+	done, err := newTestMigrator(t, db, fsys).Up(context.Background())
+	require.Error(t, err)
+	assert.Equal(t, []int64{1}, versions(done))
+
+	statuses, err := newTestMigrator(t, db, fsys).Status(context.Background())
+	require.NoError(t, err)
+	assert.False(t, statuses[1].Applied)
+
+	var columns []string
+	require.NoError(t, db.Select(context.Background(), &columns, `SELECT name FROM pragma_table_info('users')`))
+	assert.Equal(t, []string{"id", "name"}, columns)
+}
+
+func TestVerify(t *testing.T) {
+	db := sqlxtest.Open(t)
+	ctx := context.Background()
+
+	_, err := newTestMigrator(t, db, testFS()).Up(ctx)
+	require.NoError(t, err)
+
+	fsys := testFS()
+	// a changed down file is a changed migration too
+	fsys["migrations/0001_create_users.down.sql"] = &fstest.MapFile{Data: []byte("DROP TABLE IF EXISTS users")}
+	delete(fsys, "migrations/0010_create_orders.up.sql")
+	delete(fsys, "migrations/0010_create_orders.down.sql")
+
+	err = newTestMigrator(t, db, fsys).Verify(ctx)
+	var mismatch *ChecksumMismatchError
+	require.True(t, errors.As(err, &mismatch))
+	assert.Equal(t, int64(1), mismatch.Version)
+	var missing *MissingMigrationError
+	require.True(t, errors.As(err, &missing))
+	assert.Equal(t, int64(10), missing.Version)
+
+	_, err = newTestMigrator(t, db, fsys).Up(ctx)
+	assert.ErrorAs(t, err, &mismatch)
+}
+
+func TestDryRun(t *testing.T) {
+	db := sqlxtest.Open(t)
+	ctx := context.Background()
+	var out bytes.Buffer
+
+	done, err := newTestMigrator(t, db, testFS(), WithDryRun(&out)).UpTo(ctx, 1)
+	require.NoError(t, err)
+	assert.Empty(t, done)
+	assert.Equal(t, "-- 1_create_users (up)\nCREATE TABLE users (id INTEGER PRIMARY KEY, name TEXT)\n", out.String())
+
+	// the dry run created no table, not even the version table
+	var count int
+	require.NoError(t, db.Get(ctx, &count, `SELECT COUNT(*) FROM sqlite_master WHERE type = 'table'`))
+	assert.Zero(t, count)
+
+	_, err = newTestMigrator(t, db, testFS()).UpTo(ctx, 1)
+	require.NoError(t, err)
+	out.Reset()
+	done, err = newTestMigrator(t, db, testFS(), WithDryRun(&out)).Up(ctx)
+	require.NoError(t, err)
+	assert.Empty(t, done)
+	assert.Contains(t, out.String(), "-- 2_add_email (up)")
+	assert.NotContains(t, out.String(), "-- 1_create_users")
+
+	out.Reset()
+	done, err = newTestMigrator(t, db, testFS(), WithDryRun(&out)).Down(ctx, 1)
+	require.NoError(t, err)
+	assert.Empty(t, done)
+	assert.Equal(t, "-- 1_create_users (down)\nDROP TABLE users\n", out.String())
+	assert.Equal(t, []string{"users"}, tables(t, db))
+}
+
+func TestNewRequiresDriverName(t *testing.T) {
+	db := sqlxtest.Open(t)
+
+	_, err := New(db, FromFS(testFS(), "migrations"))
+	assert.ErrorIs(t, err, ErrNoDriverName)
+
+	_, err = New(db, FromFS(testFS(), "migrations"), WithDriverName("db2"))
+	assert.ErrorIs(t, err, ErrUnsupportedDriver)
+
+	_, err = New(db, FromFS(testFS(), "migrations"), WithDriverName("db2"), WithLocker(noLocker{}))
+	assert.NoError(t, err)
+}
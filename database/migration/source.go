@@ -0,0 +1,113 @@
+package migration
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io/fs"
+	"os"
+	"path"
+	"regexp"
+	"sort"
+	"strconv"
+)
+
+// fileName matches the migration files, e.g. `0001_create_users.up.sql` and `0001_create_users.down.sql`
+var fileName = regexp.MustCompile(`^(\d+)_(.+)\.(up|down)\.sql$`)
+
+// Migration is a versioned change of the schema, Down is empty when the migration cannot be reverted
+type Migration struct {
+	Version  int64
+	Name     string
+	Up       string
+	Down     string
+	Checksum string
+}
+
+// Source provides the migrations
+type Source interface {
+	Migrations() ([]Migration, error)
+}
+
+type fsSource struct {
+	fsys fs.FS
+	dir  string
+}
+
+// FromFS reads the migration files of the directory of the file system, e.g. an embed.FS.
+// The files are named `<version>_<name>.up.sql` and `<version>_<name>.down.sql`, other files are ignored.
+func FromFS(fsys fs.FS, dir string) Source {
+	return &fsSource{fsys: fsys, dir: dir}
+}
+
+// FromDir reads the migration files of the directory, see FromFS
+func FromDir(dir string) Source {
+	return FromFS(os.DirFS(dir), ".")
+}
+
+func (s *fsSource) Migrations() ([]Migration, error) {
+	entries, err := fs.ReadDir(s.fsys, s.dir)
+	if err != nil {
+		return nil, err
+	}
+
+	byVersion := make(map[int64]*Migration)
+	for _, e := range entries {
+		if e.IsDir() {
+			continue
+		}
+		match := fileName.FindStringSubmatch(e.Name())
+		if match == nil {
+			continue
+		}
+
+		version, err := strconv.ParseInt(match[1], 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("migration %s: %w", e.Name(), err)
+		}
+
+		content, err := fs.ReadFile(s.fsys, path.Join(s.dir, e.Name()))
+		if err != nil {
+			return nil, err
+		}
+
+		m, ok := byVersion[version]
+		if !ok {
+			m = &Migration{Version: version, Name: match[2]}
+			byVersion[version] = m
+		} else if m.Name != match[2] {
+			return nil, fmt.Errorf("migration %d is named %s and %s", version, m.Name, match[2])
+		}
+
+		if match[3] == "up" {
+			m.Up = string(content)
+		} else {
+			m.Down = string(content)
+		}
+	}
+
+	migrations := make([]Migration, 0, len(byVersion))
+	for _, m := range byVersion {
+		if len(m.Up) == 0 {
+			return nil, fmt.Errorf("migration %d_%s has no up file", m.Version, m.Name)
+		}
+		m.Checksum = Checksum(m.Up, m.Down)
+		migrations = append(migrations, *m)
+	}
+	sort.Slice(migrations, func(i, j int) bool { return migrations[i].Version < migrations[j].Version })
+
+	return migrations, nil
+}
+
+// Checksum returns the SHA-256 of the up and down migrations, an applied migration whose files changed has another
+// checksum. The checksum of a migration without down file is the SHA-256 of its up file.
+func Checksum(up string, down string) string {
+	h := sha256.New()
+	h.Write([]byte(up))
+	if len(down) > 0 {
+		// the separator tells the end of the up file from the start of the down file
+		h.Write([]byte{0})
+		h.Write([]byte(down))
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
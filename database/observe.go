@@ -0,0 +1,90 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"reflect"
+	"regexp"
+	"time"
+
+	"github.com/lengocson131002/go-clean-core/trace"
+)
+
+const (
+	OperationExec     = "exec"
+	OperationPrepare  = "prepare"
+	OperationQuery    = "query"
+	OperationQueryRow = "query_row"
+	OperationGet      = "get"
+	OperationSelect   = "select"
+)
+
+var (
+	sqlStringLiteral  = regexp.MustCompile(`'(?:[^']|'')*'`)
+	sqlNumericLiteral = regexp.MustCompile(`(^|[^\w$:@.])(\d+(?:\.\d+)?)`)
+)
+
+// MaskSQL replaces the string and numeric literals of the statement with ?, the placeholders are kept
+func MaskSQL(query string) string {
+	query = sqlStringLiteral.ReplaceAllString(query, "?")
+	return sqlNumericLiteral.ReplaceAllString(query, "${1}?")
+}
+
+// observe runs the statement in a span, logs it when it is slow and observes its duration.
+// The statement returns the rows it affected, negative when unknown. No rows are not an error of the statement.
+func (g *Gdbc) observe(ctx context.Context, operation string, query string, stmt func(ctx context.Context) (int64, error)) error {
+	opts := g.opts
+	if opts.Tracer == nil && opts.Logger == nil && opts.Metrics == nil {
+		_, err := stmt(ctx)
+		return err
+	}
+
+	masked := query
+	if opts.MaskSql != nil {
+		masked = opts.MaskSql(query)
+	}
+
+	var finish trace.DatabaseTraceFinishFunc
+	if opts.Tracer != nil {
+		ctx, finish = opts.Tracer.StartDatabaseTrace(ctx, "db "+operation,
+			trace.WithDBName(opts.DBName),
+			trace.WithDBSql(masked))
+	}
+
+	start := time.Now()
+	rows, err := stmt(ctx)
+	elapsed := time.Since(start)
+
+	failure := err
+	if errors.Is(err, sql.ErrNoRows) {
+		failure = nil
+	}
+
+	if finish != nil {
+		finish(ctx, trace.WithDBError(failure), trace.WithDBRowsAffected(rows))
+	}
+
+	slow := opts.SlowThreshold > 0 && elapsed >= opts.SlowThreshold
+	if slow && opts.Logger != nil {
+		opts.Logger.Warnf(ctx, "[database] slow %s took %s: %s", operation, elapsed, masked)
+	}
+
+	if opts.Metrics != nil {
+		opts.Metrics.observe(operation, elapsed, slow, failure)
+	}
+
+	return err
+}
+
+// sliceLen returns the length of the slice the dest points to, -1 when it is not a slice
+func sliceLen(dest interface{}) int64 {
+	v := reflect.ValueOf(dest)
+	for v.Kind() == reflect.Pointer {
+		v = v.Elem()
+	}
+	if v.Kind() != reflect.Slice {
+		return -1
+	}
+	return int64(v.Len())
+}
@@ -0,0 +1,107 @@
+package database_test
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/lengocson131002/go-clean-core/database"
+	"github.com/lengocson131002/go-clean-core/database/sqlx/sqlxtest"
+	"github.com/lengocson131002/go-clean-core/logger"
+	"github.com/lengocson131002/go-clean-core/trace"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type span struct {
+	name string
+	opts trace.DatabaseTraceOptions
+	end  trace.DatabaseTraceFinishOptions
+}
+
+type fakeTracer struct {
+	trace.Tracer
+	spans []*span
+}
+
+func (t *fakeTracer) StartDatabaseTrace(ctx context.Context, spanName string, opts ...trace.DatabaseTraceOption) (context.Context, trace.DatabaseTraceFinishFunc) {
+	s := &span{name: spanName}
+	for _, o := range opts {
+		o(&s.opts)
+	}
+	t.spans = append(t.spans, s)
+
+	return ctx, func(ctx context.Context, opts ...trace.DatabaseTraceFinishOption) {
+		for _, o := range opts {
+			o(&s.end)
+		}
+	}
+}
+
+type fakeLogger struct {
+	logger.Logger
+	warnings []string
+}
+
+func (l *fakeLogger) Warnf(ctx context.Context, format string, args ...interface{}) {
+	l.warnings = append(l.warnings, fmt.Sprintf(format, args...))
+}
+
+func TestMaskSQL(t *testing.T) {
+	assert.Equal(t,
+		"SELECT * FROM users WHERE name = ? AND age > ? AND t1.id = $1 AND code = :2 AND ref = @p3 LIMIT ?",
+		database.MaskSQL("SELECT * FROM users WHERE name = 'o''brien' AND age > 18.5 AND t1.id = $1 AND code = :2 AND ref = @p3 LIMIT 10"))
+}
+
+func TestObserveStatements(t *testing.T) {
+	metrics, err := database.NewPrometheusMetrics(prometheus.NewRegistry())
+	require.NoError(t, err)
+
+	tracer := &fakeTracer{}
+	log := &fakeLogger{}
+	g := sqlxtest.OpenWith(t, []database.Option{
+		database.WithDBName("test"),
+		database.WithTracer(tracer),
+		database.WithSlowQueryLog(log, time.Nanosecond),
+		database.WithMetrics(metrics),
+	})
+
+	ctx := context.Background()
+	_, err = g.Exec(ctx, `CREATE TABLE users (id INTEGER PRIMARY KEY, name TEXT)`)
+	require.NoError(t, err)
+	_, err = g.Exec(ctx, `INSERT INTO users (id, name) VALUES (1, 'alice'), (2, 'bob')`)
+	require.NoError(t, err)
+
+	var names []string
+	require.NoError(t, g.Select(ctx, &names, `SELECT name FROM users`))
+
+	var name string
+	assert.ErrorIs(t, g.Get(ctx, &name, `SELECT name FROM users WHERE id = ?`, 3), sql.ErrNoRows)
+
+	_, err = g.Exec(ctx, `INSERT INTO missing (id) VALUES (1)`)
+	require.Error(t, err)
+
+	require.Len(t, tracer.spans, 5)
+
+	insert := tracer.spans[1]
+	assert.Equal(t, "db exec", insert.name)
+	assert.Equal(t, "test", insert.opts.DBName)
+	assert.Equal(t, "INSERT INTO users (id, name) VALUES (?, ?), (?, ?)", insert.opts.DBSql)
+	assert.Equal(t, int64(2), insert.end.RowsAffected)
+
+	assert.Equal(t, int64(2), tracer.spans[2].end.RowsAffected)
+	// no rows are not an error of the statement
+	assert.NoError(t, tracer.spans[3].end.Error)
+	assert.Error(t, tracer.spans[4].end.Error)
+
+	assert.Len(t, log.warnings, 5)
+	assert.Contains(t, log.warnings[1], "INSERT INTO users (id, name) VALUES (?, ?), (?, ?)")
+
+	// exec success and error, select and get
+	assert.Equal(t, 4, testutil.CollectAndCount(metrics.QueryHistogram))
+	assert.Equal(t, float64(3), testutil.ToFloat64(metrics.SlowQueries.WithLabelValues(database.OperationExec)))
+}
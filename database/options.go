@@ -0,0 +1,93 @@
+package database
+
+import (
+	"time"
+
+	"github.com/lengocson131002/go-clean-core/logger"
+	"github.com/lengocson131002/go-clean-core/trace"
+)
+
+type Options struct {
+	// DBName is the db.name of the statement spans
+	DBName string
+	// Tracer starts a span per statement
+	Tracer trace.Tracer
+	// Logger logs the statements slower than SlowThreshold
+	Logger        logger.Logger
+	SlowThreshold time.Duration
+	// Metrics observes the statement durations
+	Metrics *Metrics
+	// MaskSql masks the statements in spans and logs, MaskSQL by default
+	MaskSql func(query string) string
+
+	// ConnectTimeout bounds the time to connect, retries included
+	ConnectTimeout time.Duration
+	// ConnectAttempts retries to connect to a database which is not reachable yet, e.g. starting with the service
+	ConnectAttempts   int
+	ConnectBackoff    time.Duration
+	MaxConnectBackoff time.Duration
+}
+
+type Option func(*Options)
+
+func WithDBName(name string) Option {
+	return func(o *Options) {
+		o.DBName = name
+	}
+}
+
+func WithTracer(tracer trace.Tracer) Option {
+	return func(o *Options) {
+		o.Tracer = tracer
+	}
+}
+
+// WithSlowQueryLog logs the statements taking at least threshold as warnings
+func WithSlowQueryLog(log logger.Logger, threshold time.Duration) Option {
+	return func(o *Options) {
+		o.Logger = log
+		o.SlowThreshold = threshold
+	}
+}
+
+func WithMetrics(metrics *Metrics) Option {
+	return func(o *Options) {
+		o.Metrics = metrics
+	}
+}
+
+func WithSqlMasker(mask func(query string) string) Option {
+	return func(o *Options) {
+		o.MaskSql = mask
+	}
+}
+
+func WithConnectTimeout(timeout time.Duration) Option {
+	return func(o *Options) {
+		o.ConnectTimeout = timeout
+	}
+}
+
+// WithConnectRetry connects up to attempts times, the backoff between the attempts doubles up to max
+func WithConnectRetry(attempts int, backoff time.Duration, max time.Duration) Option {
+	return func(o *Options) {
+		o.ConnectAttempts = attempts
+		o.ConnectBackoff = backoff
+		o.MaxConnectBackoff = max
+	}
+}
+
+func NewOptions(opts ...Option) Options {
+	options := Options{
+		MaskSql:           MaskSQL,
+		ConnectAttempts:   1,
+		ConnectBackoff:    DefaultConnectBackoff,
+		MaxConnectBackoff: DefaultMaxConnectBackoff,
+	}
+
+	for _, o := range opts {
+		o(&options)
+	}
+
+	return options
+}
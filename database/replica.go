@@ -0,0 +1,369 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Balancer selects the replica running a read
+type Balancer int
+
+const (
+	// RoundRobin takes turns between the healthy replicas
+	RoundRobin Balancer = iota
+	// LeastConnections selects the healthy replica using the fewest connections. The connections of the replicas
+	// implementing StatsProvider are in use until the rows of their queries are closed, the other replicas count
+	// the reads running.
+	LeastConnections
+)
+
+var (
+	DefaultHealthCheckInterval = time.Second * 5
+	DefaultHealthCheckTimeout  = time.Second
+)
+
+// Pinger is implemented by the executors verifying their connection, the replicas failing to ping are ejected
+type Pinger interface {
+	PingContext(ctx context.Context) error
+}
+
+type primaryKey struct{}
+
+// WithPrimary makes the reads of the context run on the primary, e.g. to read what was just written
+func WithPrimary(ctx context.Context) context.Context {
+	return context.WithValue(ctx, primaryKey{}, true)
+}
+
+type writtenKey struct{}
+
+// WithReadYourWrites pins the reads of the context to the primary once the context wrote through the router,
+// i.e. ran Exec, Prepare or a transaction, so that they read what was written. The reads before the first write
+// still run on the replicas.
+func WithReadYourWrites(ctx context.Context) context.Context {
+	return context.WithValue(ctx, writtenKey{}, new(atomic.Bool))
+}
+
+// markWritten pins the reads of the context to the primary when it asks for its writes
+func markWritten(ctx context.Context) {
+	if written, ok := ctx.Value(writtenKey{}).(*atomic.Bool); ok {
+		written.Store(true)
+	}
+}
+
+// IsPrimary tells whether the reads of the context run on the primary, see WithPrimary and WithReadYourWrites
+func IsPrimary(ctx context.Context) bool {
+	if primary, _ := ctx.Value(primaryKey{}).(bool); primary {
+		return true
+	}
+	written, ok := ctx.Value(writtenKey{}).(*atomic.Bool)
+	return ok && written.Load()
+}
+
+type ReplicaOptions struct {
+	Balancer Balancer
+	// HealthCheckInterval pings the replicas, a replica failing to ping gets no reads until it pings again.
+	// Negative disables the health checks.
+	HealthCheckInterval time.Duration
+	HealthCheckTimeout  time.Duration
+}
+
+type ReplicaOption func(*ReplicaOptions)
+
+func WithBalancer(b Balancer) ReplicaOption {
+	return func(o *ReplicaOptions) {
+		o.Balancer = b
+	}
+}
+
+func WithHealthCheck(interval time.Duration, timeout time.Duration) ReplicaOption {
+	return func(o *ReplicaOptions) {
+		o.HealthCheckInterval = interval
+		o.HealthCheckTimeout = timeout
+	}
+}
+
+type replica struct {
+	SqlGdbc
+	inflight atomic.Int64
+	healthy  atomic.Bool
+}
+
+// load returns the connections of the pool in use, a *sql.Rows holds its connection until closed. It falls back
+// to the reads running for the executors without pool, they release a query when it returns its rows.
+func (rep *replica) load() int64 {
+	if s, ok := rep.SqlGdbc.(StatsProvider); ok {
+		return int64(s.Stats().InUse)
+	}
+	return rep.inflight.Load()
+}
+
+// ReplicaRouter runs the reads (Query, QueryRow, Get and Select) on the replicas and the writes, the prepared statements
+// and the transactions on the primary. The reads run on the primary when the context asks for it, see WithPrimary,
+// or when no replica is healthy.
+//
+// The reads do not follow the writes: a read right after a write runs on a replica which may lag behind the primary
+// and miss the write. Use WithPrimary for the reads needing the latest data, or WithReadYourWrites to pin the reads
+// of a context to the primary after its first write.
+type ReplicaRouter struct {
+	primary  SqlGdbc
+	replicas []*replica
+	opts     ReplicaOptions
+	next     atomic.Uint64
+
+	closeOnce sync.Once
+	closing   chan struct{}
+	wg        sync.WaitGroup
+}
+
+// NewReplicaRouter returns the router of the primary and the replicas, it checks the health of the replicas until closed
+func NewReplicaRouter(primary SqlGdbc, replicas []SqlGdbc, opts ...ReplicaOption) *ReplicaRouter {
+	options := ReplicaOptions{
+		Balancer:            RoundRobin,
+		HealthCheckInterval: DefaultHealthCheckInterval,
+		HealthCheckTimeout:  DefaultHealthCheckTimeout,
+	}
+	for _, o := range opts {
+		o(&options)
+	}
+
+	r := &ReplicaRouter{
+		primary: primary,
+		opts:    options,
+		closing: make(chan struct{}),
+	}
+	for _, s := range replicas {
+		rep := &replica{SqlGdbc: s}
+		rep.healthy.Store(true)
+		r.replicas = append(r.replicas, rep)
+	}
+
+	if options.HealthCheckInterval > 0 && len(r.replicas) > 0 {
+		r.checkHealth()
+		r.wg.Add(1)
+		go r.healthChecks()
+	}
+
+	return r
+}
+
+func (r *ReplicaRouter) Primary() SqlGdbc {
+	return r.primary
+}
+
+// HealthyReplicas returns the number of replicas getting reads
+func (r *ReplicaRouter) HealthyReplicas() int {
+	n := 0
+	for _, rep := range r.replicas {
+		if rep.healthy.Load() {
+			n++
+		}
+	}
+	return n
+}
+
+// Close stops the health checks and closes the primary and the replicas implementing io.Closer
+func (r *ReplicaRouter) Close() error {
+	r.closeOnce.Do(func() {
+		close(r.closing)
+	})
+	r.wg.Wait()
+
+	var errs []error
+	for _, s := range append([]SqlGdbc{r.primary}, r.Replicas()...) {
+		if c, ok := s.(interface{ Close() error }); ok {
+			if err := c.Close(); err != nil {
+				errs = append(errs, err)
+			}
+		}
+	}
+	return errors.Join(errs...)
+}
+
+func (r *ReplicaRouter) Replicas() []SqlGdbc {
+	executors := make([]SqlGdbc, 0, len(r.replicas))
+	for _, rep := range r.replicas {
+		executors = append(executors, rep.SqlGdbc)
+	}
+	return executors
+}
+
+func (r *ReplicaRouter) healthChecks() {
+	defer r.wg.Done()
+
+	ticker := time.NewTicker(r.opts.HealthCheckInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			r.checkHealth()
+		case <-r.closing:
+			return
+		}
+	}
+}
+
+func (r *ReplicaRouter) checkHealth() {
+	var wg sync.WaitGroup
+	for _, rep := range r.replicas {
+		p, ok := rep.SqlGdbc.(Pinger)
+		if !ok {
+			continue
+		}
+
+		wg.Add(1)
+		go func(rep *replica, p Pinger) {
+			defer wg.Done()
+			ctx, cancel := context.WithTimeout(context.Background(), r.opts.HealthCheckTimeout)
+			defer cancel()
+			rep.healthy.Store(p.PingContext(ctx) == nil)
+		}(rep, p)
+	}
+	wg.Wait()
+}
+
+// reader runs the read on a healthy replica, on the primary when the context asks for it or no replica is healthy
+func (r *ReplicaRouter) reader(ctx context.Context, read func(s SqlGdbc)) {
+	if ctx == nil || !IsPrimary(ctx) {
+		if rep := r.pick(); rep != nil {
+			rep.inflight.Add(1)
+			defer rep.inflight.Add(-1)
+			read(rep.SqlGdbc)
+			return
+		}
+	}
+	read(r.primary)
+}
+
+func (r *ReplicaRouter) pick() *replica {
+	n := len(r.replicas)
+	if n == 0 {
+		return nil
+	}
+
+	start := int(r.next.Add(1) % uint64(n))
+	var (
+		picked *replica
+		least  int64
+	)
+	for i := 0; i < n; i++ {
+		rep := r.replicas[(start+i)%n]
+		if !rep.healthy.Load() {
+			continue
+		}
+		if r.opts.Balancer == RoundRobin {
+			return rep
+		}
+		if load := rep.load(); picked == nil || load < least {
+			picked, least = rep, load
+		}
+	}
+	return picked
+}
+
+// Exec implements SqlGdbc.
+func (r *ReplicaRouter) Exec(query string, args ...interface{}) (sql.Result, error) {
+	return r.primary.Exec(query, args...)
+}
+
+// Prepare implements SqlGdbc.
+func (r *ReplicaRouter) Prepare(query string) (*sql.Stmt, error) {
+	return r.primary.Prepare(query)
+}
+
+// Query implements SqlGdbc.
+func (r *ReplicaRouter) Query(query string, args ...interface{}) (rows *sql.Rows, err error) {
+	r.reader(nil, func(s SqlGdbc) { rows, err = s.Query(query, args...) })
+	return
+}
+
+// QueryRow implements SqlGdbc.
+func (r *ReplicaRouter) QueryRow(query string, args ...interface{}) (row *sql.Row) {
+	r.reader(nil, func(s SqlGdbc) { row = s.QueryRow(query, args...) })
+	return
+}
+
+// Get implements SqlGdbc.
+func (r *ReplicaRouter) Get(dest interface{}, query string, args ...interface{}) (err error) {
+	r.reader(nil, func(s SqlGdbc) { err = s.Get(dest, query, args...) })
+	return
+}
+
+// Select implements SqlGdbc.
+func (r *ReplicaRouter) Select(dest interface{}, query string, args ...interface{}) (err error) {
+	r.reader(nil, func(s SqlGdbc) { err = s.Select(dest, query, args...) })
+	return
+}
+
+// ExecContext implements SqlGdbc.
+func (r *ReplicaRouter) ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error) {
+	markWritten(ctx)
+	return r.primary.ExecContext(ctx, query, args...)
+}
+
+// PrepareContext implements SqlGdbc.
+func (r *ReplicaRouter) PrepareContext(ctx context.Context, query string) (*sql.Stmt, error) {
+	markWritten(ctx)
+	return r.primary.PrepareContext(ctx, query)
+}
+
+// QueryContext implements SqlGdbc.
+func (r *ReplicaRouter) QueryContext(ctx context.Context, query string, args ...interface{}) (rows *sql.Rows, err error) {
+	r.reader(ctx, func(s SqlGdbc) { rows, err = s.QueryContext(ctx, query, args...) })
+	return
+}
+
+// QueryRowContext implements SqlGdbc.
+func (r *ReplicaRouter) QueryRowContext(ctx context.Context, query string, args ...interface{}) (row *sql.Row) {
+	r.reader(ctx, func(s SqlGdbc) { row = s.QueryRowContext(ctx, query, args...) })
+	return
+}
+
+// GetContext implements SqlGdbc.
+func (r *ReplicaRouter) GetContext(ctx context.Context, dest interface{}, query string, args ...interface{}) (err error) {
+	r.reader(ctx, func(s SqlGdbc) { err = s.GetContext(ctx, dest, query, args...) })
+	return
+}
+
+// SelectContext implements SqlGdbc.
+func (r *ReplicaRouter) SelectContext(ctx context.Context, dest interface{}, query string, args ...interface{}) (err error) {
+	r.reader(ctx, func(s SqlGdbc) { err = s.SelectContext(ctx, dest, query, args...) })
+	return
+}
+
+// Rebind implements SqlGdbc.
+func (r *ReplicaRouter) Rebind(query string) string {
+	return r.primary.Rebind(query)
+}
+
+// WithinTransaction implements Transactor, the transactions run on the primary.
+func (r *ReplicaRouter) WithinTransaction(ctx context.Context, txFunc func(ctx context.Context) error, opts ...TxOption) error {
+	markWritten(ctx)
+	return r.primary.WithinTransaction(ctx, txFunc, opts...)
+}
+
+// WithinTransactionOptions implements Transactor, the transactions run on the primary.
+func (r *ReplicaRouter) WithinTransactionOptions(ctx context.Context, txFunc func(ctx context.Context) error, txOption *sql.TxOptions, opts ...TxOption) error {
+	markWritten(ctx)
+	return r.primary.WithinTransactionOptions(ctx, txFunc, txOption, opts...)
+}
+
+// Stats implements StatsProvider, it returns the statistics of the pool of the primary.
+func (r *ReplicaRouter) Stats() sql.DBStats {
+	if s, ok := r.primary.(StatsProvider); ok {
+		return s.Stats()
+	}
+	return sql.DBStats{}
+}
+
+// PingContext implements Pinger, it pings the primary.
+func (r *ReplicaRouter) PingContext(ctx context.Context) error {
+	if p, ok := r.primary.(Pinger); ok {
+		return p.PingContext(ctx)
+	}
+	return nil
+}
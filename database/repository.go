@@ -0,0 +1,232 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+
+	"github.com/jmoiron/sqlx"
+)
+
+const (
+	// TagName is the struct tag of the columns, e.g. `db:"id,pk,auto"`
+	TagName = "db"
+	// TagOptionPk marks the key columns of Update
+	TagOptionPk = "pk"
+	// TagOptionAuto marks the columns generated by the database, they are not inserted nor updated
+	TagOptionAuto = "auto"
+)
+
+var ErrNoKeyColumns = errors.New("no key columns, tag the key fields with pk or pass the key columns")
+
+// QueryOne returns the row of the query scanned into a T, sql.ErrNoRows when there is no row
+func QueryOne[T any](ctx context.Context, g *Gdbc, query string, args ...interface{}) (T, error) {
+	var dest T
+	err := g.Get(ctx, &dest, query, args...)
+	return dest, err
+}
+
+// QueryAll returns the rows of the query scanned into Ts, an empty slice when there is no row
+func QueryAll[T any](ctx context.Context, g *Gdbc, query string, args ...interface{}) ([]T, error) {
+	dest := make([]T, 0)
+	if err := g.Select(ctx, &dest, query, args...); err != nil {
+		return nil, err
+	}
+	return dest, nil
+}
+
+// NamedQueryAll is QueryAll with the :name parameters of the query bound to the fields of a struct or the keys of a map
+func NamedQueryAll[T any](ctx context.Context, g *Gdbc, query string, arg interface{}) ([]T, error) {
+	query, args, err := Named(g, query, arg)
+	if err != nil {
+		return nil, err
+	}
+	return QueryAll[T](ctx, g, query, args...)
+}
+
+// NamedExec runs the statement with the :name parameters bound to the fields of a struct or the keys of a map
+func NamedExec(ctx context.Context, g *Gdbc, query string, arg interface{}) (sql.Result, error) {
+	query, args, err := Named(g, query, arg)
+	if err != nil {
+		return nil, err
+	}
+	return g.Exec(ctx, query, args...)
+}
+
+// Named binds the :name parameters of the query, the returned query has the placeholders of the driver
+func Named(g *Gdbc, query string, arg interface{}) (string, []interface{}, error) {
+	query, args, err := sqlx.Named(query, arg)
+	if err != nil {
+		return "", nil, err
+	}
+	// slices bound to named parameters expand to IN lists
+	if query, args, err = sqlx.In(query, args...); err != nil {
+		return "", nil, err
+	}
+	return g.Rebind(query), args, nil
+}
+
+// In expands the slice arguments of the ? placeholders, e.g. `WHERE id IN (?)`, the returned query
+// has the placeholders of the driver
+func In(g *Gdbc, query string, args ...interface{}) (string, []interface{}, error) {
+	query, args, err := sqlx.In(query, args...)
+	if err != nil {
+		return "", nil, err
+	}
+	return g.Rebind(query), args, nil
+}
+
+// Insert inserts the columns of the entity into the table, except the auto columns
+func Insert[T any](ctx context.Context, g *Gdbc, table string, entity T) (sql.Result, error) {
+	cols, err := columnsOf(entity)
+	if err != nil {
+		return nil, err
+	}
+
+	names := make([]string, 0, len(cols))
+	args := make([]interface{}, 0, len(cols))
+	for _, c := range cols {
+		if c.auto {
+			continue
+		}
+		names = append(names, c.name)
+		args = append(args, c.value)
+	}
+
+	query := fmt.Sprintf("INSERT INTO %s (%s) VALUES (%s)", table, strings.Join(names, ", "), placeholders(len(names)))
+	return g.Exec(ctx, g.Rebind(query), args...)
+}
+
+// Update sets the columns of the entity in the row of the table having its key columns, the pk columns when
+// no key columns are passed. The key and auto columns are not set.
+func Update[T any](ctx context.Context, g *Gdbc, table string, entity T, keyColumns ...string) (sql.Result, error) {
+	cols, err := columnsOf(entity)
+	if err != nil {
+		return nil, err
+	}
+
+	keys := make(map[string]bool, len(keyColumns))
+	for _, k := range keyColumns {
+		keys[k] = true
+	}
+	if len(keys) == 0 {
+		for _, c := range cols {
+			if c.pk {
+				keys[c.name] = true
+			}
+		}
+	}
+	if len(keys) == 0 {
+		return nil, ErrNoKeyColumns
+	}
+
+	var (
+		sets  []string
+		where []string
+		args  []interface{}
+		wargs []interface{}
+	)
+	for _, c := range cols {
+		switch {
+		case keys[c.name]:
+			where = append(where, c.name+" = ?")
+			wargs = append(wargs, c.value)
+			delete(keys, c.name)
+		case !c.auto:
+			sets = append(sets, c.name+" = ?")
+			args = append(args, c.value)
+		}
+	}
+	for k := range keys {
+		return nil, fmt.Errorf("key column %s is not a column of %T", k, entity)
+	}
+	if len(sets) == 0 {
+		return nil, fmt.Errorf("%T has no columns to update", entity)
+	}
+
+	query := fmt.Sprintf("UPDATE %s SET %s WHERE %s", table, strings.Join(sets, ", "), strings.Join(where, " AND "))
+	return g.Exec(ctx, g.Rebind(query), append(args, wargs...)...)
+}
+
+type column struct {
+	name  string
+	value interface{}
+	pk    bool
+	auto  bool
+}
+
+// columnsOf returns the columns of the exported fields of the struct, the fields of embedded structs included.
+// A field is named by its db tag, its lower cased name otherwise, fields tagged `db:"-"` are skipped.
+func columnsOf(entity interface{}) ([]column, error) {
+	v := reflect.ValueOf(entity)
+	for v.Kind() == reflect.Pointer {
+		if v.IsNil() {
+			return nil, fmt.Errorf("nil %T", entity)
+		}
+		v = v.Elem()
+	}
+	if v.Kind() != reflect.Struct {
+		return nil, fmt.Errorf("%T is not a struct", entity)
+	}
+
+	var cols []column
+	appendColumns(v, &cols)
+	if len(cols) == 0 {
+		return nil, fmt.Errorf("%T has no columns", entity)
+	}
+	return cols, nil
+}
+
+func appendColumns(v reflect.Value, cols *[]column) {
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		tag, hasTag := f.Tag.Lookup(TagName)
+		if tag == "-" {
+			continue
+		}
+
+		if f.Anonymous && !hasTag {
+			fv := v.Field(i)
+			if fv.Kind() == reflect.Pointer {
+				if fv.IsNil() {
+					continue
+				}
+				fv = fv.Elem()
+			}
+			if fv.Kind() == reflect.Struct {
+				appendColumns(fv, cols)
+				continue
+			}
+		}
+
+		if !f.IsExported() {
+			continue
+		}
+
+		parts := strings.Split(tag, ",")
+		c := column{
+			name:  parts[0],
+			value: v.Field(i).Interface(),
+		}
+		if len(c.name) == 0 {
+			c.name = sqlx.NameMapper(f.Name)
+		}
+		for _, o := range parts[1:] {
+			switch strings.TrimSpace(o) {
+			case TagOptionPk:
+				c.pk = true
+			case TagOptionAuto:
+				c.auto = true
+			}
+		}
+		*cols = append(*cols, c)
+	}
+}
+
+func placeholders(n int) string {
+	return strings.TrimSuffix(strings.Repeat("?, ", n), ", ")
+}
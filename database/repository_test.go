@@ -0,0 +1,100 @@
+package database_test
+
+import (
+	"context"
+	"database/sql"
+	"testing"
+
+	"github.com/lengocson131002/go-clean-core/database"
+	"github.com/lengocson131002/go-clean-core/database/sqlx/sqlxtest"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type audit struct {
+	CreatedBy string `db:"created_by"`
+}
+
+type product struct {
+	Id    int64  `db:"id,pk,auto"`
+	Name  string `db:"name"`
+	Price int64
+	audit
+	Cache string `db:"-"`
+}
+
+func newRepositoryGdbc(t *testing.T) *database.Gdbc {
+	return sqlxtest.Open(t, `CREATE TABLE products (id INTEGER PRIMARY KEY AUTOINCREMENT, name TEXT, price INTEGER, created_by TEXT)`)
+}
+
+func TestTypedHelpers(t *testing.T) {
+	g := newRepositoryGdbc(t)
+	ctx := context.Background()
+
+	for _, p := range []product{
+		{Name: "apple", Price: 3, audit: audit{CreatedBy: "alice"}},
+		{Name: "banana", Price: 2, audit: audit{CreatedBy: "bob"}},
+		{Name: "cherry", Price: 9, Cache: "ignored"},
+	} {
+		res, err := database.Insert(ctx, g, "products", p)
+		require.NoError(t, err)
+		rows, _ := res.RowsAffected()
+		assert.Equal(t, int64(1), rows)
+	}
+
+	apple, err := database.QueryOne[product](ctx, g, `SELECT * FROM products WHERE name = ?`, "apple")
+	require.NoError(t, err)
+	assert.Equal(t, product{Id: 1, Name: "apple", Price: 3, audit: audit{CreatedBy: "alice"}}, apple)
+
+	_, err = database.QueryOne[product](ctx, g, `SELECT * FROM products WHERE name = ?`, "durian")
+	assert.ErrorIs(t, err, sql.ErrNoRows)
+
+	apple.Price = 4
+	_, err = database.Update(ctx, g, "products", &apple)
+	require.NoError(t, err)
+
+	prices, err := database.QueryAll[int64](ctx, g, `SELECT price FROM products ORDER BY id`)
+	require.NoError(t, err)
+	assert.Equal(t, []int64{4, 2, 9}, prices)
+
+	none, err := database.QueryAll[product](ctx, g, `SELECT * FROM products WHERE price > ?`, 100)
+	require.NoError(t, err)
+	assert.NotNil(t, none)
+	assert.Empty(t, none)
+}
+
+func TestNamedAndIn(t *testing.T) {
+	g := newRepositoryGdbc(t)
+	ctx := context.Background()
+
+	for _, name := range []string{"apple", "banana", "cherry"} {
+		_, err := database.NamedExec(ctx, g, `INSERT INTO products (name, price) VALUES (:name, :price)`,
+			map[string]interface{}{"name": name, "price": len(name)})
+		require.NoError(t, err)
+	}
+
+	query, args, err := database.In(g, `SELECT name FROM products WHERE id IN (?) AND price > ? ORDER BY id`, []int{1, 3}, 5)
+	require.NoError(t, err)
+	names, err := database.QueryAll[string](ctx, g, query, args...)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"cherry"}, names)
+
+	products, err := database.NamedQueryAll[product](ctx, g, `SELECT id, name, price FROM products WHERE name IN (:names) ORDER BY id`,
+		map[string]interface{}{"names": []string{"apple", "banana"}})
+	require.NoError(t, err)
+	require.Len(t, products, 2)
+	assert.Equal(t, "banana", products[1].Name)
+}
+
+func TestUpdateWithoutKey(t *testing.T) {
+	g := newRepositoryGdbc(t)
+
+	type item struct {
+		Name string `db:"name"`
+	}
+	_, err := database.Update(context.Background(), g, "products", item{Name: "apple"})
+	assert.ErrorIs(t, err, database.ErrNoKeyColumns)
+
+	_, err = database.Update(context.Background(), g, "products", item{Name: "apple"}, "id")
+	assert.Error(t, err)
+}
@@ -0,0 +1,175 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+)
+
+var (
+	DefaultRetryBackoff    = time.Millisecond * 10
+	DefaultMaxRetryBackoff = time.Second
+)
+
+// ErrorClassifier reports whether a transaction failed with an error which may not happen when it runs again,
+// e.g. serialization failures, deadlocks and lock timeouts
+type ErrorClassifier func(err error) bool
+
+var (
+	classifiersMutex sync.RWMutex
+	classifiers      = map[string]ErrorClassifier{
+		"postgres": PostgresRetryable,
+		"pgx":      PostgresRetryable,
+		"mysql":    MySQLRetryable,
+		"godror":   OracleRetryable,
+		"oracle":   OracleRetryable,
+		"sqlite":   SQLiteRetryable,
+		"sqlite3":  SQLiteRetryable,
+	}
+)
+
+// RegisterErrorClassifier sets the classifier of the errors of the driver, it replaces the classifier of a built-in driver
+func RegisterErrorClassifier(driverName string, classifier ErrorClassifier) {
+	classifiersMutex.Lock()
+	defer classifiersMutex.Unlock()
+	classifiers[driverName] = classifier
+}
+
+// GetErrorClassifier returns the classifier of the errors of the driver, errors of unknown drivers are not retryable
+func GetErrorClassifier(driverName string) ErrorClassifier {
+	classifiersMutex.RLock()
+	defer classifiersMutex.RUnlock()
+	if c, ok := classifiers[driverName]; ok {
+		return c
+	}
+	return func(err error) bool { return false }
+}
+
+// PostgresRetryable classifies the errors having a SQLSTATE, e.g. of lib/pq and pgx:
+// serialization_failure (40001), deadlock_detected (40P01) and lock_not_available (55P03)
+func PostgresRetryable(err error) bool {
+	var e interface{ SQLState() string }
+	if !errors.As(err, &e) {
+		return false
+	}
+	switch e.SQLState() {
+	case "40001", "40P01", "55P03":
+		return true
+	}
+	return false
+}
+
+// OracleRetryable classifies the errors having an ORA code, e.g. of godror: cannot serialize access (ORA-08177),
+// deadlock (ORA-00060), resource busy (ORA-00054) and wait timeout (ORA-30006)
+func OracleRetryable(err error) bool {
+	var e interface{ Code() int }
+	if errors.As(err, &e) {
+		switch e.Code() {
+		case 8177, 60, 54, 30006:
+			return true
+		}
+		return false
+	}
+	msg := err.Error()
+	for _, code := range []string{"ORA-08177", "ORA-00060", "ORA-00054", "ORA-30006"} {
+		if strings.Contains(msg, code) {
+			return true
+		}
+	}
+	return false
+}
+
+// mysqlRetryableError matches the number in the message of go-sql-driver/mysql errors, e.g. `Error 1213 (40001): ...`
+var mysqlRetryableError = regexp.MustCompile(`\bError (1213|1205)\b`)
+
+// MySQLRetryable classifies the MySQL errors by their number: deadlock (1213) and lock wait timeout (1205).
+// Errors exposing a `Number() uint16` method are classified by it, the others by their message since
+// go-sql-driver/mysql exposes the number as a field only, the errors may be wrapped.
+func MySQLRetryable(err error) bool {
+	var e interface{ Number() uint16 }
+	if errors.As(err, &e) {
+		switch e.Number() {
+		case 1213, 1205:
+			return true
+		}
+		return false
+	}
+	return mysqlRetryableError.MatchString(err.Error())
+}
+
+// SQLiteRetryable classifies the SQLITE_BUSY and SQLITE_LOCKED errors, including their extended codes
+func SQLiteRetryable(err error) bool {
+	var e interface{ Code() int }
+	if !errors.As(err, &e) {
+		return false
+	}
+	switch e.Code() & 0xff {
+	case 5, 6:
+		return true
+	}
+	return false
+}
+
+// RetryTransaction runs the transaction until it succeeds, its error is not retryable or the attempts of the options
+// are spent, waiting for an exponential backoff between the attempts.
+// The classifier of the options is used when set, the classifier of the driver otherwise.
+func RetryTransaction(ctx context.Context, driverName string, options TxOptions, run func() error) error {
+	attempts := options.MaxAttempts
+	if attempts < 1 {
+		attempts = 1
+	}
+
+	retryable := options.Classifier
+	if retryable == nil {
+		retryable = GetErrorClassifier(driverName)
+	}
+
+	var err error
+	for attempt := 1; ; attempt++ {
+		if err = run(); err == nil || !retryable(err) {
+			return err
+		}
+
+		if attempt >= attempts {
+			return fmt.Errorf("transaction failed after %d attempts: %w", attempt, err)
+		}
+
+		select {
+		case <-time.After(retryBackoff(options, attempt)):
+		case <-ctx.Done():
+			return err
+		}
+	}
+}
+
+// retryBackoff doubles the backoff with each attempt up to the max backoff, with jitter
+// so the conflicting transactions do not run again at once
+func retryBackoff(options TxOptions, attempt int) time.Duration {
+	backoff := options.RetryBackoff
+	if backoff <= 0 {
+		backoff = DefaultRetryBackoff
+	}
+	max := options.MaxRetryBackoff
+	if max <= 0 {
+		max = DefaultMaxRetryBackoff
+	}
+	return exponentialBackoff(backoff, max, attempt)
+}
+
+// exponentialBackoff returns the backoff doubled attempt - 1 times up to max, between half of it and all of it
+func exponentialBackoff(backoff time.Duration, max time.Duration, attempt int) time.Duration {
+	for i := 1; i < attempt && backoff < max; i++ {
+		backoff *= 2
+	}
+	if backoff > max {
+		backoff = max
+	}
+
+	half := backoff / 2
+	return half + time.Duration(rand.Int63n(int64(half)+1))
+}
@@ -0,0 +1,106 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type sqlStateError string
+
+func (e sqlStateError) Error() string    { return "sqlstate " + string(e) }
+func (e sqlStateError) SQLState() string { return string(e) }
+
+type codeError int
+
+func (e codeError) Error() string { return fmt.Sprintf("code %d", int(e)) }
+func (e codeError) Code() int     { return int(e) }
+
+type numberError uint16
+
+func (e numberError) Error() string  { return fmt.Sprintf("number %d", uint16(e)) }
+func (e numberError) Number() uint16 { return uint16(e) }
+
+func TestErrorClassifiers(t *testing.T) {
+	assert.True(t, PostgresRetryable(fmt.Errorf("commit: %w", sqlStateError("40001"))))
+	assert.True(t, PostgresRetryable(sqlStateError("40P01")))
+	assert.False(t, PostgresRetryable(sqlStateError("23505")))
+	assert.False(t, PostgresRetryable(errors.New("40001")))
+
+	assert.True(t, OracleRetryable(codeError(8177)))
+	assert.True(t, OracleRetryable(errors.New("ORA-00060: deadlock detected while waiting for resource")))
+	assert.False(t, OracleRetryable(codeError(1)))
+
+	assert.True(t, MySQLRetryable(errors.New("Error 1213 (40001): Deadlock found when trying to get lock")))
+	assert.True(t, MySQLRetryable(fmt.Errorf("update stock: %w", errors.New("Error 1205: Lock wait timeout exceeded"))))
+	assert.True(t, MySQLRetryable(fmt.Errorf("commit: %w", numberError(1213))))
+	assert.False(t, MySQLRetryable(numberError(1062)))
+	assert.False(t, MySQLRetryable(errors.New("Error 1062 (23000): Duplicate entry")))
+	assert.False(t, MySQLRetryable(errors.New("Error 12130: unknown")))
+
+	// SQLITE_BUSY_SNAPSHOT is an extended code of SQLITE_BUSY
+	assert.True(t, SQLiteRetryable(codeError(517)))
+	assert.False(t, SQLiteRetryable(codeError(19)))
+
+	assert.False(t, GetErrorClassifier("unknown")(sqlStateError("40001")))
+}
+
+func TestRetryTransaction(t *testing.T) {
+	options := NewTxOptions(WithRetry(3), WithRetryBackoff(time.Millisecond, time.Millisecond*2))
+
+	attempts := 0
+	err := RetryTransaction(context.Background(), "postgres", options, func() error {
+		attempts++
+		if attempts < 3 {
+			return sqlStateError("40001")
+		}
+		return nil
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, 3, attempts)
+
+	attempts = 0
+	err = RetryTransaction(context.Background(), "postgres", options, func() error {
+		attempts++
+		return sqlStateError("40P01")
+	})
+	assert.ErrorIs(t, err, sqlStateError("40P01"))
+	assert.Equal(t, 3, attempts)
+
+	// errors which are not retryable fail at once
+	attempts = 0
+	err = RetryTransaction(context.Background(), "postgres", options, func() error {
+		attempts++
+		return sqlStateError("23505")
+	})
+	assert.Error(t, err)
+	assert.Equal(t, 1, attempts)
+}
+
+func TestRetryTransactionCanceled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	options := NewTxOptions(WithRetry(5), WithRetryBackoff(time.Hour, time.Hour))
+
+	attempts := 0
+	err := RetryTransaction(ctx, "postgres", options, func() error {
+		attempts++
+		cancel()
+		return sqlStateError("40001")
+	})
+	assert.ErrorIs(t, err, sqlStateError("40001"))
+	assert.Equal(t, 1, attempts)
+}
+
+func TestRetryBackoff(t *testing.T) {
+	options := NewTxOptions(WithRetryBackoff(time.Millisecond*10, time.Millisecond*50))
+
+	for attempt, max := range map[int]time.Duration{1: 10, 2: 20, 3: 40, 5: 50} {
+		backoff := retryBackoff(options, attempt)
+		assert.GreaterOrEqual(t, backoff, max*time.Millisecond/2)
+		assert.LessOrEqual(t, backoff, max*time.Millisecond)
+	}
+}
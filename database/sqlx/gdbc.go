@@ -1,7 +1,9 @@
 package sqlx
 
 import (
+	"context"
 	"database/sql"
+	"sync/atomic"
 
 	"github.com/jmoiron/sqlx"
 )
@@ -14,6 +16,12 @@ type SqlxDBTx struct {
 // SqlxConnx is the sqlx.Tx based implementation of GDBC
 type SqlxConnTx struct {
 	DB *sqlx.Tx
+
+	// db began the transaction, it begins the transactions requiring a new one
+	db         *sqlx.DB
+	savepoints int64
+	// set when a joined function fails, the transaction is rolled back instead of committed
+	rollbackOnly atomic.Bool
 }
 
 func NewSqlxDBGdbc(db *sqlx.DB) *SqlxDBTx {
@@ -21,7 +29,7 @@ func NewSqlxDBGdbc(db *sqlx.DB) *SqlxDBTx {
 }
 
 func NewSqlxConnGdbc(db *sqlx.Tx) *SqlxConnTx {
-	return &SqlxConnTx{db}
+	return &SqlxConnTx{DB: db}
 }
 
 // Get implements database.SqlGdbc.
@@ -31,7 +39,7 @@ func (s *SqlxDBTx) Get(dest interface{}, query string, args ...interface{}) erro
 
 // Select implements database.SqlGdbc.
 func (s *SqlxDBTx) Select(dest interface{}, query string, args ...interface{}) error {
-	return s.DB.Select(dest, query, args)
+	return s.DB.Select(dest, query, args...)
 }
 
 // Exec implements SqlGdbc.
@@ -46,7 +54,7 @@ func (s *SqlxDBTx) Prepare(query string) (*sql.Stmt, error) {
 
 // Query implements SqlGdbc.
 func (s *SqlxDBTx) Query(query string, args ...interface{}) (*sql.Rows, error) {
-	return s.DB.Query(query, args)
+	return s.DB.Query(query, args...)
 }
 
 // QueryRow implements SqlGdbc.
@@ -66,7 +74,7 @@ func (s *SqlxConnTx) Prepare(query string) (*sql.Stmt, error) {
 
 // Query implements SqlGdbc.
 func (s *SqlxConnTx) Query(query string, args ...interface{}) (*sql.Rows, error) {
-	return s.DB.Query(query, args)
+	return s.DB.Query(query, args...)
 }
 
 // QueryRow implements SqlGdbc.
@@ -81,5 +89,90 @@ func (s *SqlxConnTx) Get(dest interface{}, query string, args ...interface{}) er
 
 // Select implements database.SqlGdbc.
 func (s *SqlxConnTx) Select(dest interface{}, query string, args ...interface{}) error {
-	return s.DB.Select(dest, query, args)
+	return s.DB.Select(dest, query, args...)
+}
+
+// ExecContext implements database.SqlGdbc.
+func (s *SqlxDBTx) ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error) {
+	return s.DB.ExecContext(ctx, query, args...)
+}
+
+// PrepareContext implements database.SqlGdbc.
+func (s *SqlxDBTx) PrepareContext(ctx context.Context, query string) (*sql.Stmt, error) {
+	return s.DB.PrepareContext(ctx, query)
+}
+
+// QueryContext implements database.SqlGdbc.
+func (s *SqlxDBTx) QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error) {
+	return s.DB.QueryContext(ctx, query, args...)
+}
+
+// QueryRowContext implements database.SqlGdbc.
+func (s *SqlxDBTx) QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row {
+	return s.DB.QueryRowContext(ctx, query, args...)
+}
+
+// GetContext implements database.SqlGdbc.
+func (s *SqlxDBTx) GetContext(ctx context.Context, dest interface{}, query string, args ...interface{}) error {
+	return s.DB.GetContext(ctx, dest, query, args...)
+}
+
+// SelectContext implements database.SqlGdbc.
+func (s *SqlxDBTx) SelectContext(ctx context.Context, dest interface{}, query string, args ...interface{}) error {
+	return s.DB.SelectContext(ctx, dest, query, args...)
+}
+
+// ExecContext implements database.SqlGdbc.
+func (s *SqlxConnTx) ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error) {
+	return s.DB.ExecContext(ctx, query, args...)
+}
+
+// PrepareContext implements database.SqlGdbc.
+func (s *SqlxConnTx) PrepareContext(ctx context.Context, query string) (*sql.Stmt, error) {
+	return s.DB.PrepareContext(ctx, query)
+}
+
+// QueryContext implements database.SqlGdbc.
+func (s *SqlxConnTx) QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error) {
+	return s.DB.QueryContext(ctx, query, args...)
+}
+
+// QueryRowContext implements database.SqlGdbc.
+func (s *SqlxConnTx) QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row {
+	return s.DB.QueryRowContext(ctx, query, args...)
+}
+
+// GetContext implements database.SqlGdbc.
+func (s *SqlxConnTx) GetContext(ctx context.Context, dest interface{}, query string, args ...interface{}) error {
+	return s.DB.GetContext(ctx, dest, query, args...)
+}
+
+// SelectContext implements database.SqlGdbc.
+func (s *SqlxConnTx) SelectContext(ctx context.Context, dest interface{}, query string, args ...interface{}) error {
+	return s.DB.SelectContext(ctx, dest, query, args...)
+}
+
+// Rebind implements database.SqlGdbc.
+func (s *SqlxDBTx) Rebind(query string) string {
+	return s.DB.Rebind(query)
+}
+
+// Rebind implements database.SqlGdbc.
+func (s *SqlxConnTx) Rebind(query string) string {
+	return s.DB.Rebind(query)
+}
+
+// PingContext implements database.Pinger.
+func (s *SqlxDBTx) PingContext(ctx context.Context) error {
+	return s.DB.PingContext(ctx)
+}
+
+// Stats implements database.StatsProvider.
+func (s *SqlxDBTx) Stats() sql.DBStats {
+	return s.DB.Stats()
+}
+
+// Close closes the connections of the database.
+func (s *SqlxDBTx) Close() error {
+	return s.DB.Close()
 }
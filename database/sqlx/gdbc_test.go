@@ -0,0 +1,102 @@
+package sqlx_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/lengocson131002/go-clean-core/database"
+	"github.com/lengocson131002/go-clean-core/database/sqlx/sqlxtest"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type account struct {
+	Id      int64  `db:"id"`
+	Name    string `db:"name"`
+	Balance int64  `db:"balance"`
+}
+
+func newTestGdbc(t *testing.T) *database.Gdbc {
+	return sqlxtest.Open(t, `CREATE TABLE accounts (id INTEGER PRIMARY KEY, name TEXT NOT NULL, balance INTEGER NOT NULL)`)
+}
+
+func TestGdbcContext(t *testing.T) {
+	g := newTestGdbc(t)
+	ctx := context.Background()
+
+	for _, a := range []account{{1, "alice", 100}, {2, "bob", 50}, {3, "carol", 10}} {
+		_, err := g.Exec(ctx, `INSERT INTO accounts (id, name, balance) VALUES (?, ?, ?)`, a.Id, a.Name, a.Balance)
+		require.NoError(t, err)
+	}
+
+	var a account
+	require.NoError(t, g.Get(ctx, &a, `SELECT * FROM accounts WHERE id = ?`, 2))
+	assert.Equal(t, account{2, "bob", 50}, a)
+
+	var rich []account
+	require.NoError(t, g.Select(ctx, &rich, `SELECT * FROM accounts WHERE balance >= ? ORDER BY id`, 50))
+	assert.Equal(t, []account{{1, "alice", 100}, {2, "bob", 50}}, rich)
+
+	rows, err := g.Query(ctx, `SELECT name FROM accounts WHERE balance < ? ORDER BY id`, 100)
+	require.NoError(t, err)
+	var names []string
+	for rows.Next() {
+		var name string
+		require.NoError(t, rows.Scan(&name))
+		names = append(names, name)
+	}
+	require.NoError(t, rows.Close())
+	assert.Equal(t, []string{"bob", "carol"}, names)
+
+	var total int64
+	require.NoError(t, g.QueryRow(ctx, `SELECT SUM(balance) FROM accounts`).Scan(&total))
+	assert.Equal(t, int64(160), total)
+
+	stmt, err := g.Prepare(ctx, `UPDATE accounts SET balance = balance + ? WHERE id = ?`)
+	require.NoError(t, err)
+	defer stmt.Close()
+	_, err = stmt.ExecContext(ctx, 5, 3)
+	require.NoError(t, err)
+	require.NoError(t, g.Get(ctx, &a, `SELECT * FROM accounts WHERE id = ?`, 3))
+	assert.Equal(t, int64(15), a.Balance)
+}
+
+func TestGdbcContextCanceled(t *testing.T) {
+	g := newTestGdbc(t)
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	_, err := g.Exec(ctx, `INSERT INTO accounts (id, name, balance) VALUES (?, ?, ?)`, 1, "alice", 100)
+	assert.ErrorIs(t, err, context.Canceled)
+
+	var accounts []account
+	assert.ErrorIs(t, g.Select(ctx, &accounts, `SELECT * FROM accounts`), context.Canceled)
+
+	var count int
+	require.NoError(t, g.Get(context.Background(), &count, `SELECT COUNT(*) FROM accounts`))
+	assert.Equal(t, 0, count)
+}
+
+func TestGdbcContextWithinTransaction(t *testing.T) {
+	g := newTestGdbc(t)
+	ctx := context.Background()
+
+	err := g.Executor.WithinTransaction(ctx, func(ctx context.Context) error {
+		if _, err := g.Exec(ctx, `INSERT INTO accounts (id, name, balance) VALUES (?, ?, ?)`, 1, "alice", 100); err != nil {
+			return err
+		}
+
+		// the queries of the transaction context run on the transaction
+		var a account
+		if err := g.Get(ctx, &a, `SELECT * FROM accounts WHERE id = ?`, 1); err != nil {
+			return err
+		}
+		assert.Equal(t, "alice", a.Name)
+		return nil
+	})
+	require.NoError(t, err)
+
+	var count int
+	require.NoError(t, g.Get(ctx, &count, `SELECT COUNT(*) FROM accounts`))
+	assert.Equal(t, 1, count)
+}
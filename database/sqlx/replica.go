@@ -0,0 +1,59 @@
+package sqlx
+
+import (
+	"context"
+	"errors"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/lengocson131002/go-clean-core/database"
+)
+
+// ReplicatedDatabase connects to a primary and its read replicas, see database.ReplicaRouter
+type ReplicatedDatabase struct {
+	replicaDSNs []string
+	opts        []database.ReplicaOption
+}
+
+// NewReplicatedDatabaseConnector returns the connector of a primary and the replicas of the DSNs,
+// the replicas have the driver and the pool options of the primary
+func NewReplicatedDatabaseConnector(replicaDSNs []string, opts ...database.ReplicaOption) database.Database {
+	return &ReplicatedDatabase{
+		replicaDSNs: replicaDSNs,
+		opts:        opts,
+	}
+}
+
+// Connect connects to the primary of the DSN. An unreachable replica does not fail the connect,
+// it gets reads once it passes a health check.
+func (c *ReplicatedDatabase) Connect(driverName string, dsn string, poolOptions *database.PoolOptions, opts ...database.Option) (*database.Gdbc, error) {
+	primary, err := connect(context.Background(), driverName, dsn, poolOptions, database.NewOptions(opts...))
+	if err != nil {
+		return nil, err
+	}
+
+	replicas := make([]database.SqlGdbc, 0, len(c.replicaDSNs))
+	for _, replicaDSN := range c.replicaDSNs {
+		db, err := sqlx.Open(driverName, replicaDSN)
+		if err != nil {
+			for _, r := range replicas {
+				r.(*SqlxDBTx).DB.Close()
+			}
+			return nil, errors.Join(err, primary.Close())
+		}
+		configurePool(db, poolOptions)
+		replicas = append(replicas, &SqlxDBTx{DB: db})
+	}
+
+	router := database.NewReplicaRouter(&SqlxDBTx{DB: primary}, replicas, c.opts...)
+	return database.NewGdbc(router, opts...), nil
+}
+
+func configurePool(db *sqlx.DB, poolOptions *database.PoolOptions) {
+	if poolOptions == nil {
+		return
+	}
+	db.SetMaxIdleConns(poolOptions.MaxIdleCount)
+	db.SetMaxOpenConns(poolOptions.MaxOpen)
+	db.SetConnMaxLifetime(poolOptions.MaxLifetime)
+	db.SetConnMaxIdleTime(poolOptions.MaxIdleTime)
+}
@@ -0,0 +1,117 @@
+package sqlx
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/lengocson131002/go-clean-core/database"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// newReplicatedGdbc connects to a primary and two replicas, the node table of each names the database
+func newReplicatedGdbc(t *testing.T, opts ...database.ReplicaOption) (*database.Gdbc, []string) {
+	dir := t.TempDir()
+	names := []string{"primary", "replica1", "replica2"}
+	dsns := make([]string, len(names))
+	for i, name := range names {
+		dsns[i] = filepath.Join(dir, name+".db")
+		db, err := sqlx.Connect("sqlite", dsns[i])
+		require.NoError(t, err)
+		_, err = db.Exec(`CREATE TABLE node (name TEXT)`)
+		require.NoError(t, err)
+		_, err = db.Exec(`INSERT INTO node (name) VALUES (?)`, name)
+		require.NoError(t, err)
+		require.NoError(t, db.Close())
+	}
+
+	g, err := NewReplicatedDatabaseConnector(dsns[1:], opts...).Connect("sqlite", dsns[0], nil)
+	require.NoError(t, err)
+	t.Cleanup(func() { g.Executor.(*database.ReplicaRouter).Close() })
+	return g, dsns
+}
+
+func readNode(t *testing.T, ctx context.Context, g *database.Gdbc) string {
+	var name string
+	require.NoError(t, g.Get(ctx, &name, `SELECT name FROM node`))
+	return name
+}
+
+func TestReplicaRouting(t *testing.T) {
+	g, _ := newReplicatedGdbc(t)
+	ctx := context.Background()
+
+	reads := map[string]int{}
+	for i := 0; i < 4; i++ {
+		reads[readNode(t, ctx, g)]++
+	}
+	assert.Equal(t, map[string]int{"replica1": 2, "replica2": 2}, reads)
+
+	assert.Equal(t, "primary", readNode(t, database.WithPrimary(ctx), g))
+
+	// writes and transactions run on the primary
+	_, err := g.Exec(ctx, `UPDATE node SET name = ?`, "written")
+	require.NoError(t, err)
+	assert.Equal(t, "written", readNode(t, database.WithPrimary(ctx), g))
+
+	require.NoError(t, g.WithinTransaction(ctx, func(ctx context.Context) error {
+		assert.Equal(t, "written", readNode(t, ctx, g))
+		return nil
+	}))
+}
+
+func TestReplicaReadYourWrites(t *testing.T) {
+	g, _ := newReplicatedGdbc(t)
+	ctx := database.WithReadYourWrites(context.Background())
+
+	assert.NotEqual(t, "primary", readNode(t, ctx, g))
+
+	_, err := g.Exec(ctx, `UPDATE node SET name = ?`, "written")
+	require.NoError(t, err)
+	for i := 0; i < 2; i++ {
+		assert.Equal(t, "written", readNode(t, ctx, g))
+	}
+
+	// the other contexts still read the replicas
+	assert.NotEqual(t, "written", readNode(t, context.Background(), g))
+}
+
+func TestReplicaLeastConnections(t *testing.T) {
+	g, _ := newReplicatedGdbc(t, database.WithBalancer(database.LeastConnections))
+
+	reads := map[string]int{}
+	for i := 0; i < 4; i++ {
+		reads[readNode(t, context.Background(), g)]++
+	}
+	assert.NotContains(t, reads, "primary")
+	assert.Len(t, reads, 2)
+
+	// open rows hold a connection of their replica until closed, the next reads go to the other replica
+	rows, err := g.Query(context.Background(), `SELECT name FROM node`)
+	require.NoError(t, err)
+	require.True(t, rows.Next())
+	var busy string
+	require.NoError(t, rows.Scan(&busy))
+	for i := 0; i < 3; i++ {
+		assert.NotEqual(t, busy, readNode(t, context.Background(), g))
+	}
+	require.NoError(t, rows.Close())
+}
+
+func TestReplicaEjection(t *testing.T) {
+	g, _ := newReplicatedGdbc(t, database.WithHealthCheck(time.Millisecond*10, time.Second))
+	router := g.Executor.(*database.ReplicaRouter)
+	require.Equal(t, 2, router.HealthyReplicas())
+
+	// a closed replica fails to ping
+	replica := router.Replicas()[0].(*SqlxDBTx)
+	require.NoError(t, replica.DB.Close())
+	require.Eventually(t, func() bool { return router.HealthyReplicas() == 1 }, time.Second, time.Millisecond*5)
+
+	for i := 0; i < 3; i++ {
+		assert.Equal(t, "replica2", readNode(t, context.Background(), g))
+	}
+}
@@ -1,6 +1,8 @@
 package sqlx
 
 import (
+	"context"
+
 	"github.com/jmoiron/sqlx"
 	"github.com/lengocson131002/go-clean-core/database"
 )
@@ -12,27 +14,44 @@ func NewSqlxDatabaseConnector() database.Database {
 	return &SqlxDatabase{}
 }
 
-func (c *SqlxDatabase) Connect(driverName string, dsn string, poolOptions *database.PoolOptions) (*database.Gdbc, error) {
-	db := sqlx.MustConnect(driverName, dsn)
+func (c *SqlxDatabase) Connect(driverName string, dsn string, poolOptions *database.PoolOptions, opts ...database.Option) (*database.Gdbc, error) {
+	return c.ConnectContext(context.Background(), driverName, dsn, poolOptions, opts...)
+}
+
+// ConnectContext connects to the database until the context is done, see database.WithConnectRetry
+// and database.WithConnectTimeout
+func (c *SqlxDatabase) ConnectContext(ctx context.Context, driverName string, dsn string, poolOptions *database.PoolOptions, opts ...database.Option) (*database.Gdbc, error) {
+	options := database.NewOptions(opts...)
 
-	err := db.Ping()
+	db, err := connect(ctx, driverName, dsn, poolOptions, options)
 	if err != nil {
-		if db != nil {
-			err = db.Close()
-		}
 		return nil, err
 	}
 
-	if poolOptions != nil {
-		db.SetMaxIdleConns(poolOptions.MaxIdleCount)
-		db.SetMaxOpenConns(poolOptions.MaxOpen)
-		db.SetConnMaxLifetime(poolOptions.MaxLifetime)
-		db.SetConnMaxIdleTime(poolOptions.MaxIdleTime)
+	return database.NewGdbc(&SqlxDBTx{
+		DB: db,
+	}, opts...), nil
+}
+
+// connect opens the database and pings it with the retries of the options
+func connect(ctx context.Context, driverName string, dsn string, poolOptions *database.PoolOptions, options database.Options) (*sqlx.DB, error) {
+	if options.ConnectTimeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, options.ConnectTimeout)
+		defer cancel()
+	}
+
+	db, err := sqlx.Open(driverName, dsn)
+	if err != nil {
+		return nil, err
+	}
+
+	configurePool(db, poolOptions)
+
+	if err := database.RetryConnect(ctx, options, db.PingContext); err != nil {
+		db.Close()
+		return nil, err
 	}
 
-	return &database.Gdbc{
-		Executor: &SqlxDBTx{
-			DB: db,
-		},
-	}, err
+	return db, nil
 }
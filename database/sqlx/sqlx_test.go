@@ -0,0 +1,61 @@
+package sqlx
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/lengocson131002/go-clean-core/database"
+	"github.com/lengocson131002/go-clean-core/logger"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	_ "modernc.org/sqlite"
+)
+
+type warnLogger struct {
+	logger.Logger
+	warnings []string
+}
+
+func (l *warnLogger) Warnf(ctx context.Context, format string, args ...interface{}) {
+	l.warnings = append(l.warnings, fmt.Sprintf(format, args...))
+}
+
+func TestConnect(t *testing.T) {
+	g, err := NewSqlxDatabaseConnector().Connect("sqlite", filepath.Join(t.TempDir(), "connect.db"), &database.PoolOptions{MaxOpen: 3})
+	require.NoError(t, err)
+
+	assert.NoError(t, g.PingContext(context.Background()))
+	assert.Equal(t, 3, g.Stats().MaxOpenConnections)
+
+	collector := database.NewPoolCollector("connect", g)
+	assert.Equal(t, 9, testutil.CollectAndCount(collector))
+
+	require.NoError(t, g.Close())
+	assert.Error(t, g.PingContext(context.Background()))
+}
+
+func TestConnectFailure(t *testing.T) {
+	// an unknown driver fails without panicking
+	_, err := NewSqlxDatabaseConnector().Connect("unknown", "dsn", nil)
+	assert.Error(t, err)
+
+	unreachable := filepath.Join(t.TempDir(), "missing", "connect.db")
+
+	log := &warnLogger{}
+	_, err = NewSqlxDatabaseConnector().Connect("sqlite", unreachable, nil,
+		database.WithConnectRetry(3, time.Millisecond, time.Millisecond*2),
+		database.WithSlowQueryLog(log, 0))
+	assert.ErrorContains(t, err, "connect failed after 3 attempts")
+	assert.Len(t, log.warnings, 2)
+
+	start := time.Now()
+	_, err = NewSqlxDatabaseConnector().Connect("sqlite", unreachable, nil,
+		database.WithConnectRetry(100, time.Millisecond*10, time.Millisecond*10),
+		database.WithConnectTimeout(time.Millisecond*50))
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+	assert.Less(t, time.Since(start), time.Second)
+}
@@ -0,0 +1,40 @@
+// Package sqlxtest provides the SQLite databases of the tests of the packages built on database.Gdbc
+package sqlxtest
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/lengocson131002/go-clean-core/database"
+	gsqlx "github.com/lengocson131002/go-clean-core/database/sqlx"
+	"github.com/stretchr/testify/require"
+	_ "modernc.org/sqlite"
+)
+
+// DriverName is the driver of the databases of Open
+const DriverName = "sqlite"
+
+// Open opens a SQLite database in a temporary directory of the test, runs the schema statements and returns its Gdbc.
+// The database is closed when the test ends.
+func Open(t testing.TB, schema ...string) *database.Gdbc {
+	t.Helper()
+	return OpenWith(t, nil, schema...)
+}
+
+// OpenWith is Open building the Gdbc with the options
+func OpenWith(t testing.TB, opts []database.Option, schema ...string) *database.Gdbc {
+	t.Helper()
+
+	db, err := sqlx.Open(DriverName, filepath.Join(t.TempDir(), "test.db"))
+	require.NoError(t, err)
+	t.Cleanup(func() { db.Close() })
+
+	g := database.NewGdbc(gsqlx.NewSqlxDBGdbc(db), opts...)
+	for _, stmt := range schema {
+		_, err := g.Exec(context.Background(), stmt)
+		require.NoError(t, err)
+	}
+	return g
+}
@@ -3,31 +3,46 @@ package sqlx
 import (
 	"context"
 	"database/sql"
+	"errors"
 	"fmt"
+	"sync/atomic"
 
-	"github.com/jmoiron/sqlx"
 	"github.com/lengocson131002/go-clean-core/database"
 )
 
-func (sdt *SqlxDBTx) WithinTransaction(ctx context.Context, txFunc func(ctx context.Context) error) error {
-	return sdt.WithinTransactionOptions(ctx, txFunc, nil)
+var ErrNoParentDB = errors.New("transaction has no database to begin a new transaction, see NewSqlxConnGdbc")
+
+// WithinTransaction runs the function within a transaction, see database.Propagation
+func (sdt *SqlxDBTx) WithinTransaction(ctx context.Context, txFunc func(ctx context.Context) error, opts ...database.TxOption) error {
+	return sdt.WithinTransactionOptions(ctx, txFunc, nil, opts...)
 }
 
-func (sdt *SqlxDBTx) WithinTransactionOptions(ctx context.Context, txFunc func(ctx context.Context) error, txOptions *sql.TxOptions) error {
-	var err error
-	var tx *sqlx.Tx
+func (sdt *SqlxDBTx) WithinTransactionOptions(ctx context.Context, txFunc func(ctx context.Context) error, txOptions *sql.TxOptions, opts ...database.TxOption) error {
+	options := database.NewTxOptions(opts...)
 
-	if txOptions != nil {
-		tx, err = sdt.DB.BeginTxx(ctx, txOptions)
-	} else {
-		tx, err = sdt.DB.Beginx()
+	// join the transaction of the context, it decides how to propagate
+	if tx := database.ExtractTx(ctx); tx != nil && options.Propagation != database.PropagationRequiresNew {
+		return tx.WithinTransactionOptions(ctx, txFunc, txOptions, opts...)
 	}
 
+	return sdt.beginWithRetry(ctx, txFunc, txOptions, options)
+}
+
+func (sdt *SqlxDBTx) beginWithRetry(ctx context.Context, txFunc func(ctx context.Context) error, txOptions *sql.TxOptions, options database.TxOptions) error {
+	return database.RetryTransaction(ctx, sdt.DB.DriverName(), options, func() error {
+		return sdt.begin(ctx, txFunc, txOptions)
+	})
+}
+
+// begin runs the function in a new transaction, the transaction is committed when the function succeeds
+// and rolled back when it fails or panics
+func (sdt *SqlxDBTx) begin(ctx context.Context, txFunc func(ctx context.Context) error, txOptions *sql.TxOptions) (err error) {
+	tx, err := sdt.DB.BeginTxx(ctx, txOptions)
 	if err != nil {
 		return fmt.Errorf("begin transaction: %w", err)
 	}
 
-	sct := &SqlxConnTx{tx}
+	sct := &SqlxConnTx{DB: tx, db: sdt.DB}
 
 	defer func() {
 		if p := recover(); p != nil {
@@ -35,33 +50,101 @@ func (sdt *SqlxDBTx) WithinTransactionOptions(ctx context.Context, txFunc func(c
 			panic(p)
 		} else if err != nil {
 			tx.Rollback()
-		} else {
-			err = tx.Commit()
+		} else if sct.rollbackOnly.Load() {
+			tx.Rollback()
+			err = database.ErrRollbackOnly
+		} else if err = tx.Commit(); err != nil {
+			err = fmt.Errorf("commit transaction: %w", err)
 		}
 	}()
 
-	err = txFunc(database.InjectTx(ctx, sct))
-	return err
+	return txFunc(database.InjectTx(ctx, sct))
+}
+
+// WithinTransaction runs the function within the transaction, see database.Propagation.
+// The transaction is committed or rolled back by the function which began it.
+func (sct *SqlxConnTx) WithinTransaction(ctx context.Context, txFunc func(ctx context.Context) error, opts ...database.TxOption) error {
+	return sct.WithinTransactionOptions(ctx, txFunc, nil, opts...)
 }
 
-func (sct *SqlxConnTx) WithinTransaction(ctx context.Context, txFunc func(ctx context.Context) error) error {
-	return sct.WithinTransactionOptions(ctx, txFunc, nil)
+func (sct *SqlxConnTx) WithinTransactionOptions(ctx context.Context, txFunc func(ctx context.Context) error, txOptions *sql.TxOptions, opts ...database.TxOption) error {
+	options := database.NewTxOptions(opts...)
+
+	switch options.Propagation {
+	case database.PropagationRequiresNew:
+		if sct.db == nil {
+			return ErrNoParentDB
+		}
+		return (&SqlxDBTx{DB: sct.db}).beginWithRetry(ctx, txFunc, txOptions, options)
+	case database.PropagationNested:
+		return sct.savepoint(ctx, txFunc)
+	default:
+		if err := txFunc(database.InjectTx(ctx, sct)); err != nil {
+			sct.rollbackOnly.Store(true)
+			return err
+		}
+		return nil
+	}
 }
 
-func (sct *SqlxConnTx) WithinTransactionOptions(ctx context.Context, txFunc func(ctx context.Context) error, txOptions *sql.TxOptions) error {
-	var err error
-	tx := sct.DB
+// savepoint runs the function in a savepoint of the transaction, the savepoint is released when the function
+// succeeds and rolled back when it fails or panics
+func (sct *SqlxConnTx) savepoint(ctx context.Context, txFunc func(ctx context.Context) error) (err error) {
+	stmts := savepointStatements(sct.DB.DriverName())
+	name := fmt.Sprintf("sp_%d", atomic.AddInt64(&sct.savepoints, 1))
+
+	if _, err = sct.DB.ExecContext(ctx, fmt.Sprintf(stmts.create, name)); err != nil {
+		return fmt.Errorf("create savepoint: %w", err)
+	}
+
+	// roll back even when the context is canceled, the outer transaction stays usable.
+	// The failures of the functions joined within the savepoint are rolled back with it.
+	rollbackOnly := sct.rollbackOnly.Load()
+	rollback := func() {
+		sct.DB.ExecContext(context.WithoutCancel(ctx), fmt.Sprintf(stmts.rollback, name))
+		sct.rollbackOnly.Store(rollbackOnly)
+	}
+
 	defer func() {
 		if p := recover(); p != nil {
-			tx.Rollback()
+			rollback()
 			panic(p)
 		} else if err != nil {
-			tx.Rollback()
-		} else {
-			err = tx.Commit()
+			rollback()
+		} else if len(stmts.release) > 0 {
+			if _, err = sct.DB.ExecContext(ctx, fmt.Sprintf(stmts.release, name)); err != nil {
+				err = fmt.Errorf("release savepoint: %w", err)
+			}
 		}
 	}()
 
-	err = txFunc(database.InjectTx(ctx, sct))
-	return err
+	return txFunc(database.InjectTx(ctx, sct))
+}
+
+type savepointStmts struct {
+	create   string
+	rollback string
+	release  string
+}
+
+// savepointStatements returns the savepoint statements of the driver, Oracle and SQL Server do not release savepoints
+func savepointStatements(driverName string) savepointStmts {
+	switch driverName {
+	case "sqlserver", "mssql":
+		return savepointStmts{
+			create:   "SAVE TRANSACTION %s",
+			rollback: "ROLLBACK TRANSACTION %s",
+		}
+	case "godror", "oracle", "oci8":
+		return savepointStmts{
+			create:   "SAVEPOINT %s",
+			rollback: "ROLLBACK TO SAVEPOINT %s",
+		}
+	default:
+		return savepointStmts{
+			create:   "SAVEPOINT %s",
+			rollback: "ROLLBACK TO SAVEPOINT %s",
+			release:  "RELEASE SAVEPOINT %s",
+		}
+	}
 }
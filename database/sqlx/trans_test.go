@@ -0,0 +1,176 @@
+package sqlx_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/lengocson131002/go-clean-core/database"
+	gsqlx "github.com/lengocson131002/go-clean-core/database/sqlx"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var errTest = errors.New("test")
+
+func insertAccount(ctx context.Context, g *database.Gdbc, id int64, name string) error {
+	_, err := g.Exec(ctx, `INSERT INTO accounts (id, name, balance) VALUES (?, ?, 0)`, id, name)
+	return err
+}
+
+func accountNames(t *testing.T, g *database.Gdbc) []string {
+	var names []string
+	require.NoError(t, g.Select(context.Background(), &names, `SELECT name FROM accounts ORDER BY id`))
+	return names
+}
+
+func TestTransactionRequired(t *testing.T) {
+	g := newTestGdbc(t)
+
+	err := g.WithinTransaction(context.Background(), func(ctx context.Context) error {
+		require.NoError(t, insertAccount(ctx, g, 1, "alice"))
+
+		// the inner function joins the transaction, it does not commit it
+		require.NoError(t, g.WithinTransaction(ctx, func(ctx context.Context) error {
+			return insertAccount(ctx, g, 2, "bob")
+		}))
+
+		return errTest
+	})
+	assert.ErrorIs(t, err, errTest)
+	assert.Empty(t, accountNames(t, g))
+}
+
+func TestTransactionRequiredRollbackOnly(t *testing.T) {
+	g := newTestGdbc(t)
+
+	err := g.WithinTransaction(context.Background(), func(ctx context.Context) error {
+		require.NoError(t, insertAccount(ctx, g, 1, "alice"))
+
+		// the error of the joined function is ignored, the transaction is rolled back anyway
+		assert.ErrorIs(t, g.WithinTransaction(ctx, func(ctx context.Context) error {
+			return errTest
+		}), errTest)
+		return nil
+	})
+	assert.ErrorIs(t, err, database.ErrRollbackOnly)
+	assert.Empty(t, accountNames(t, g))
+
+	// the failure of a function joined within a savepoint is rolled back with the savepoint
+	err = g.WithinTransaction(context.Background(), func(ctx context.Context) error {
+		require.NoError(t, insertAccount(ctx, g, 1, "alice"))
+
+		assert.ErrorIs(t, g.WithinTransaction(ctx, func(ctx context.Context) error {
+			require.NoError(t, insertAccount(ctx, g, 2, "bob"))
+			return g.WithinTransaction(ctx, func(ctx context.Context) error {
+				return errTest
+			})
+		}, database.WithPropagation(database.PropagationNested)), errTest)
+		return nil
+	})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"alice"}, accountNames(t, g))
+}
+
+func TestTransactionNested(t *testing.T) {
+	g := newTestGdbc(t)
+
+	err := g.WithinTransaction(context.Background(), func(ctx context.Context) error {
+		require.NoError(t, insertAccount(ctx, g, 1, "alice"))
+
+		err := g.WithinTransaction(ctx, func(ctx context.Context) error {
+			require.NoError(t, insertAccount(ctx, g, 2, "bob"))
+			return errTest
+		}, database.WithPropagation(database.PropagationNested))
+		assert.ErrorIs(t, err, errTest)
+
+		require.NoError(t, g.WithinTransaction(ctx, func(ctx context.Context) error {
+			return insertAccount(ctx, g, 3, "carol")
+		}, database.WithPropagation(database.PropagationNested)))
+
+		return nil
+	})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"alice", "carol"}, accountNames(t, g))
+}
+
+func TestTransactionNestedPanic(t *testing.T) {
+	g := newTestGdbc(t)
+
+	err := g.WithinTransaction(context.Background(), func(ctx context.Context) error {
+		require.NoError(t, insertAccount(ctx, g, 1, "alice"))
+
+		assert.Panics(t, func() {
+			g.WithinTransaction(ctx, func(ctx context.Context) error {
+				insertAccount(ctx, g, 2, "bob")
+				panic("nested")
+			}, database.WithPropagation(database.PropagationNested))
+		})
+
+		return nil
+	})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"alice"}, accountNames(t, g))
+}
+
+func TestTransactionRequiresNew(t *testing.T) {
+	g := newTestGdbc(t)
+
+	err := g.WithinTransaction(context.Background(), func(ctx context.Context) error {
+		// the new transaction commits independently of the outer one
+		require.NoError(t, g.WithinTransaction(ctx, func(ctx context.Context) error {
+			return insertAccount(ctx, g, 1, "alice")
+		}, database.WithPropagation(database.PropagationRequiresNew)))
+
+		return errTest
+	})
+	assert.ErrorIs(t, err, errTest)
+	assert.Equal(t, []string{"alice"}, accountNames(t, g))
+}
+
+func TestTransactionCommitError(t *testing.T) {
+	g := newTestGdbc(t)
+
+	err := g.WithinTransaction(context.Background(), func(ctx context.Context) error {
+		// committing the transaction inside the function makes the final commit fail
+		return database.ExtractTx(ctx).(*gsqlx.SqlxConnTx).DB.Commit()
+	})
+	assert.Error(t, err)
+}
+
+func TestTransactionRetry(t *testing.T) {
+	g := newTestGdbc(t)
+	retryable := func(err error) bool { return errors.Is(err, errTest) }
+
+	attempts := 0
+	err := g.WithinTransaction(context.Background(), func(ctx context.Context) error {
+		attempts++
+		if err := insertAccount(ctx, g, 1, "alice"); err != nil {
+			return err
+		}
+		// the failed attempts are rolled back, the insert does not conflict
+		if attempts < 3 {
+			return errTest
+		}
+		return nil
+	}, database.WithRetry(3), database.WithRetryBackoff(time.Millisecond, time.Millisecond), database.WithErrorClassifier(retryable))
+
+	require.NoError(t, err)
+	assert.Equal(t, 3, attempts)
+	assert.Equal(t, []string{"alice"}, accountNames(t, g))
+}
+
+func TestTransactionBeginCanceled(t *testing.T) {
+	g := newTestGdbc(t)
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	called := false
+	err := g.WithinTransaction(ctx, func(ctx context.Context) error {
+		called = true
+		return nil
+	})
+	assert.ErrorIs(t, err, context.Canceled)
+	assert.False(t, called)
+}
@@ -3,11 +3,103 @@ package database
 import (
 	"context"
 	"database/sql"
+	"errors"
+	"time"
 )
 
+// ErrRollbackOnly is returned by the function beginning a transaction when it succeeded but a joined function failed,
+// the transaction is rolled back instead of committed
+var ErrRollbackOnly = errors.New("transaction rolled back, a joined function failed")
+
+// Propagation selects how a transaction relates to the transaction already in the context
+type Propagation int
+
+const (
+	// PropagationRequired joins the transaction of the context, or begins a new one when there is none.
+	// Only the function beginning the transaction commits it. An error of a joined function marks the transaction
+	// rollback-only, it is rolled back even when the beginning function ignores the error, which then gets ErrRollbackOnly.
+	PropagationRequired Propagation = iota
+	// PropagationRequiresNew always begins a new transaction, independent of the transaction of the context
+	PropagationRequiresNew
+	// PropagationNested runs in a savepoint of the transaction of the context, an error rolls back to the savepoint
+	// and leaves the outer transaction usable. It begins a new transaction when there is none.
+	PropagationNested
+)
+
+func (p Propagation) String() string {
+	switch p {
+	case PropagationRequired:
+		return "required"
+	case PropagationRequiresNew:
+		return "requires_new"
+	case PropagationNested:
+		return "nested"
+	default:
+		return "unknown"
+	}
+}
+
+type TxOptions struct {
+	Propagation Propagation
+
+	// MaxAttempts runs a new transaction again when it fails with a retryable error, see RetryTransaction.
+	// Joined transactions and savepoints are never retried, the transaction which began them is.
+	MaxAttempts     int
+	RetryBackoff    time.Duration
+	MaxRetryBackoff time.Duration
+	Classifier      ErrorClassifier
+}
+
+type TxOption func(*TxOptions)
+
+// WithPropagation sets the propagation of the transaction, PropagationRequired by default
+func WithPropagation(p Propagation) TxOption {
+	return func(o *TxOptions) {
+		o.Propagation = p
+	}
+}
+
+// WithRetry runs the transaction up to maxAttempts times while it fails with a retryable error.
+// The function of the transaction may run several times, it must not have side effects outside the transaction.
+func WithRetry(maxAttempts int) TxOption {
+	return func(o *TxOptions) {
+		o.MaxAttempts = maxAttempts
+	}
+}
+
+// WithRetryBackoff sets the backoff before the second attempt, it doubles with each attempt up to max
+func WithRetryBackoff(backoff time.Duration, max time.Duration) TxOption {
+	return func(o *TxOptions) {
+		o.RetryBackoff = backoff
+		o.MaxRetryBackoff = max
+	}
+}
+
+// WithErrorClassifier classifies the retryable errors instead of the classifier of the driver
+func WithErrorClassifier(classifier ErrorClassifier) TxOption {
+	return func(o *TxOptions) {
+		o.Classifier = classifier
+	}
+}
+
+func NewTxOptions(opts ...TxOption) TxOptions {
+	options := TxOptions{
+		Propagation: PropagationRequired,
+		MaxAttempts: 1,
+	}
+
+	for _, o := range opts {
+		o(&options)
+	}
+
+	return options
+}
+
+// Transactor runs functions within transactions, the transaction is injected into the context of the function.
+// The sql.TxOptions only apply when a new transaction begins.
 type Transactor interface {
-	WithinTransaction(ctx context.Context, txFunc func(ctx context.Context) error) error
-	WithinTransactionOptions(ctx context.Context, txFunc func(ctx context.Context) error, txOption *sql.TxOptions) error
+	WithinTransaction(ctx context.Context, txFunc func(ctx context.Context) error, opts ...TxOption) error
+	WithinTransactionOptions(ctx context.Context, txFunc func(ctx context.Context) error, txOption *sql.TxOptions, opts ...TxOption) error
 }
 
 type TxKey struct{}
@@ -24,6 +116,6 @@ func ExtractTx(ctx context.Context) SqlGdbc {
 }
 
 type EnableTransactor interface {
-	WithinTransaction(ctx context.Context, txFunc func(ctx context.Context) error) error
-	WithinTransactionOptions(ctx context.Context, txFunc func(ctx context.Context) error, txOption *sql.TxOptions) error
+	WithinTransaction(ctx context.Context, txFunc func(ctx context.Context) error, opts ...TxOption) error
+	WithinTransactionOptions(ctx context.Context, txFunc func(ctx context.Context) error, txOption *sql.TxOptions, opts ...TxOption) error
 }
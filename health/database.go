@@ -0,0 +1,59 @@
+package healthchecks
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+const (
+	DEFAULT_DB_PING_TIMEOUT = time.Second
+)
+
+// DatabasePinger is implemented by database.Gdbc and *sql.DB
+type DatabasePinger interface {
+	PingContext(ctx context.Context) error
+}
+
+type DatabaseChecker struct {
+	db      DatabasePinger
+	timeout time.Duration
+}
+
+// NewDatabaseChecker returns a checker failing when the database does not answer a ping within the timeout
+func NewDatabaseChecker(db DatabasePinger, timeout time.Duration) *DatabaseChecker {
+	if timeout <= 0 {
+		timeout = DEFAULT_DB_PING_TIMEOUT
+	}
+
+	return &DatabaseChecker{
+		db:      db,
+		timeout: timeout,
+	}
+}
+
+// Check implements HealthCheckHandler.
+func (dc *DatabaseChecker) Check(name string) Integration {
+	var (
+		start        = time.Now()
+		status       = true
+		errorMessage = ""
+	)
+
+	ctx, cancel := context.WithTimeout(context.Background(), dc.timeout)
+	defer cancel()
+
+	if err := dc.db.PingContext(ctx); err != nil {
+		status = false
+		errorMessage = fmt.Sprintf("database ping failed: %s", err)
+	}
+
+	return Integration{
+		Name:         name,
+		Status:       status,
+		ResponseTime: time.Since(start).Nanoseconds(),
+		Error:        errorMessage,
+	}
+}
+
+var _ HealthCheckHandler = (*DatabaseChecker)(nil)
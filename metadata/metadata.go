@@ -0,0 +1,72 @@
+// Package metadata carries request scoped key-values, such as the correlation id or the tenant,
+// through the context so they can be propagated to outgoing messages and requests.
+package metadata
+
+import (
+	"context"
+)
+
+const (
+	CorrelationIdKey = "correlationId"
+	CausationIdKey   = "causationId"
+	TenantIdKey      = "tenantId"
+	SourceKey        = "source"
+)
+
+type Metadata map[string]string
+
+type metadataKey struct{}
+
+// Copy returns a copy of the metadata
+func (md Metadata) Copy() Metadata {
+	copied := make(Metadata, len(md))
+	for k, v := range md {
+		copied[k] = v
+	}
+	return copied
+}
+
+func (md Metadata) Get(key string) (string, bool) {
+	v, ok := md[key]
+	return v, ok
+}
+
+func (md Metadata) Set(key, value string) {
+	md[key] = value
+}
+
+func FromContext(ctx context.Context) (Metadata, bool) {
+	md, ok := ctx.Value(metadataKey{}).(Metadata)
+	return md, ok
+}
+
+// NewContext returns a context carrying a copy of the metadata, later changes of md are not visible
+func NewContext(ctx context.Context, md Metadata) context.Context {
+	return context.WithValue(ctx, metadataKey{}, md.Copy())
+}
+
+// Get returns the value of the key in the metadata of the context
+func Get(ctx context.Context, key string) (string, bool) {
+	md, ok := FromContext(ctx)
+	if !ok {
+		return "", false
+	}
+	return md.Get(key)
+}
+
+// MergeContext returns a context carrying the metadata of ctx merged with md, values of md win when overwrite is set
+func MergeContext(ctx context.Context, md Metadata, overwrite bool) context.Context {
+	merged, ok := FromContext(ctx)
+	if !ok {
+		return NewContext(ctx, md)
+	}
+
+	merged = merged.Copy()
+	for k, v := range md {
+		if _, exists := merged[k]; exists && !overwrite {
+			continue
+		}
+		merged[k] = v
+	}
+	return context.WithValue(ctx, metadataKey{}, merged)
+}
@@ -0,0 +1,169 @@
+// Package pagination defines the page requests and pages of the offset and keyset paginations,
+// shared by the query builders and the transports
+package pagination
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+)
+
+var (
+	DefaultPageSize = 20
+	MaxPageSize     = 1000
+
+	ErrInvalidCursor = errors.New("invalid cursor")
+)
+
+// PageRequest requests a page of the offset pagination, pages start from 1
+type PageRequest struct {
+	Page int
+	Size int
+	// WithTotal counts the rows of all the pages
+	WithTotal bool
+}
+
+// Normalize returns the request with the first page and the default size when not set, the size is at most MaxPageSize
+func (r PageRequest) Normalize() PageRequest {
+	if r.Page < 1 {
+		r.Page = 1
+	}
+	r.Size = normalizeSize(r.Size)
+	return r
+}
+
+// Offset returns the rows skipped before the page
+func (r PageRequest) Offset() int {
+	r = r.Normalize()
+	return (r.Page - 1) * r.Size
+}
+
+// Page is a page of the offset pagination
+type Page[T any] struct {
+	Items []T
+	Page  int
+	Size  int
+	// Total is the rows of all the pages, nil when not counted
+	Total   *int64
+	HasNext bool
+}
+
+// TotalPages returns the number of pages, false when the rows were not counted
+func (p Page[T]) TotalPages() (int64, bool) {
+	if p.Total == nil || p.Size <= 0 {
+		return 0, false
+	}
+	return (*p.Total + int64(p.Size) - 1) / int64(p.Size), true
+}
+
+// CursorRequest requests the page after the cursor of the keyset pagination, the first page without cursor
+type CursorRequest struct {
+	Cursor string
+	Size   int
+}
+
+func (r CursorRequest) Normalize() CursorRequest {
+	r.Size = normalizeSize(r.Size)
+	return r
+}
+
+// CursorPage is a page of the keyset pagination
+type CursorPage[T any] struct {
+	Items []T
+	Size  int
+	// NextCursor requests the next page, empty when there is no next page
+	NextCursor string
+	HasNext    bool
+}
+
+// Keyset orders the rows of the keyset pagination by the columns, the columns must identify a row,
+// e.g. created_at and id. The key of an item returns the values of the columns.
+type Keyset[T any] struct {
+	Columns []string
+	Desc    bool
+	Key     func(item T) []interface{}
+	// Types are the types of the column values, the values of a cursor are decoded to them.
+	// When not set, they are the types of the key of a zero item, a pointer to a zero value for pointer items.
+	Types []reflect.Type
+}
+
+// Cursor returns the opaque cursor of the rows after the item
+func (k Keyset[T]) Cursor(item T) (string, error) {
+	key := k.Key(item)
+	if len(key) != len(k.Columns) {
+		return "", fmt.Errorf("key of %d values for %d columns", len(key), len(k.Columns))
+	}
+
+	b, err := json.Marshal(key)
+	if err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+// After returns the values of the columns of the cursor, typed as the values of the key
+func (k Keyset[T]) After(cursor string) ([]interface{}, error) {
+	b, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidCursor, err)
+	}
+
+	var raw []json.RawMessage
+	if err := json.Unmarshal(b, &raw); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidCursor, err)
+	}
+	if len(raw) != len(k.Columns) {
+		return nil, fmt.Errorf("%w: %d values for %d columns", ErrInvalidCursor, len(raw), len(k.Columns))
+	}
+
+	types := k.types()
+
+	values := make([]interface{}, len(raw))
+	for i, r := range raw {
+		if i >= len(types) || types[i] == nil {
+			if err := json.Unmarshal(r, &values[i]); err != nil {
+				return nil, fmt.Errorf("%w: %v", ErrInvalidCursor, err)
+			}
+			continue
+		}
+
+		v := reflect.New(types[i])
+		if err := json.Unmarshal(r, v.Interface()); err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrInvalidCursor, err)
+		}
+		values[i] = v.Elem().Interface()
+	}
+	return values, nil
+}
+
+// types returns the Types, or the types of the key of a zero item
+func (k Keyset[T]) types() []reflect.Type {
+	if k.Types != nil {
+		return k.Types
+	}
+
+	// the key of a nil pointer item would dereference it
+	var zero T
+	if t := reflect.TypeOf(zero); t != nil && t.Kind() == reflect.Pointer {
+		zero = reflect.New(t.Elem()).Interface().(T)
+	}
+
+	key := k.Key(zero)
+	types := make([]reflect.Type, len(key))
+	for i, v := range key {
+		types[i] = reflect.TypeOf(v)
+	}
+	return types
+}
+
+func normalizeSize(size int) int {
+	if size <= 0 {
+		return DefaultPageSize
+	}
+	if size > MaxPageSize {
+		return MaxPageSize
+	}
+	return size
+}
@@ -33,6 +33,8 @@ type DatabaseTraceFinishOption func(*DatabaseTraceFinishOptions)
 
 type DatabaseTraceFinishOptions struct {
 	Error error
+	// RowsAffected is negative when unknown
+	RowsAffected int64
 }
 
 type DatabaseTraceFinishFunc func(context.Context, ...DatabaseTraceFinishOption)
@@ -42,3 +44,9 @@ func WithDBError(err error) DatabaseTraceFinishOption {
 		options.Error = err
 	}
 }
+
+func WithDBRowsAffected(rows int64) DatabaseTraceFinishOption {
+	return func(options *DatabaseTraceFinishOptions) {
+		options.RowsAffected = rows
+	}
+}
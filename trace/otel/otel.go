@@ -139,12 +139,23 @@ func (*openTelemetryTracer) StartDatabaseTrace(ctx context.Context, spanName str
 	)
 
 	return ctx, func(ctx context.Context, opts ...trace.DatabaseTraceFinishOption) {
-		options := trace.DatabaseTraceFinishOptions{}
+		options := trace.DatabaseTraceFinishOptions{
+			RowsAffected: -1,
+		}
 		for _, opt := range opts {
 			opt(&options)
 		}
 
 		if span := oteltrace.SpanFromContext(ctx); span != nil {
+			if options.RowsAffected >= 0 {
+				span.SetAttributes(attribute.Int64("db.rows_affected", options.RowsAffected))
+			}
+
+			if options.Error != nil {
+				span.RecordError(options.Error)
+				span.SetStatus(codes.Error, fmt.Sprintf("Error: %v", options.Error))
+			}
+
 			span.End()
 		}
 	}
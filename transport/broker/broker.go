@@ -22,6 +22,11 @@ type Broker interface {
 // message and optional Ack method to acknowledge receipt of the message.
 type Handler func(context.Context, Event) error
 
+const (
+	// MessageIdHeader is the header carrying the unique id of a message
+	MessageIdHeader = "messageId"
+)
+
 // Message is a message send/received from the broker.
 type Message struct {
 	Headers map[string]string
@@ -0,0 +1,99 @@
+package claimcheck
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+
+	"github.com/google/uuid"
+	"github.com/lengocson131002/go-clean-core/transport/broker"
+)
+
+const (
+	// ReferenceHeader carries the store key of the payload of a claim-checked message
+	ReferenceHeader = "claimCheck"
+	// SizeHeader carries the size of the stored payload
+	SizeHeader = "claimCheckSize"
+)
+
+// ClaimCheck stores message bodies larger than the threshold and publishes a reference header instead.
+// Subscribers resolve the reference and get the original body.
+type ClaimCheck struct {
+	store     Store
+	threshold int
+}
+
+func New(store Store, threshold int) *ClaimCheck {
+	return &ClaimCheck{
+		store:     store,
+		threshold: threshold,
+	}
+}
+
+// PublishMiddleware replaces the body of oversized messages with a reference to the stored payload
+func (c *ClaimCheck) PublishMiddleware() broker.PublishMiddleware {
+	return func(next broker.PublishFunc) broker.PublishFunc {
+		return func(ctx context.Context, topic string, m *broker.Message, opts ...broker.PublishOption) error {
+			if m == nil || len(m.Body) <= c.threshold {
+				return next(ctx, topic, m, opts...)
+			}
+
+			key := uuid.New().String()
+			if err := c.store.Put(ctx, key, m.Body); err != nil {
+				return fmt.Errorf("failed to store payload of message to %s: %w", topic, err)
+			}
+
+			headers := make(map[string]string, len(m.Headers)+2)
+			for k, v := range m.Headers {
+				headers[k] = v
+			}
+			headers[ReferenceHeader] = key
+			headers[SizeHeader] = strconv.Itoa(len(m.Body))
+
+			err := next(ctx, topic, &broker.Message{Headers: headers}, opts...)
+			if err != nil {
+				c.store.Delete(ctx, key)
+				return err
+			}
+
+			// keep generated headers, e.g. the correlation id, visible to the caller
+			for k, v := range headers {
+				if _, ok := m.Headers[k]; !ok && k != ReferenceHeader && k != SizeHeader {
+					if m.Headers == nil {
+						m.Headers = make(map[string]string)
+					}
+					m.Headers[k] = v
+				}
+			}
+			return nil
+		}
+	}
+}
+
+// SubscribeMiddleware loads the payload of claim-checked messages before calling the handler
+func (c *ClaimCheck) SubscribeMiddleware() broker.SubscribeMiddleware {
+	return func(next broker.Handler) broker.Handler {
+		return func(ctx context.Context, e broker.Event) error {
+			m := e.Message()
+			if m == nil {
+				return next(ctx, e)
+			}
+
+			key, ok := m.Headers[ReferenceHeader]
+			if !ok {
+				return next(ctx, e)
+			}
+
+			data, err := c.store.Get(ctx, key)
+			if err != nil {
+				return fmt.Errorf("failed to load payload %s of message from %s: %w", key, e.Topic(), err)
+			}
+
+			m.Body = data
+			delete(m.Headers, ReferenceHeader)
+			delete(m.Headers, SizeHeader)
+
+			return next(ctx, e)
+		}
+	}
+}
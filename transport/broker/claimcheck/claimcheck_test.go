@@ -0,0 +1,105 @@
+package claimcheck
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/lengocson131002/go-clean-core/transport/broker"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type event struct {
+	topic string
+	m     *broker.Message
+}
+
+func (e *event) Topic() string            { return e.topic }
+func (e *event) Message() *broker.Message { return e.m }
+func (e *event) Ack() error               { return nil }
+func (e *event) Error() error             { return nil }
+
+func TestStores(t *testing.T) {
+	fileStore, err := NewFileStore(t.TempDir())
+	require.NoError(t, err)
+
+	stores := map[string]Store{
+		"memory": NewMemoryStore(),
+		"file":   fileStore,
+	}
+
+	for name, store := range stores {
+		t.Run(name, func(t *testing.T) {
+			ctx := context.Background()
+
+			require.NoError(t, store.Put(ctx, "key", []byte("payload")))
+
+			data, err := store.Get(ctx, "key")
+			require.NoError(t, err)
+			assert.Equal(t, []byte("payload"), data)
+
+			require.NoError(t, store.Delete(ctx, "key"))
+			_, err = store.Get(ctx, "key")
+			assert.True(t, errors.Is(err, ErrNotFound))
+		})
+	}
+}
+
+func TestFileStoreRejectsPathKeys(t *testing.T) {
+	store, err := NewFileStore(t.TempDir())
+	require.NoError(t, err)
+
+	_, err = store.Get(context.Background(), "../secret")
+	assert.True(t, errors.Is(err, ErrInvalidKey))
+}
+
+func TestClaimCheckRoundTrip(t *testing.T) {
+	var (
+		cc        = New(NewMemoryStore(), 4)
+		published []*broker.Message
+	)
+
+	publish := cc.PublishMiddleware()(func(ctx context.Context, topic string, m *broker.Message, opts ...broker.PublishOption) error {
+		published = append(published, m)
+		return nil
+	})
+
+	require.NoError(t, publish(context.Background(), "topic", &broker.Message{Body: []byte("tiny")}))
+	require.NoError(t, publish(context.Background(), "topic", &broker.Message{
+		Headers: map[string]string{"key": "value"},
+		Body:    []byte("large payload"),
+	}))
+
+	require.Len(t, published, 2)
+	assert.Equal(t, []byte("tiny"), published[0].Body)
+	assert.Empty(t, published[1].Body)
+	assert.Equal(t, "13", published[1].Headers[SizeHeader])
+
+	var handled []*broker.Message
+	handler := cc.SubscribeMiddleware()(func(ctx context.Context, e broker.Event) error {
+		handled = append(handled, e.Message())
+		return nil
+	})
+
+	for _, m := range published {
+		require.NoError(t, handler(context.Background(), &event{topic: "topic", m: m}))
+	}
+
+	assert.Equal(t, []byte("tiny"), handled[0].Body)
+	assert.Equal(t, []byte("large payload"), handled[1].Body)
+	assert.Equal(t, "value", handled[1].Headers["key"])
+	assert.NotContains(t, handled[1].Headers, ReferenceHeader)
+}
+
+func TestClaimCheckMissingPayload(t *testing.T) {
+	cc := New(NewMemoryStore(), 4)
+	handler := cc.SubscribeMiddleware()(func(ctx context.Context, e broker.Event) error {
+		return nil
+	})
+
+	err := handler(context.Background(), &event{topic: "topic", m: &broker.Message{
+		Headers: map[string]string{ReferenceHeader: "missing"},
+	}})
+	assert.True(t, errors.Is(err, ErrNotFound))
+}
@@ -0,0 +1,156 @@
+package dedup
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/lengocson131002/go-clean-core/logger"
+	"github.com/lengocson131002/go-clean-core/transport/broker"
+)
+
+const DefaultTTL = 24 * time.Hour
+
+// KeyFunc extracts the deduplication key of a message, messages with an empty key are not deduplicated
+type KeyFunc func(e broker.Event) string
+
+// MessageIdKey uses the message id of the envelope
+func MessageIdKey(e broker.Event) string {
+	if m := e.Message(); m != nil {
+		return m.Header(broker.MessageIdHeader)
+	}
+	return ""
+}
+
+type Option func(*options)
+
+type options struct {
+	ttl     time.Duration
+	keyFunc KeyFunc
+	scope   string
+	logger  logger.Logger
+}
+
+// WithTTL sets how long processed ids are recorded, defaults to 24 hours
+func WithTTL(ttl time.Duration) Option {
+	return func(o *options) {
+		o.ttl = ttl
+	}
+}
+
+// WithKeyFunc sets the key extractor, defaults to the message id
+func WithKeyFunc(keyFunc KeyFunc) Option {
+	return func(o *options) {
+		o.keyFunc = keyFunc
+	}
+}
+
+// WithScope prefixes the keys, so subscriptions sharing a store do not skip each other's messages.
+// WithDeduplication defaults it to the consumer group of the subscription.
+func WithScope(scope string) Option {
+	return func(o *options) {
+		o.scope = scope
+	}
+}
+
+func WithLogger(log logger.Logger) Option {
+	return func(o *options) {
+		o.logger = log
+	}
+}
+
+// Middleware skips the messages whose key is already recorded in the store. The key is recorded before the handler
+// runs and removed when the handler fails, so failed messages are processed again on redelivery.
+//
+// With a TransactionalStore, the handler runs in a transaction recording the key, the writes of the handler
+// through database.Gdbc join it, so the key is recorded if and only if they are committed.
+func Middleware(store Store, opts ...Option) broker.SubscribeMiddleware {
+	o := options{
+		ttl:     DefaultTTL,
+		keyFunc: MessageIdKey,
+	}
+
+	for _, opt := range opts {
+		opt(&o)
+	}
+
+	return middleware(store, o, func() string { return o.scope })
+}
+
+// WithDeduplication deduplicates the messages of the subscription, the keys are scoped by the consumer group
+// unless WithScope is given
+func WithDeduplication(store Store, opts ...Option) broker.SubscribeOption {
+	return func(so *broker.SubscribeOptions) {
+		o := options{
+			ttl:     DefaultTTL,
+			keyFunc: MessageIdKey,
+		}
+
+		for _, opt := range opts {
+			opt(&o)
+		}
+
+		// the group is read when handling, the options may set it after this one
+		scope := func() string {
+			if len(o.scope) != 0 {
+				return o.scope
+			}
+			return so.Group
+		}
+
+		so.Middlewares = append(so.Middlewares, middleware(store, o, scope))
+	}
+}
+
+func middleware(store Store, o options, scope func() string) broker.SubscribeMiddleware {
+	return func(next broker.Handler) broker.Handler {
+		return func(ctx context.Context, e broker.Event) error {
+			key := o.keyFunc(e)
+			if len(key) == 0 {
+				return next(ctx, e)
+			}
+
+			if s := scope(); len(s) != 0 {
+				key = fmt.Sprintf("%s:%s", s, key)
+			}
+
+			if ts, ok := store.(TransactionalStore); ok {
+				return ts.WithinTransaction(ctx, func(ctx context.Context) error {
+					marked, err := ts.Mark(ctx, key, o.ttl)
+					if err != nil {
+						return err
+					}
+					if !marked {
+						o.skip(ctx, e, key)
+						return nil
+					}
+					return next(ctx, e)
+				})
+			}
+
+			marked, err := store.Mark(ctx, key, o.ttl)
+			if err != nil {
+				return err
+			}
+			if !marked {
+				o.skip(ctx, e, key)
+				return nil
+			}
+
+			if err := next(ctx, e); err != nil {
+				if uerr := store.Unmark(ctx, key); uerr != nil && o.logger != nil {
+					o.logger.Errorf(ctx, "Failed to unmark message %s of topic %s: %v", key, e.Topic(), uerr)
+				}
+				return err
+			}
+
+			return nil
+		}
+	}
+}
+
+func (o options) skip(ctx context.Context, e broker.Event, key string) {
+	if o.logger != nil {
+		o.logger.Debugf(ctx, "Skipped duplicate message %s of topic %s", key, e.Topic())
+	}
+}
@@ -0,0 +1,178 @@
+package dedup
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/lengocson131002/go-clean-core/database/sqlx/sqlxtest"
+	"github.com/lengocson131002/go-clean-core/transport/broker"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type testEvent struct {
+	message *broker.Message
+}
+
+func (e *testEvent) Topic() string            { return "orders" }
+func (e *testEvent) Message() *broker.Message { return e.message }
+func (e *testEvent) Ack() error               { return nil }
+func (e *testEvent) Error() error             { return nil }
+
+func newEvent(id string) broker.Event {
+	return &testEvent{message: &broker.Message{Headers: map[string]string{broker.MessageIdHeader: id}}}
+}
+
+func TestMemoryStore(t *testing.T) {
+	ctx := context.Background()
+	s := NewMemoryStore(2)
+	now := time.Now()
+	s.now = func() time.Time { return now }
+
+	marked, err := s.Mark(ctx, "a", time.Minute)
+	require.NoError(t, err)
+	assert.True(t, marked)
+
+	marked, _ = s.Mark(ctx, "a", time.Minute)
+	assert.False(t, marked, "already marked")
+
+	// expired ids are marked again
+	now = now.Add(2 * time.Minute)
+	marked, _ = s.Mark(ctx, "a", time.Minute)
+	assert.True(t, marked)
+
+	// the least recently marked id is evicted
+	s.Mark(ctx, "b", time.Minute)
+	s.Mark(ctx, "c", time.Minute)
+	assert.Equal(t, 2, s.Len())
+	marked, _ = s.Mark(ctx, "a", time.Minute)
+	assert.True(t, marked)
+
+	require.NoError(t, s.Unmark(ctx, "a"))
+	marked, _ = s.Mark(ctx, "a", time.Minute)
+	assert.True(t, marked)
+}
+
+func TestMiddleware(t *testing.T) {
+	ctx := context.Background()
+	calls := 0
+	fail := true
+	handler := Middleware(NewMemoryStore(0))(func(ctx context.Context, e broker.Event) error {
+		calls++
+		if fail {
+			return errors.New("failed")
+		}
+		return nil
+	})
+
+	// failed messages are processed again
+	assert.Error(t, handler(ctx, newEvent("1")))
+	fail = false
+	assert.NoError(t, handler(ctx, newEvent("1")))
+	assert.NoError(t, handler(ctx, newEvent("1")))
+	assert.Equal(t, 2, calls)
+
+	// messages without id are not deduplicated
+	assert.NoError(t, handler(ctx, &testEvent{message: &broker.Message{}}))
+	assert.NoError(t, handler(ctx, &testEvent{message: &broker.Message{}}))
+	assert.Equal(t, 4, calls)
+}
+
+func TestWithDeduplicationScopedByGroup(t *testing.T) {
+	ctx := context.Background()
+	store := NewMemoryStore(0)
+	calls := 0
+	handler := func(ctx context.Context, e broker.Event) error {
+		calls++
+		return nil
+	}
+
+	subscribe := func(opts ...broker.SubscribeOption) broker.Handler {
+		var so broker.SubscribeOptions
+		for _, o := range opts {
+			o(&so)
+		}
+		return broker.ChainSubscriptionHandler(handler, so)
+	}
+
+	// the group is set after the deduplication option
+	billing := subscribe(WithDeduplication(store), broker.WithSubscribeGroup("billing"))
+	shipping := subscribe(WithDeduplication(store), broker.WithSubscribeGroup("shipping"))
+
+	assert.NoError(t, billing(ctx, newEvent("1")))
+	assert.NoError(t, shipping(ctx, newEvent("1")))
+	assert.NoError(t, billing(ctx, newEvent("1")))
+	assert.Equal(t, 2, calls)
+}
+
+func TestSQLStore(t *testing.T) {
+	ctx := context.Background()
+	db := sqlxtest.Open(t)
+	s := NewSQLStore(db)
+	require.NoError(t, s.CreateTable(ctx))
+
+	now := time.Now()
+	s.now = func() time.Time { return now }
+
+	marked, err := s.Mark(ctx, "a", time.Minute)
+	require.NoError(t, err)
+	assert.True(t, marked)
+
+	marked, err = s.Mark(ctx, "a", time.Minute)
+	require.NoError(t, err)
+	assert.False(t, marked)
+
+	now = now.Add(2 * time.Minute)
+	marked, err = s.Mark(ctx, "a", time.Minute)
+	require.NoError(t, err)
+	assert.True(t, marked)
+
+	require.NoError(t, s.Unmark(ctx, "a"))
+	marked, _ = s.Mark(ctx, "a", time.Minute)
+	assert.True(t, marked)
+
+	now = now.Add(2 * time.Minute)
+	require.NoError(t, s.Purge(ctx))
+	var count int
+	require.NoError(t, db.Get(ctx, &count, "SELECT COUNT(*) FROM processed_messages"))
+	assert.Equal(t, 0, count)
+}
+
+func TestSQLStoreRecordsInHandlerTransaction(t *testing.T) {
+	ctx := context.Background()
+	db := sqlxtest.Open(t)
+	store := NewSQLStore(db)
+	require.NoError(t, store.CreateTable(ctx))
+	_, err := db.Exec(ctx, "CREATE TABLE orders (id VARCHAR(255) NOT NULL)")
+	require.NoError(t, err)
+
+	fail := true
+	handler := Middleware(store)(func(ctx context.Context, e broker.Event) error {
+		if _, err := db.Exec(ctx, "INSERT INTO orders (id) VALUES (?)", e.Message().Header(broker.MessageIdHeader)); err != nil {
+			return err
+		}
+		if fail {
+			return errors.New("failed")
+		}
+		return nil
+	})
+
+	count := func(table string) int {
+		var n int
+		require.NoError(t, db.Get(ctx, &n, "SELECT COUNT(*) FROM "+table))
+		return n
+	}
+
+	// the handler failed, neither the order nor the id is recorded
+	assert.Error(t, handler(ctx, newEvent("1")))
+	assert.Equal(t, 0, count("orders"))
+	assert.Equal(t, 0, count("processed_messages"))
+
+	fail = false
+	assert.NoError(t, handler(ctx, newEvent("1")))
+	assert.NoError(t, handler(ctx, newEvent("1")))
+	assert.Equal(t, 1, count("orders"))
+	assert.Equal(t, 1, count("processed_messages"))
+}
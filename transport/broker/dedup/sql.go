@@ -0,0 +1,109 @@
+package dedup
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/lengocson131002/go-clean-core/database"
+)
+
+const DefaultTable = "processed_messages"
+
+// SQLStore records the ids of processed messages in a table of the database:
+//
+//	CREATE TABLE processed_messages (id VARCHAR(255) NOT NULL PRIMARY KEY, expires_at BIGINT NOT NULL)
+//
+// The ids are recorded in the transaction of the context when there is one. Concurrent consumers marking
+// the same id get a primary key violation, the message is redelivered and skipped afterwards.
+type SQLStore struct {
+	db       *database.Gdbc
+	table    string
+	bindType int
+	now      func() time.Time
+}
+
+type SQLStoreOption func(*SQLStore)
+
+// WithTable sets the table of the store, defaults to processed_messages
+func WithTable(table string) SQLStoreOption {
+	return func(s *SQLStore) {
+		s.table = table
+	}
+}
+
+// WithDriverName sets the placeholders of the queries from the driver name, e.g. `$1` for postgres.
+// Defaults to `?` placeholders.
+func WithDriverName(driverName string) SQLStoreOption {
+	return func(s *SQLStore) {
+		s.bindType = sqlx.BindType(driverName)
+	}
+}
+
+func NewSQLStore(db *database.Gdbc, opts ...SQLStoreOption) *SQLStore {
+	s := &SQLStore{
+		db:       db,
+		table:    DefaultTable,
+		bindType: sqlx.QUESTION,
+		now:      time.Now,
+	}
+
+	for _, opt := range opts {
+		opt(s)
+	}
+
+	return s
+}
+
+func (s *SQLStore) query(query string) string {
+	return sqlx.Rebind(s.bindType, fmt.Sprintf(query, s.table))
+}
+
+// CreateTable creates the table of the store when it does not exist
+func (s *SQLStore) CreateTable(ctx context.Context) error {
+	_, err := s.db.Exec(ctx, s.query("CREATE TABLE IF NOT EXISTS %s (id VARCHAR(255) NOT NULL PRIMARY KEY, expires_at BIGINT NOT NULL)"))
+	return err
+}
+
+func (s *SQLStore) Mark(ctx context.Context, id string, ttl time.Duration) (bool, error) {
+	now := s.now()
+
+	if _, err := s.db.Exec(ctx, s.query("DELETE FROM %s WHERE id = ? AND expires_at <= ?"), id, now.UnixNano()); err != nil {
+		return false, fmt.Errorf("failed to delete expired id %s: %w", id, err)
+	}
+
+	var count int
+	if err := s.db.Get(ctx, &count, s.query("SELECT COUNT(*) FROM %s WHERE id = ?"), id); err != nil {
+		return false, fmt.Errorf("failed to look up id %s: %w", id, err)
+	}
+
+	if count != 0 {
+		return false, nil
+	}
+
+	if _, err := s.db.Exec(ctx, s.query("INSERT INTO %s (id, expires_at) VALUES (?, ?)"), id, now.Add(ttl).UnixNano()); err != nil {
+		return false, fmt.Errorf("failed to record id %s: %w", id, err)
+	}
+
+	return true, nil
+}
+
+func (s *SQLStore) Unmark(ctx context.Context, id string) error {
+	_, err := s.db.Exec(ctx, s.query("DELETE FROM %s WHERE id = ?"), id)
+	return err
+}
+
+// Purge deletes the expired ids
+func (s *SQLStore) Purge(ctx context.Context) error {
+	_, err := s.db.Exec(ctx, s.query("DELETE FROM %s WHERE expires_at <= ?"), s.now().UnixNano())
+	return err
+}
+
+// WithinTransaction runs the function in the transaction of the context, or in a new one
+func (s *SQLStore) WithinTransaction(ctx context.Context, txFunc func(ctx context.Context) error) error {
+	if database.ExtractTx(ctx) != nil {
+		return txFunc(ctx)
+	}
+	return s.db.WithinTransaction(ctx, txFunc)
+}
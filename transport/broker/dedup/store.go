@@ -0,0 +1,97 @@
+package dedup
+
+import (
+	"container/list"
+	"context"
+	"sync"
+	"time"
+)
+
+const DefaultMemoryCapacity = 10000
+
+// Store records the ids of processed messages for a time to live
+type Store interface {
+	// Mark records the id, it returns false when the id is already recorded and not expired
+	Mark(ctx context.Context, id string, ttl time.Duration) (bool, error)
+	// Unmark removes the id so the message is processed again when it is redelivered
+	Unmark(ctx context.Context, id string) error
+}
+
+// TransactionalStore records the ids in the transaction of the handler, so the id is recorded if and only if
+// the writes of the handler are committed
+type TransactionalStore interface {
+	Store
+	WithinTransaction(ctx context.Context, txFunc func(ctx context.Context) error) error
+}
+
+type memoryEntry struct {
+	id        string
+	expiresAt time.Time
+}
+
+// MemoryStore keeps the most recently processed ids in memory, the least recently marked ids are evicted
+// when the capacity is reached. It only deduplicates the messages redelivered to the same process.
+type MemoryStore struct {
+	mutex    sync.Mutex
+	capacity int
+	entries  map[string]*list.Element
+	order    *list.List
+	now      func() time.Time
+}
+
+func NewMemoryStore(capacity int) *MemoryStore {
+	if capacity <= 0 {
+		capacity = DefaultMemoryCapacity
+	}
+
+	return &MemoryStore{
+		capacity: capacity,
+		entries:  make(map[string]*list.Element),
+		order:    list.New(),
+		now:      time.Now,
+	}
+}
+
+func (s *MemoryStore) Mark(ctx context.Context, id string, ttl time.Duration) (bool, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	now := s.now()
+	if el, ok := s.entries[id]; ok {
+		entry := el.Value.(*memoryEntry)
+		if now.Before(entry.expiresAt) {
+			return false, nil
+		}
+		entry.expiresAt = now.Add(ttl)
+		s.order.MoveToFront(el)
+		return true, nil
+	}
+
+	s.entries[id] = s.order.PushFront(&memoryEntry{id: id, expiresAt: now.Add(ttl)})
+
+	for s.order.Len() > s.capacity {
+		oldest := s.order.Back()
+		s.order.Remove(oldest)
+		delete(s.entries, oldest.Value.(*memoryEntry).id)
+	}
+
+	return true, nil
+}
+
+func (s *MemoryStore) Unmark(ctx context.Context, id string) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if el, ok := s.entries[id]; ok {
+		s.order.Remove(el)
+		delete(s.entries, id)
+	}
+	return nil
+}
+
+// Len returns the number of recorded ids, expired ones included
+func (s *MemoryStore) Len() int {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return s.order.Len()
+}
@@ -0,0 +1,88 @@
+package broker
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	// DeliverAtHeader carries the time a delayed message is due
+	DeliverAtHeader = "deliverAt"
+	// PriorityHeader carries the priority of a message published with a priority other than PriorityNormal
+	PriorityHeader = "priority"
+)
+
+// Priority orders the delivery of messages, higher priorities are processed first
+type Priority int
+
+const (
+	PriorityLow    Priority = -1
+	PriorityNormal Priority = 0
+	PriorityHigh   Priority = 1
+)
+
+func (p Priority) String() string {
+	switch p {
+	case PriorityLow:
+		return "low"
+	case PriorityNormal:
+		return "normal"
+	case PriorityHigh:
+		return "high"
+	default:
+		return strconv.Itoa(int(p))
+	}
+}
+
+// ParsePriority parses the name or the number of a priority
+func ParsePriority(s string) (Priority, error) {
+	switch strings.ToLower(s) {
+	case "low":
+		return PriorityLow, nil
+	case "normal", "":
+		return PriorityNormal, nil
+	case "high":
+		return PriorityHigh, nil
+	}
+
+	p, err := strconv.Atoi(s)
+	if err != nil {
+		return PriorityNormal, fmt.Errorf("invalid priority %q", s)
+	}
+	return Priority(p), nil
+}
+
+// DeliverAt returns the time the message is due, false when it is not delayed
+func (m *Message) DeliverAt() (time.Time, bool) {
+	v := m.Header(DeliverAtHeader)
+	if len(v) == 0 {
+		return time.Time{}, false
+	}
+
+	t, err := time.Parse(time.RFC3339Nano, v)
+	if err != nil {
+		return time.Time{}, false
+	}
+	return t, true
+}
+
+// Priority returns the priority of the message, PriorityNormal when it has none
+func (m *Message) Priority() Priority {
+	p, err := ParsePriority(m.Header(PriorityHeader))
+	if err != nil {
+		return PriorityNormal
+	}
+	return p
+}
+
+// SetDelivery writes the due time and the priority of the publish options to the headers of the message
+func (m *Message) SetDelivery(opts PublishOptions) {
+	if !opts.DeliverAt.IsZero() {
+		m.SetHeader(DeliverAtHeader, opts.DeliverAt.UTC().Format(time.RFC3339Nano))
+	}
+	if opts.Priority != PriorityNormal {
+		m.SetHeader(PriorityHeader, opts.Priority.String())
+	}
+}
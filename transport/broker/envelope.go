@@ -0,0 +1,235 @@
+package broker
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/lengocson131002/go-clean-core/metadata"
+)
+
+// Standard headers of the message envelope
+const (
+	CorrelationIdHeader = "correlationId"
+	CausationIdHeader   = "causationId"
+	MessageTypeHeader   = "messageType"
+	SourceHeader        = "source"
+	TimestampHeader     = "timestamp"
+	ContentTypeHeader   = "contentType"
+	ReplyToHeader       = "replyTo"
+	TenantIdHeader      = "tenantId"
+)
+
+// Envelope holds the standard headers of a message
+type Envelope struct {
+	Id            string
+	Type          string
+	Source        string
+	Timestamp     time.Time
+	ContentType   string
+	CorrelationId string
+	CausationId   string
+	ReplyTo       string
+	TenantId      string
+}
+
+// Header returns the value of the header, empty when it is not set
+func (m *Message) Header(key string) string {
+	if m.Headers == nil {
+		return ""
+	}
+	return m.Headers[key]
+}
+
+// SetHeader sets the header, an empty value removes it
+func (m *Message) SetHeader(key string, value string) {
+	if len(value) == 0 {
+		delete(m.Headers, key)
+		return
+	}
+	if m.Headers == nil {
+		m.Headers = make(map[string]string)
+	}
+	m.Headers[key] = value
+}
+
+// Envelope reads the standard headers of the message
+func (m *Message) Envelope() Envelope {
+	e := Envelope{
+		Id:            m.Header(MessageIdHeader),
+		Type:          m.Header(MessageTypeHeader),
+		Source:        m.Header(SourceHeader),
+		ContentType:   m.Header(ContentTypeHeader),
+		CorrelationId: m.Header(CorrelationIdHeader),
+		CausationId:   m.Header(CausationIdHeader),
+		ReplyTo:       m.Header(ReplyToHeader),
+		TenantId:      m.Header(TenantIdHeader),
+	}
+
+	if ts := m.Header(TimestampHeader); len(ts) != 0 {
+		if t, err := time.Parse(time.RFC3339Nano, ts); err == nil {
+			e.Timestamp = t
+		}
+	}
+
+	return e
+}
+
+// SetEnvelope writes the non-empty fields of the envelope to the headers of the message
+func (m *Message) SetEnvelope(e Envelope) {
+	set := func(key, value string) {
+		if len(value) != 0 {
+			m.SetHeader(key, value)
+		}
+	}
+
+	set(MessageIdHeader, e.Id)
+	set(MessageTypeHeader, e.Type)
+	set(SourceHeader, e.Source)
+	set(ContentTypeHeader, e.ContentType)
+	set(CorrelationIdHeader, e.CorrelationId)
+	set(CausationIdHeader, e.CausationId)
+	set(ReplyToHeader, e.ReplyTo)
+	set(TenantIdHeader, e.TenantId)
+
+	if !e.Timestamp.IsZero() {
+		m.SetHeader(TimestampHeader, e.Timestamp.UTC().Format(time.RFC3339Nano))
+	}
+}
+
+// NewReply returns a reply to the request carrying its correlation id and tenant, caused by the request
+func NewReply(request *Message, body []byte) *Message {
+	reply := &Message{Body: body}
+	reply.SetEnvelope(Envelope{
+		CorrelationId: request.Header(CorrelationIdHeader),
+		CausationId:   request.Header(MessageIdHeader),
+		TenantId:      request.Header(TenantIdHeader),
+	})
+	return reply
+}
+
+// PopulateEnvelope fills the missing message id, timestamp and correlation id of the message.
+// Correlation id, causation id, tenant and source are taken from the metadata of the context when present.
+func PopulateEnvelope(ctx context.Context, m *Message) {
+	if m.Headers == nil {
+		m.Headers = make(map[string]string)
+	}
+
+	if md, ok := metadata.FromContext(ctx); ok {
+		fromMetadata := map[string]string{
+			CorrelationIdHeader: metadata.CorrelationIdKey,
+			CausationIdHeader:   metadata.CausationIdKey,
+			TenantIdHeader:      metadata.TenantIdKey,
+			SourceHeader:        metadata.SourceKey,
+		}
+		for header, key := range fromMetadata {
+			if len(m.Headers[header]) != 0 {
+				continue
+			}
+			if value, ok := md.Get(key); ok && len(value) != 0 {
+				m.Headers[header] = value
+			}
+		}
+	}
+
+	if len(m.Headers[MessageIdHeader]) == 0 {
+		m.Headers[MessageIdHeader] = uuid.New().String()
+	}
+	if len(m.Headers[CorrelationIdHeader]) == 0 {
+		m.Headers[CorrelationIdHeader] = uuid.New().String()
+	}
+	if len(m.Headers[TimestampHeader]) == 0 {
+		m.Headers[TimestampHeader] = time.Now().UTC().Format(time.RFC3339Nano)
+	}
+}
+
+// ContextWithEnvelope returns a context carrying the correlation id and tenant of the consumed message,
+// its message id becomes the causation id of the messages published while handling it
+func ContextWithEnvelope(ctx context.Context, m *Message) context.Context {
+	if m == nil {
+		return ctx
+	}
+
+	md := metadata.Metadata{}
+	if id := m.Header(CorrelationIdHeader); len(id) != 0 {
+		md[metadata.CorrelationIdKey] = id
+	}
+	if id := m.Header(MessageIdHeader); len(id) != 0 {
+		md[metadata.CausationIdKey] = id
+	}
+	if tenant := m.Header(TenantIdHeader); len(tenant) != 0 {
+		md[metadata.TenantIdKey] = tenant
+	}
+
+	if len(md) == 0 {
+		return ctx
+	}
+	return metadata.MergeContext(ctx, md, true)
+}
+
+const (
+	CloudEventsPrefix      = "ce_"
+	CloudEventsSpecVersion = "1.0"
+	cloudEventsContentType = "content-type"
+)
+
+// cloudEventsAttributes maps envelope headers to CloudEvents attributes,
+// extension attribute names are lower case alphanumeric
+var cloudEventsAttributes = map[string]string{
+	MessageIdHeader:     "id",
+	MessageTypeHeader:   "type",
+	SourceHeader:        "source",
+	TimestampHeader:     "time",
+	CorrelationIdHeader: "correlationid",
+	CausationIdHeader:   "causationid",
+	ReplyToHeader:       "replyto",
+	TenantIdHeader:      "tenantid",
+}
+
+// ToCloudEventsHeaders maps the envelope headers to CloudEvents binary content mode headers,
+// e.g. `ce_id`, `ce_type` and `content-type`. Other headers are kept as they are.
+func ToCloudEventsHeaders(headers map[string]string) map[string]string {
+	result := make(map[string]string, len(headers)+1)
+	for key, value := range headers {
+		if attr, ok := cloudEventsAttributes[key]; ok {
+			result[CloudEventsPrefix+attr] = value
+		} else if key == ContentTypeHeader {
+			result[cloudEventsContentType] = value
+		} else {
+			result[key] = value
+		}
+	}
+	result[CloudEventsPrefix+"specversion"] = CloudEventsSpecVersion
+	return result
+}
+
+// FromCloudEventsHeaders maps CloudEvents binary content mode headers back to the envelope headers
+func FromCloudEventsHeaders(headers map[string]string) map[string]string {
+	result := make(map[string]string, len(headers))
+	for key, value := range headers {
+		switch {
+		case key == cloudEventsContentType:
+			result[ContentTypeHeader] = value
+		case key == CloudEventsPrefix+"specversion":
+		case strings.HasPrefix(key, CloudEventsPrefix):
+			attr := strings.TrimPrefix(key, CloudEventsPrefix)
+			if header, ok := envelopeHeaders[attr]; ok {
+				result[header] = value
+			} else {
+				result[attr] = value
+			}
+		default:
+			result[key] = value
+		}
+	}
+	return result
+}
+
+var envelopeHeaders = func() map[string]string {
+	headers := make(map[string]string, len(cloudEventsAttributes))
+	for header, attr := range cloudEventsAttributes {
+		headers[attr] = header
+	}
+	return headers
+}()
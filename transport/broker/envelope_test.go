@@ -0,0 +1,85 @@
+package broker
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/lengocson131002/go-clean-core/metadata"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestEnvelopeRoundTrip(t *testing.T) {
+	ts := time.Date(2024, 3, 1, 10, 0, 0, 0, time.UTC)
+	e := Envelope{
+		Id:            "id",
+		Type:          "t24.account.created",
+		Source:        "core-banking",
+		Timestamp:     ts,
+		ContentType:   "application/json",
+		CorrelationId: "correlation",
+		CausationId:   "cause",
+		ReplyTo:       "reply",
+		TenantId:      "tenant",
+	}
+
+	m := &Message{}
+	m.SetEnvelope(e)
+
+	assert.Equal(t, e, m.Envelope())
+	assert.Equal(t, "2024-03-01T10:00:00Z", m.Header(TimestampHeader))
+}
+
+func TestPopulateEnvelopeFromMetadata(t *testing.T) {
+	ctx := metadata.NewContext(context.Background(), metadata.Metadata{
+		metadata.CorrelationIdKey: "correlation",
+		metadata.TenantIdKey:      "tenant",
+	})
+
+	m := &Message{Headers: map[string]string{TenantIdHeader: "own"}}
+	PopulateEnvelope(ctx, m)
+
+	e := m.Envelope()
+	assert.NotEmpty(t, e.Id)
+	assert.False(t, e.Timestamp.IsZero())
+	assert.Equal(t, "correlation", e.CorrelationId)
+	assert.Equal(t, "own", e.TenantId)
+}
+
+func TestContextWithEnvelopeChainsCausation(t *testing.T) {
+	consumed := &Message{}
+	consumed.SetEnvelope(Envelope{Id: "first", CorrelationId: "correlation", TenantId: "tenant"})
+
+	ctx := ContextWithEnvelope(context.Background(), consumed)
+
+	published := &Message{}
+	PopulateEnvelope(ctx, published)
+
+	e := published.Envelope()
+	assert.Equal(t, "first", e.CausationId)
+	assert.Equal(t, "correlation", e.CorrelationId)
+	assert.Equal(t, "tenant", e.TenantId)
+	assert.NotEqual(t, "first", e.Id)
+}
+
+func TestCloudEventsHeaders(t *testing.T) {
+	headers := map[string]string{
+		MessageIdHeader:     "id",
+		MessageTypeHeader:   "type",
+		ContentTypeHeader:   "application/json",
+		CorrelationIdHeader: "correlation",
+		"custom":            "value",
+	}
+
+	ce := ToCloudEventsHeaders(headers)
+	assert.Equal(t, map[string]string{
+		"ce_id":            "id",
+		"ce_type":          "type",
+		"content-type":     "application/json",
+		"ce_correlationid": "correlation",
+		"ce_specversion":   "1.0",
+		"custom":           "value",
+	}, ce)
+
+	assert.Equal(t, headers, FromCloudEventsHeaders(ce))
+}
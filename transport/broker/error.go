@@ -24,3 +24,30 @@ type RequestTimeoutResponse struct {
 func (e RequestTimeoutResponse) Error() string {
 	return fmt.Sprintf("Request timeout exceeded. Timeout: %vs", e.Timeout.Seconds())
 }
+
+type DrainTimeoutError struct {
+	Timeout time.Duration
+}
+
+func (e DrainTimeoutError) Error() string {
+	return fmt.Sprintf("In-flight messages were not drained in time. Timeout: %vs", e.Timeout.Seconds())
+}
+
+type SubscribeTimeoutError struct {
+	Topic   string
+	Timeout time.Duration
+}
+
+func (e SubscribeTimeoutError) Error() string {
+	return fmt.Sprintf("Subscriber of topic %s was not ready in time. Timeout: %vs", e.Topic, e.Timeout.Seconds())
+}
+
+type MessageTooLargeError struct {
+	Topic string
+	Size  int
+	Limit int
+}
+
+func (e MessageTooLargeError) Error() string {
+	return fmt.Sprintf("Message published to %s is too large. Size: %d bytes, limit: %d bytes", e.Topic, e.Size, e.Limit)
+}
@@ -0,0 +1,55 @@
+package flowcontrol
+
+import (
+	"context"
+	"time"
+
+	"github.com/lengocson131002/go-clean-core/transport/broker"
+	"golang.org/x/time/rate"
+)
+
+// RateLimit delays the handler so the messages are handled at the rate of the token bucket limiter.
+// Sharing the limiter between subscriptions caps their total rate.
+func RateLimit(limiter *rate.Limiter) broker.SubscribeMiddleware {
+	return func(next broker.Handler) broker.Handler {
+		return func(ctx context.Context, e broker.Event) error {
+			if err := limiter.Wait(ctx); err != nil {
+				return err
+			}
+			return next(ctx, e)
+		}
+	}
+}
+
+// ConcurrencyLimit waits for a slot of the limiter before calling the handler, so the subscriptions sharing
+// the limiter handle at most its limit of messages at once
+func ConcurrencyLimit(limiter *ConcurrencyLimiter) broker.SubscribeMiddleware {
+	return func(next broker.Handler) broker.Handler {
+		return func(ctx context.Context, e broker.Event) error {
+			if err := limiter.Acquire(ctx); err != nil {
+				return err
+			}
+
+			start := time.Now()
+			err := next(ctx, e)
+			limiter.Release(time.Since(start), err)
+			return err
+		}
+	}
+}
+
+// WithRateLimit handles at most perSecond messages per second of the subscription, with bursts of burst messages
+func WithRateLimit(perSecond float64, burst int) broker.SubscribeOption {
+	return WithSharedRateLimit(rate.NewLimiter(rate.Limit(perSecond), burst))
+}
+
+// WithSharedRateLimit rate limits the subscription with a limiter shared with other subscriptions
+func WithSharedRateLimit(limiter *rate.Limiter) broker.SubscribeOption {
+	return broker.WithSubscriptionMiddlewares(RateLimit(limiter))
+}
+
+// WithConcurrencyLimit limits the messages of the subscription handled at once, see NewConcurrencyLimiter
+// and NewAdaptiveLimiter
+func WithConcurrencyLimit(limiter *ConcurrencyLimiter) broker.SubscribeOption {
+	return broker.WithSubscriptionMiddlewares(ConcurrencyLimit(limiter))
+}
@@ -0,0 +1,113 @@
+package flowcontrol
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/lengocson131002/go-clean-core/transport/broker"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/time/rate"
+)
+
+type testEvent struct{}
+
+func (e *testEvent) Topic() string            { return "orders" }
+func (e *testEvent) Message() *broker.Message { return &broker.Message{} }
+func (e *testEvent) Ack() error               { return nil }
+func (e *testEvent) Error() error             { return nil }
+
+func TestSharedRateLimit(t *testing.T) {
+	limiter := rate.NewLimiter(rate.Limit(50), 1)
+	handler := func(ctx context.Context, e broker.Event) error { return nil }
+
+	first := RateLimit(limiter)(handler)
+	second := RateLimit(limiter)(handler)
+
+	start := time.Now()
+	for i := 0; i < 5; i++ {
+		require.NoError(t, first(context.Background(), &testEvent{}))
+		require.NoError(t, second(context.Background(), &testEvent{}))
+	}
+
+	// 10 messages at 50 per second with a burst of 1
+	assert.GreaterOrEqual(t, time.Since(start), 170*time.Millisecond)
+}
+
+func TestConcurrencyLimit(t *testing.T) {
+	limiter := NewConcurrencyLimiter(2)
+
+	var running, max atomic.Int32
+	handler := ConcurrencyLimit(limiter)(func(ctx context.Context, e broker.Event) error {
+		n := running.Add(1)
+		for {
+			m := max.Load()
+			if n <= m || max.CompareAndSwap(m, n) {
+				break
+			}
+		}
+		time.Sleep(10 * time.Millisecond)
+		running.Add(-1)
+		return nil
+	})
+
+	done := make(chan struct{})
+	for i := 0; i < 6; i++ {
+		go func() {
+			handler(context.Background(), &testEvent{})
+			done <- struct{}{}
+		}()
+	}
+	for i := 0; i < 6; i++ {
+		<-done
+	}
+
+	assert.Equal(t, int32(2), max.Load())
+	assert.Equal(t, 0, limiter.InFlight())
+}
+
+func TestAcquireCanceled(t *testing.T) {
+	limiter := NewConcurrencyLimiter(1)
+	require.True(t, limiter.TryAcquire())
+	assert.False(t, limiter.TryAcquire())
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	assert.ErrorIs(t, limiter.Acquire(ctx), context.DeadlineExceeded)
+}
+
+func TestAdaptiveLimiter(t *testing.T) {
+	limiter := NewAdaptiveLimiter(AdaptiveOptions{
+		Initial:       10,
+		Min:           2,
+		Max:           12,
+		TargetLatency: 100 * time.Millisecond,
+		Backoff:       0.5,
+	})
+
+	release := func(latency time.Duration, err error) {
+		require.True(t, limiter.TryAcquire())
+		limiter.Release(latency, err)
+	}
+
+	// errors and slow handlers shrink the limit down to the minimum
+	release(time.Millisecond, errors.New("failed"))
+	assert.Equal(t, 5, limiter.Limit())
+	release(time.Second, nil)
+	assert.Equal(t, 2, limiter.Limit())
+	release(time.Second, nil)
+	assert.Equal(t, 2, limiter.Limit())
+
+	// successful handlers grow it by one per limit of messages, up to the maximum
+	for i := 0; i < 6; i++ {
+		release(time.Millisecond, nil)
+	}
+	assert.Equal(t, 4, limiter.Limit())
+	for i := 0; i < 200; i++ {
+		release(time.Millisecond, nil)
+	}
+	assert.Equal(t, 12, limiter.Limit())
+}
@@ -0,0 +1,148 @@
+package flowcontrol
+
+import (
+	"context"
+	"math"
+	"sync"
+	"time"
+)
+
+// AdaptiveOptions configures an adaptive ConcurrencyLimiter
+type AdaptiveOptions struct {
+	// Initial limit, Min when not set
+	Initial int
+	// Min and Max bound the limit, Min defaults to 1
+	Min int
+	Max int
+	// TargetLatency is the handler latency above which the limit shrinks, latency is ignored when not set
+	TargetLatency time.Duration
+	// Backoff multiplies the limit on errors and slow handlers, defaults to 0.9
+	Backoff float64
+}
+
+const DefaultBackoff = 0.9
+
+// ConcurrencyLimiter limits the number of messages handled at once. An adaptive limiter grows the limit by one
+// per limit of successful handlers and shrinks it multiplicatively on handler errors or latency above the target.
+type ConcurrencyLimiter struct {
+	mutex    sync.Mutex
+	limit    float64
+	min      float64
+	max      float64
+	target   time.Duration
+	backoff  float64
+	adaptive bool
+	inflight int
+	changed  chan struct{}
+}
+
+// NewConcurrencyLimiter returns a limiter handling at most n messages at once
+func NewConcurrencyLimiter(n int) *ConcurrencyLimiter {
+	if n < 1 {
+		n = 1
+	}
+	return &ConcurrencyLimiter{
+		limit:   float64(n),
+		min:     float64(n),
+		max:     float64(n),
+		changed: make(chan struct{}),
+	}
+}
+
+func NewAdaptiveLimiter(opts AdaptiveOptions) *ConcurrencyLimiter {
+	if opts.Min < 1 {
+		opts.Min = 1
+	}
+	if opts.Max < opts.Min {
+		opts.Max = opts.Min
+	}
+	if opts.Initial < opts.Min {
+		opts.Initial = opts.Min
+	}
+	if opts.Initial > opts.Max {
+		opts.Initial = opts.Max
+	}
+	if opts.Backoff <= 0 || opts.Backoff >= 1 {
+		opts.Backoff = DefaultBackoff
+	}
+
+	return &ConcurrencyLimiter{
+		limit:    float64(opts.Initial),
+		min:      float64(opts.Min),
+		max:      float64(opts.Max),
+		target:   opts.TargetLatency,
+		backoff:  opts.Backoff,
+		adaptive: true,
+		changed:  make(chan struct{}),
+	}
+}
+
+// TryAcquire takes a slot without waiting, it returns false when the limit is reached
+func (l *ConcurrencyLimiter) TryAcquire() bool {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	if l.inflight >= l.currentLimit() {
+		return false
+	}
+	l.inflight++
+	return true
+}
+
+// Acquire waits for a slot until the context is done
+func (l *ConcurrencyLimiter) Acquire(ctx context.Context) error {
+	for {
+		l.mutex.Lock()
+		if l.inflight < l.currentLimit() {
+			l.inflight++
+			l.mutex.Unlock()
+			return nil
+		}
+		changed := l.changed
+		l.mutex.Unlock()
+
+		select {
+		case <-changed:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
+
+// Release frees the slot, the latency and the error of the handler adapt the limit of an adaptive limiter
+func (l *ConcurrencyLimiter) Release(latency time.Duration, err error) {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	l.inflight--
+
+	if l.adaptive {
+		if err != nil || (l.target > 0 && latency > l.target) {
+			l.limit = math.Max(l.min, l.limit*l.backoff)
+		} else {
+			l.limit = math.Min(l.max, l.limit+1/l.limit)
+		}
+	}
+
+	// wake the waiters to check the limit again
+	close(l.changed)
+	l.changed = make(chan struct{})
+}
+
+// Limit returns the current limit
+func (l *ConcurrencyLimiter) Limit() int {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	return l.currentLimit()
+}
+
+// InFlight returns the number of messages being handled
+func (l *ConcurrencyLimiter) InFlight() int {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	return l.inflight
+}
+
+func (l *ConcurrencyLimiter) currentLimit() int {
+	return int(l.limit)
+}
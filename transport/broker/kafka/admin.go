@@ -0,0 +1,485 @@
+package kafka
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/IBM/sarama"
+	"gopkg.in/yaml.v3"
+)
+
+const (
+	CleanupPolicyDelete  = "delete"
+	CleanupPolicyCompact = "compact"
+
+	topicConfigRetentionMs    = "retention.ms"
+	topicConfigCleanupPolicy  = "cleanup.policy"
+	defaultReplicationFactor  = 1
+	defaultNumberOfPartitions = 1
+)
+
+// TopicConfig is the desired state of a topic
+type TopicConfig struct {
+	Name              string `yaml:"name" mapstructure:"name"`
+	Partitions        int32  `yaml:"partitions" mapstructure:"partitions"`
+	ReplicationFactor int16  `yaml:"replicationFactor" mapstructure:"replicationFactor"`
+
+	// Retention is mapped to `retention.ms`. Zero keeps the broker default, negative means unlimited
+	Retention time.Duration `yaml:"retention" mapstructure:"retention"`
+
+	// Compacted sets `cleanup.policy` to compact instead of delete
+	Compacted bool `yaml:"compacted" mapstructure:"compacted"`
+
+	// Additional topic level configurations, e.g. `min.insync.replicas`
+	Configs map[string]string `yaml:"configs" mapstructure:"configs"`
+}
+
+// TopicTopology is a set of topics applied by the reconciler at startup
+type TopicTopology struct {
+	Topics []TopicConfig `yaml:"topics" mapstructure:"topics"`
+}
+
+type PartitionInfo struct {
+	ID       int32
+	Leader   int32
+	Replicas []int32
+	Isr      []int32
+}
+
+type TopicInfo struct {
+	Name              string
+	Partitions        int32
+	ReplicationFactor int16
+	Configs           map[string]string
+	PartitionDetails  []PartitionInfo
+}
+
+type PartitionLag struct {
+	Topic           string
+	Partition       int32
+	CommittedOffset int64 // -1 if the group has not committed any offset yet
+	HighWaterMark   int64
+	Lag             int64
+}
+
+type ConsumerGroupInfo struct {
+	GroupId  string
+	State    string
+	Members  int
+	TotalLag int64
+	Lags     []PartitionLag
+}
+
+type ClusterInfo struct {
+	Brokers      []string
+	ControllerId int32
+}
+
+// TopicAdmin is used to administrate kafka topics and consumer groups
+type TopicAdmin interface {
+	// EnsureTopic creates the topic if it does not exist, otherwise grows partitions and updates configs to match
+	EnsureTopic(cfg TopicConfig) error
+	// Reconcile ensures all topics of the topology
+	Reconcile(topology TopicTopology) error
+	ListTopics() ([]TopicInfo, error)
+	DescribeTopics(topics ...string) ([]TopicInfo, error)
+	DeleteTopic(topic string) error
+	ListConsumerGroups() ([]string, error)
+	// DescribeConsumerGroup returns group state and the lag of each partition the group has committed offsets for
+	DescribeConsumerGroup(group string) (*ConsumerGroupInfo, error)
+	DescribeCluster() (*ClusterInfo, error)
+	Close() error
+}
+
+type topicAdmin struct {
+	admin  sarama.ClusterAdmin
+	client sarama.Client
+}
+
+func NewTopicAdmin(addrs []string, config *sarama.Config) (TopicAdmin, error) {
+	if config == nil {
+		config = sarama.NewConfig()
+	}
+
+	client, err := sarama.NewClient(addrs, config)
+	if err != nil {
+		return nil, err
+	}
+
+	admin, err := sarama.NewClusterAdminFromClient(client)
+	if err != nil {
+		client.Close()
+		return nil, err
+	}
+
+	return &topicAdmin{
+		admin:  admin,
+		client: client,
+	}, nil
+}
+
+func GetTopicAdmin(cfg *KafkaBrokerConfig) (TopicAdmin, error) {
+	conf, err := newSaramaConfig(cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	addrs := cfg.Addresses
+	if len(addrs) == 0 {
+		addrs = []string{DefaultKafkaBroker}
+	}
+
+	return NewTopicAdmin(addrs, conf)
+}
+
+// LoadTopicTopology reads a YAML topology file
+func LoadTopicTopology(path string) (*TopicTopology, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return ParseTopicTopology(data)
+}
+
+// ParseTopicTopology parses a YAML topology.
+//
+//	topics:
+//	  - name: orders
+//	    partitions: 6
+//	    replicationFactor: 3
+//	    retention: 168h
+func ParseTopicTopology(data []byte) (*TopicTopology, error) {
+	var topology TopicTopology
+	if err := yaml.Unmarshal(data, &topology); err != nil {
+		return nil, fmt.Errorf("failed to parse topic topology: %w", err)
+	}
+	return &topology, nil
+}
+
+// EnsureTopic implements TopicAdmin.
+func (a *topicAdmin) EnsureTopic(cfg TopicConfig) error {
+	if len(cfg.Name) == 0 {
+		return errors.New("topic name is required")
+	}
+
+	topics, err := a.admin.ListTopics()
+	if err != nil {
+		return err
+	}
+
+	current, ok := topics[cfg.Name]
+	if !ok {
+		return a.createTopic(cfg)
+	}
+
+	partitions := cfg.Partitions
+	if partitions > current.NumPartitions {
+		if err := a.admin.CreatePartitions(cfg.Name, partitions, nil, false); err != nil {
+			return fmt.Errorf("failed to increase partitions of topic %s: %w", cfg.Name, err)
+		}
+	} else if partitions > 0 && partitions < current.NumPartitions {
+		return fmt.Errorf("topic %s has %d partitions, can not decrease to %d", cfg.Name, current.NumPartitions, partitions)
+	}
+
+	if cfg.ReplicationFactor > 0 && cfg.ReplicationFactor != current.ReplicationFactor {
+		return fmt.Errorf("topic %s has replication factor %d, changing to %d requires a partition reassignment", cfg.Name, current.ReplicationFactor, cfg.ReplicationFactor)
+	}
+
+	desired := cfg.configEntries()
+	if len(desired) == 0 {
+		return nil
+	}
+
+	var (
+		changed = false
+		entries = make(map[string]*string, len(current.ConfigEntries)+len(desired))
+	)
+
+	// AlterConfig resets every entry which is not sent, so keep the current ones
+	for key, value := range current.ConfigEntries {
+		entries[key] = value
+	}
+
+	for key, value := range desired {
+		if cur, ok := current.ConfigEntries[key]; !ok || cur == nil || *cur != *value {
+			changed = true
+		}
+		entries[key] = value
+	}
+
+	if !changed {
+		return nil
+	}
+
+	if err := a.admin.AlterConfig(sarama.TopicResource, cfg.Name, entries, false); err != nil {
+		return fmt.Errorf("failed to update configs of topic %s: %w", cfg.Name, err)
+	}
+
+	return nil
+}
+
+func (a *topicAdmin) createTopic(cfg TopicConfig) error {
+	detail := &sarama.TopicDetail{
+		NumPartitions:     cfg.Partitions,
+		ReplicationFactor: cfg.ReplicationFactor,
+		ConfigEntries:     cfg.configEntries(),
+	}
+
+	if detail.NumPartitions <= 0 {
+		detail.NumPartitions = defaultNumberOfPartitions
+	}
+
+	if detail.ReplicationFactor <= 0 {
+		detail.ReplicationFactor = defaultReplicationFactor
+	}
+
+	err := a.admin.CreateTopic(cfg.Name, detail, false)
+	if err != nil && !errors.Is(err, sarama.ErrTopicAlreadyExists) {
+		return fmt.Errorf("failed to create topic %s: %w", cfg.Name, err)
+	}
+
+	return nil
+}
+
+// Reconcile implements TopicAdmin.
+func (a *topicAdmin) Reconcile(topology TopicTopology) error {
+	var errs []error
+	for _, topic := range topology.Topics {
+		if err := a.EnsureTopic(topic); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// ListTopics implements TopicAdmin.
+func (a *topicAdmin) ListTopics() ([]TopicInfo, error) {
+	topics, err := a.admin.ListTopics()
+	if err != nil {
+		return nil, err
+	}
+
+	result := make([]TopicInfo, 0, len(topics))
+	for name, detail := range topics {
+		configs := make(map[string]string, len(detail.ConfigEntries))
+		for key, value := range detail.ConfigEntries {
+			if value != nil {
+				configs[key] = *value
+			}
+		}
+
+		result = append(result, TopicInfo{
+			Name:              name,
+			Partitions:        detail.NumPartitions,
+			ReplicationFactor: detail.ReplicationFactor,
+			Configs:           configs,
+		})
+	}
+
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].Name < result[j].Name
+	})
+
+	return result, nil
+}
+
+// DescribeTopics implements TopicAdmin.
+func (a *topicAdmin) DescribeTopics(topics ...string) ([]TopicInfo, error) {
+	metadata, err := a.admin.DescribeTopics(topics)
+	if err != nil {
+		return nil, err
+	}
+
+	result := make([]TopicInfo, 0, len(metadata))
+	for _, topic := range metadata {
+		if topic.Err != sarama.ErrNoError {
+			return nil, fmt.Errorf("failed to describe topic %s: %w", topic.Name, topic.Err)
+		}
+
+		info := TopicInfo{
+			Name:             topic.Name,
+			Partitions:       int32(len(topic.Partitions)),
+			PartitionDetails: make([]PartitionInfo, 0, len(topic.Partitions)),
+		}
+
+		for _, partition := range topic.Partitions {
+			info.PartitionDetails = append(info.PartitionDetails, PartitionInfo{
+				ID:       partition.ID,
+				Leader:   partition.Leader,
+				Replicas: partition.Replicas,
+				Isr:      partition.Isr,
+			})
+		}
+
+		if len(topic.Partitions) > 0 {
+			info.ReplicationFactor = int16(len(topic.Partitions[0].Replicas))
+		}
+
+		sort.Slice(info.PartitionDetails, func(i, j int) bool {
+			return info.PartitionDetails[i].ID < info.PartitionDetails[j].ID
+		})
+
+		result = append(result, info)
+	}
+
+	return result, nil
+}
+
+// DeleteTopic implements TopicAdmin.
+func (a *topicAdmin) DeleteTopic(topic string) error {
+	return a.admin.DeleteTopic(topic)
+}
+
+// ListConsumerGroups implements TopicAdmin.
+func (a *topicAdmin) ListConsumerGroups() ([]string, error) {
+	groups, err := a.admin.ListConsumerGroups()
+	if err != nil {
+		return nil, err
+	}
+
+	result := make([]string, 0, len(groups))
+	for group := range groups {
+		result = append(result, group)
+	}
+	sort.Strings(result)
+
+	return result, nil
+}
+
+// DescribeConsumerGroup implements TopicAdmin.
+func (a *topicAdmin) DescribeConsumerGroup(group string) (*ConsumerGroupInfo, error) {
+	descriptions, err := a.admin.DescribeConsumerGroups([]string{group})
+	if err != nil {
+		return nil, err
+	}
+
+	info := &ConsumerGroupInfo{
+		GroupId: group,
+	}
+
+	for _, desc := range descriptions {
+		if desc.GroupId != group {
+			continue
+		}
+		if desc.Err != sarama.ErrNoError {
+			return nil, fmt.Errorf("failed to describe consumer group %s: %w", group, desc.Err)
+		}
+		info.State = desc.State
+		info.Members = len(desc.Members)
+	}
+
+	lags, err := a.consumerGroupLags(group)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, lag := range lags {
+		info.TotalLag += lag.Lag
+	}
+	info.Lags = lags
+
+	return info, nil
+}
+
+// consumerGroupLags compares committed offsets with the high water mark of each partition
+func (a *topicAdmin) consumerGroupLags(group string) ([]PartitionLag, error) {
+	offsets, err := a.admin.ListConsumerGroupOffsets(group, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	if offsets.Err != sarama.ErrNoError {
+		return nil, fmt.Errorf("failed to fetch offsets of consumer group %s: %w", group, offsets.Err)
+	}
+
+	lags := make([]PartitionLag, 0)
+	for topic, partitions := range offsets.Blocks {
+		for partition, block := range partitions {
+			if block == nil {
+				continue
+			}
+
+			hwm, err := a.client.GetOffset(topic, partition, sarama.OffsetNewest)
+			if err != nil {
+				return nil, fmt.Errorf("failed to fetch high water mark of %s/%d: %w", topic, partition, err)
+			}
+
+			// without committed offset, the whole partition is counted as lag
+			lag := hwm
+			if block.Offset >= 0 {
+				lag = hwm - block.Offset
+			}
+			if lag < 0 {
+				lag = 0
+			}
+
+			lags = append(lags, PartitionLag{
+				Topic:           topic,
+				Partition:       partition,
+				CommittedOffset: block.Offset,
+				HighWaterMark:   hwm,
+				Lag:             lag,
+			})
+		}
+	}
+
+	sort.Slice(lags, func(i, j int) bool {
+		if lags[i].Topic != lags[j].Topic {
+			return lags[i].Topic < lags[j].Topic
+		}
+		return lags[i].Partition < lags[j].Partition
+	})
+
+	return lags, nil
+}
+
+// DescribeCluster implements TopicAdmin.
+func (a *topicAdmin) DescribeCluster() (*ClusterInfo, error) {
+	brokers, controllerId, err := a.admin.DescribeCluster()
+	if err != nil {
+		return nil, err
+	}
+
+	info := &ClusterInfo{
+		ControllerId: controllerId,
+		Brokers:      make([]string, 0, len(brokers)),
+	}
+	for _, b := range brokers {
+		info.Brokers = append(info.Brokers, b.Addr())
+	}
+
+	return info, nil
+}
+
+// Close implements TopicAdmin. The underlying client is closed as well.
+func (a *topicAdmin) Close() error {
+	return a.admin.Close()
+}
+
+func (cfg TopicConfig) configEntries() map[string]*string {
+	entries := make(map[string]*string, len(cfg.Configs)+2)
+
+	for key, value := range cfg.Configs {
+		v := value
+		entries[key] = &v
+	}
+
+	if cfg.Retention != 0 {
+		retention := "-1"
+		if cfg.Retention > 0 {
+			retention = strconv.FormatInt(cfg.Retention.Milliseconds(), 10)
+		}
+		entries[topicConfigRetentionMs] = &retention
+	}
+
+	if cfg.Compacted {
+		policy := CleanupPolicyCompact
+		entries[topicConfigCleanupPolicy] = &policy
+	}
+
+	return entries
+}
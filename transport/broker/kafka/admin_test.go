@@ -0,0 +1,176 @@
+package kafka
+
+import (
+	"testing"
+	"time"
+
+	"github.com/IBM/sarama"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type fakeClusterAdmin struct {
+	sarama.ClusterAdmin
+
+	topics     map[string]sarama.TopicDetail
+	offsets    *sarama.OffsetFetchResponse
+	groups     []*sarama.GroupDescription
+	altered    map[string]map[string]*string
+	partitions map[string]int32
+}
+
+func (f *fakeClusterAdmin) ListTopics() (map[string]sarama.TopicDetail, error) {
+	return f.topics, nil
+}
+
+func (f *fakeClusterAdmin) CreateTopic(topic string, detail *sarama.TopicDetail, validateOnly bool) error {
+	f.topics[topic] = *detail
+	return nil
+}
+
+func (f *fakeClusterAdmin) CreatePartitions(topic string, count int32, assignment [][]int32, validateOnly bool) error {
+	f.partitions[topic] = count
+	return nil
+}
+
+func (f *fakeClusterAdmin) AlterConfig(resourceType sarama.ConfigResourceType, name string, entries map[string]*string, validateOnly bool) error {
+	f.altered[name] = entries
+	return nil
+}
+
+func (f *fakeClusterAdmin) DescribeConsumerGroups(groups []string) ([]*sarama.GroupDescription, error) {
+	return f.groups, nil
+}
+
+func (f *fakeClusterAdmin) ListConsumerGroupOffsets(group string, topicPartitions map[string][]int32) (*sarama.OffsetFetchResponse, error) {
+	return f.offsets, nil
+}
+
+type fakeClient struct {
+	sarama.Client
+
+	hwm map[string]map[int32]int64
+}
+
+func (f *fakeClient) GetOffset(topic string, partition int32, time int64) (int64, error) {
+	return f.hwm[topic][partition], nil
+}
+
+func newFakeAdmin() (*topicAdmin, *fakeClusterAdmin, *fakeClient) {
+	fa := &fakeClusterAdmin{
+		topics:     map[string]sarama.TopicDetail{},
+		altered:    map[string]map[string]*string{},
+		partitions: map[string]int32{},
+	}
+	fc := &fakeClient{
+		hwm: map[string]map[int32]int64{},
+	}
+	return &topicAdmin{admin: fa, client: fc}, fa, fc
+}
+
+func TestEnsureTopicCreatesMissingTopic(t *testing.T) {
+	admin, fa, _ := newFakeAdmin()
+
+	err := admin.EnsureTopic(TopicConfig{
+		Name:       "orders",
+		Partitions: 3,
+		Retention:  time.Hour,
+		Compacted:  true,
+	})
+	require.NoError(t, err)
+
+	detail, ok := fa.topics["orders"]
+	require.True(t, ok)
+	assert.Equal(t, int32(3), detail.NumPartitions)
+	assert.Equal(t, int16(defaultReplicationFactor), detail.ReplicationFactor)
+	assert.Equal(t, "3600000", *detail.ConfigEntries[topicConfigRetentionMs])
+	assert.Equal(t, CleanupPolicyCompact, *detail.ConfigEntries[topicConfigCleanupPolicy])
+}
+
+func TestEnsureTopicUpdatesExistingTopic(t *testing.T) {
+	admin, fa, _ := newFakeAdmin()
+
+	minIsr := "1"
+	fa.topics["orders"] = sarama.TopicDetail{
+		NumPartitions:     2,
+		ReplicationFactor: 1,
+		ConfigEntries: map[string]*string{
+			"min.insync.replicas": &minIsr,
+		},
+	}
+
+	err := admin.EnsureTopic(TopicConfig{
+		Name:       "orders",
+		Partitions: 4,
+		Retention:  -1,
+	})
+	require.NoError(t, err)
+
+	assert.Equal(t, int32(4), fa.partitions["orders"])
+	entries := fa.altered["orders"]
+	require.NotNil(t, entries)
+	assert.Equal(t, "-1", *entries[topicConfigRetentionMs])
+	assert.Equal(t, "1", *entries["min.insync.replicas"])
+}
+
+func TestEnsureTopicRejectsShrinking(t *testing.T) {
+	admin, fa, _ := newFakeAdmin()
+	fa.topics["orders"] = sarama.TopicDetail{NumPartitions: 4, ReplicationFactor: 1}
+
+	err := admin.EnsureTopic(TopicConfig{Name: "orders", Partitions: 2})
+	assert.Error(t, err)
+}
+
+func TestReconcile(t *testing.T) {
+	admin, fa, _ := newFakeAdmin()
+
+	topology, err := ParseTopicTopology([]byte(`
+topics:
+  - name: orders
+    partitions: 6
+    replicationFactor: 1
+    retention: 168h
+  - name: customers
+    compacted: true
+    configs:
+      min.insync.replicas: "1"
+`))
+	require.NoError(t, err)
+
+	require.NoError(t, admin.Reconcile(*topology))
+
+	assert.Equal(t, int32(6), fa.topics["orders"].NumPartitions)
+	assert.Equal(t, "604800000", *fa.topics["orders"].ConfigEntries[topicConfigRetentionMs])
+	assert.Equal(t, "1", *fa.topics["customers"].ConfigEntries["min.insync.replicas"])
+}
+
+func TestDescribeConsumerGroupLag(t *testing.T) {
+	admin, fa, fc := newFakeAdmin()
+
+	fa.groups = []*sarama.GroupDescription{
+		{
+			GroupId: "group",
+			State:   "Stable",
+			Members: map[string]*sarama.GroupMemberDescription{"member-1": {}},
+		},
+	}
+	fa.offsets = &sarama.OffsetFetchResponse{
+		Blocks: map[string]map[int32]*sarama.OffsetFetchResponseBlock{
+			"orders": {
+				0: {Offset: 10},
+				1: {Offset: -1},
+			},
+		},
+	}
+	fc.hwm["orders"] = map[int32]int64{0: 15, 1: 3}
+
+	info, err := admin.DescribeConsumerGroup("group")
+	require.NoError(t, err)
+
+	assert.Equal(t, "Stable", info.State)
+	assert.Equal(t, 1, info.Members)
+	assert.Equal(t, int64(8), info.TotalLag)
+	require.Len(t, info.Lags, 2)
+	assert.Equal(t, int64(5), info.Lags[0].Lag)
+	assert.Equal(t, int64(3), info.Lags[1].Lag)
+}
@@ -1,13 +1,14 @@
 package kafka
 
 import (
+	"context"
+
 	"github.com/IBM/sarama"
-	"github.com/google/uuid"
 	"github.com/lengocson131002/go-clean-core/transport/broker"
 )
 
 const (
-	CorrelationIdHeader = "correlationId"
+	CorrelationIdHeader = broker.CorrelationIdHeader
 )
 
 type Marshaler interface {
@@ -25,20 +26,17 @@ type Codec interface {
 
 type DefaultMarshaler struct{}
 
+// Marshal fills the missing message id, correlation id and timestamp of the message envelope
 func (DefaultMarshaler) Marshal(topic string, msg *broker.Message) (*sarama.ProducerMessage, error) {
-	if len(msg.Headers) == 0 {
-		msg.Headers = make(map[string]string)
-	}
+	broker.PopulateEnvelope(context.Background(), msg)
 
-	correlationId, ok := msg.Headers[CorrelationIdHeader]
-	if !ok || len(correlationId) == 0 {
-		correlationId = uuid.New().String()
-		msg.Headers[CorrelationIdHeader] = correlationId
-	}
+	return newProducerMessage(topic, msg.Headers, msg.Body), nil
+}
 
+func newProducerMessage(topic string, msgHeaders map[string]string, body []byte) *sarama.ProducerMessage {
 	headers := []sarama.RecordHeader{}
 
-	for key, value := range msg.Headers {
+	for key, value := range msgHeaders {
 		headers = append(headers, sarama.RecordHeader{
 			Key:   []byte(key),
 			Value: []byte(value),
@@ -47,9 +45,9 @@ func (DefaultMarshaler) Marshal(topic string, msg *broker.Message) (*sarama.Prod
 
 	return &sarama.ProducerMessage{
 		Topic:   topic,
-		Value:   sarama.ByteEncoder(msg.Body),
+		Value:   sarama.ByteEncoder(body),
 		Headers: headers,
-	}, nil
+	}
 }
 
 func (DefaultMarshaler) Unmarshal(kafkaMsg *sarama.ConsumerMessage) (*broker.Message, error) {
@@ -64,3 +62,28 @@ func (DefaultMarshaler) Unmarshal(kafkaMsg *sarama.ConsumerMessage) (*broker.Mes
 		Body:    []byte(kafkaMsg.Value),
 	}, nil
 }
+
+// CloudEventsMarshaler writes the message envelope as CloudEvents binary content mode headers,
+// e.g. `ce_id`, `ce_type`, `ce_source`, and maps them back on consume
+type CloudEventsMarshaler struct{}
+
+func (CloudEventsMarshaler) Marshal(topic string, msg *broker.Message) (*sarama.ProducerMessage, error) {
+	broker.PopulateEnvelope(context.Background(), msg)
+
+	return newProducerMessage(topic, broker.ToCloudEventsHeaders(msg.Headers), msg.Body), nil
+}
+
+func (CloudEventsMarshaler) Unmarshal(kafkaMsg *sarama.ConsumerMessage) (*broker.Message, error) {
+	msg, err := DefaultMarshaler{}.Unmarshal(kafkaMsg)
+	if err != nil {
+		return nil, err
+	}
+
+	msg.Headers = broker.FromCloudEventsHeaders(msg.Headers)
+	return msg, nil
+}
+
+var (
+	_ Codec = DefaultMarshaler{}
+	_ Codec = CloudEventsMarshaler{}
+)
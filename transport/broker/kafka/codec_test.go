@@ -89,3 +89,36 @@ func TestUnmarshaler(t *testing.T) {
 	}
 
 }
+
+func TestCloudEventsMarshaler(t *testing.T) {
+	c := CloudEventsMarshaler{}
+	msg := &broker.Message{
+		Headers: map[string]string{broker.MessageTypeHeader: "t24.account.created"},
+		Body:    []byte("value"),
+	}
+
+	kMsg, err := c.Marshal("topic", msg)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	consumed := &sarama.ConsumerMessage{Topic: "topic"}
+	recorded := make(map[string]string)
+	for i := range kMsg.Headers {
+		h := kMsg.Headers[i]
+		recorded[string(h.Key)] = string(h.Value)
+		consumed.Headers = append(consumed.Headers, &h)
+	}
+
+	if recorded["ce_type"] != "t24.account.created" || recorded["ce_specversion"] != "1.0" || len(recorded["ce_id"]) == 0 {
+		t.Errorf("Expected CloudEvents headers, got %v", recorded)
+	}
+
+	m, err := c.Unmarshal(consumed)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if m.Envelope().Type != "t24.account.created" || m.Envelope().Id != msg.Headers[broker.MessageIdHeader] {
+		t.Errorf("Expected the envelope to be mapped back, got %v", m.Headers)
+	}
+}
@@ -0,0 +1,87 @@
+package kafka
+
+import (
+	"github.com/IBM/sarama"
+	"github.com/lengocson131002/go-clean-core/transport/broker"
+)
+
+// codecProducer publishes with a compression codec other than the one of the broker config.
+// Sarama sets the codec per producer, so each codec gets its own client and producer.
+type codecProducer struct {
+	c  sarama.Client
+	p  sarama.SyncProducer
+	ap sarama.AsyncProducer
+}
+
+func (cp *codecProducer) close() error {
+	if cp.p != nil {
+		cp.p.Close()
+	}
+	if cp.ap != nil {
+		cp.ap.Close()
+	}
+	return cp.c.Close()
+}
+
+// getCodecProducer returns the producer of the codec, it is created on first use
+func (k *kBroker) getCodecProducer(codec sarama.CompressionCodec) (*codecProducer, error) {
+	k.cpMutex.Lock()
+	defer k.cpMutex.Unlock()
+
+	if cp, ok := k.codecProducers[codec]; ok {
+		return cp, nil
+	}
+
+	config := *k.getBrokerConfig()
+	config.Producer.Compression = codec
+	config.Producer.CompressionLevel = sarama.CompressionLevelDefault
+	config.Producer.Return.Successes = true
+	config.Producer.Return.Errors = true
+
+	c, err := sarama.NewClient(k.addrs, &config)
+	if err != nil {
+		return nil, err
+	}
+
+	p, ap, err := k.newProducers(c)
+	if err != nil {
+		c.Close()
+		return nil, err
+	}
+
+	cp := &codecProducer{c: c, p: p, ap: ap}
+	if k.codecProducers == nil {
+		k.codecProducers = make(map[sarama.CompressionCodec]*codecProducer)
+	}
+	k.codecProducers[codec] = cp
+	return cp, nil
+}
+
+func (k *kBroker) closeCodecProducers() {
+	k.cpMutex.Lock()
+	defer k.cpMutex.Unlock()
+
+	for codec, cp := range k.codecProducers {
+		if err := cp.close(); err != nil {
+			k.getLogger().Errorf(k.opts.Context, "failed to close %s producer: %s", codec, err)
+		}
+	}
+	k.codecProducers = nil
+}
+
+// getCompression returns the codec of the publish option, else the codec configured for the topic
+func (k *kBroker) getCompression(topic string, options broker.PublishOptions) (sarama.CompressionCodec, bool) {
+	if options.Context != nil {
+		if codec, ok := options.Context.Value(compressionKey{}).(sarama.CompressionCodec); ok {
+			return codec, true
+		}
+	}
+
+	if codecs, ok := k.opts.Context.Value(topicCompressionKey{}).(map[string]sarama.CompressionCodec); ok {
+		if codec, ok := codecs[topic]; ok {
+			return codec, true
+		}
+	}
+
+	return sarama.CompressionNone, false
+}
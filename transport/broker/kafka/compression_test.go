@@ -0,0 +1,100 @@
+package kafka
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/IBM/sarama"
+	"github.com/IBM/sarama/mocks"
+	"github.com/lengocson131002/go-clean-core/transport/broker"
+	"github.com/lengocson131002/go-clean-core/transport/broker/claimcheck"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGetCompression(t *testing.T) {
+	k := NewKafkaBroker(TopicCompression(map[string]sarama.CompressionCodec{
+		"t24.responses": sarama.CompressionZSTD,
+	})).(*kBroker)
+
+	apply := func(opts ...broker.PublishOption) broker.PublishOptions {
+		options := broker.PublishOptions{}
+		for _, o := range opts {
+			o(&options)
+		}
+		return options
+	}
+
+	codec, ok := k.getCompression("t24.responses", apply())
+	assert.True(t, ok)
+	assert.Equal(t, sarama.CompressionZSTD, codec)
+
+	codec, ok = k.getCompression("t24.responses", apply(Compression(sarama.CompressionGZIP)))
+	assert.True(t, ok)
+	assert.Equal(t, sarama.CompressionGZIP, codec)
+
+	_, ok = k.getCompression("other", apply())
+	assert.False(t, ok)
+}
+
+func TestPublishMessageTooLarge(t *testing.T) {
+	k := NewKafkaBroker(MaxMessageBytes(10)).(*kBroker)
+	k.p = mocks.NewSyncProducer(t, nil)
+
+	err := k.Publish(context.Background(), "topic", &broker.Message{Body: []byte(strings.Repeat("x", 11))})
+
+	var tooLarge broker.MessageTooLargeError
+	require.True(t, errors.As(err, &tooLarge))
+	assert.Equal(t, 11, tooLarge.Size)
+	assert.Equal(t, 10, tooLarge.Limit)
+}
+
+func TestPublishClaimCheck(t *testing.T) {
+	var (
+		store = claimcheck.NewMemoryStore()
+		p     = mocks.NewSyncProducer(t, nil)
+		body  = []byte(strings.Repeat("x", 100))
+		sent  *sarama.ProducerMessage
+	)
+
+	k := NewKafkaBroker(MaxMessageBytes(20), ClaimCheck(store, 10)).(*kBroker)
+	k.p = p
+
+	p.ExpectSendMessageWithMessageCheckerFunctionAndSucceed(func(msg *sarama.ProducerMessage) error {
+		sent = msg
+		return nil
+	})
+
+	msg := &broker.Message{Body: body}
+	require.NoError(t, k.Publish(context.Background(), "topic", msg))
+	assert.NotEmpty(t, msg.Headers[CorrelationIdHeader])
+
+	// the subscriber resolves the reference to the stored body
+	received, err := k.codec.Unmarshal(&sarama.ConsumerMessage{
+		Topic:   "topic",
+		Headers: toRecordHeaders(sent.Headers),
+	})
+	require.NoError(t, err)
+	assert.Empty(t, received.Body)
+	assert.NotEmpty(t, received.Headers[claimcheck.ReferenceHeader])
+
+	var handled *broker.Message
+	h := broker.ChainSubscribeMiddlewares(func(ctx context.Context, e broker.Event) error {
+		handled = e.Message()
+		return nil
+	}, k.getSubscribeMiddlewares()...)
+
+	require.NoError(t, h(context.Background(), &publication{t: "topic", m: received}))
+	assert.Equal(t, body, handled.Body)
+	assert.NotContains(t, handled.Headers, claimcheck.ReferenceHeader)
+}
+
+func toRecordHeaders(headers []sarama.RecordHeader) []*sarama.RecordHeader {
+	result := make([]*sarama.RecordHeader, 0, len(headers))
+	for i := range headers {
+		result = append(result, &headers[i])
+	}
+	return result
+}
@@ -3,55 +3,153 @@ package kafka
 import (
 	"crypto/tls"
 	"crypto/x509"
+	"errors"
 	"fmt"
 	"os"
+	"strings"
 
 	"github.com/IBM/sarama"
 	"github.com/lengocson131002/go-clean-core/transport/broker"
 )
 
+const (
+	SASLMechanismPlain       = sarama.SASLTypePlaintext
+	SASLMechanismScramSHA256 = sarama.SASLTypeSCRAMSHA256
+	SASLMechanismScramSHA512 = sarama.SASLTypeSCRAMSHA512
+	SASLMechanismOAuthBearer = sarama.SASLTypeOAuth
+	SASLMechanismGSSAPI      = sarama.SASLTypeGSSAPI
+
+	GSSAPIAuthTypeUser   = "user"
+	GSSAPIAuthTypeKeytab = "keytab"
+	GSSAPIAuthTypeCCache = "ccache"
+)
+
 type KafkaBrokerConfig struct {
 	Addresses []string
 
-	SASLEnabled   bool
+	SASLEnabled bool
+	// SASLMechanism is one of PLAIN, SCRAM-SHA-256, SCRAM-SHA-512, OAUTHBEARER or GSSAPI.
+	// When empty, SCRAM is used with the hash of SASLAlgorithm.
+	SASLMechanism string
 	SASLUser      string
 	SASLPassword  string
+	// SASLAlgorithm is the SCRAM hash, either "sha256" or "sha512"
 	SASLAlgorithm string
+	// SASLTokenProvider provides the tokens of the OAUTHBEARER mechanism
+	SASLTokenProvider sarama.AccessTokenProvider
+	SASLGSSAPI        GSSAPIConfig
 
+	// TLS material is loaded from PEM content first, then from the environment variable, then from the file
 	TLSEnabled        bool
 	TLSSkipVerify     bool
 	TLSClientCertFile string
 	TLSClientKeyFile  string
 	TLSCaCertFile     string
+	TLSClientCertPEM  string
+	TLSClientKeyPEM   string
+	TLSCaCertPEM      string
+	TLSClientCertEnv  string
+	TLSClientKeyEnv   string
+	TLSCaCertEnv      string
+}
+
+// GSSAPIConfig is the Kerberos configuration of the GSSAPI mechanism
+type GSSAPIConfig struct {
+	// AuthType is one of "user" (password), "keytab" or "ccache"
+	AuthType           string
+	KerberosConfigPath string
+	ServiceName        string
+	Realm              string
+	Username           string
+	Password           string
+	KeyTabPath         string
+	CCachePath         string
+	DisablePAFXFAST    bool
 }
 
-func createTLSConfiguration(certFile string, keyFile string, caFile string, skipVerify bool) (*tls.Config, error) {
+// TokenProviderFunc adapts a function to sarama.AccessTokenProvider
+type TokenProviderFunc func() (*sarama.AccessToken, error)
+
+func (f TokenProviderFunc) Token() (*sarama.AccessToken, error) {
+	return f()
+}
+
+func createTLSConfiguration(cfg *KafkaBrokerConfig) (*tls.Config, error) {
 	t := &tls.Config{
-		InsecureSkipVerify: skipVerify,
+		InsecureSkipVerify: cfg.TLSSkipVerify,
 	}
 
-	if certFile != "" && keyFile != "" && caFile != "" {
-		cert, err := tls.LoadX509KeyPair(certFile, keyFile)
-		if err != nil {
-			return nil, err
-		}
+	cert, err := loadPEM(cfg.TLSClientCertPEM, cfg.TLSClientCertEnv, cfg.TLSClientCertFile)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load client certificate: %w", err)
+	}
+
+	key, err := loadPEM(cfg.TLSClientKeyPEM, cfg.TLSClientKeyEnv, cfg.TLSClientKeyFile)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load client key: %w", err)
+	}
 
-		caCert, err := os.ReadFile(caFile)
+	if len(cert) != 0 || len(key) != 0 {
+		pair, err := tls.X509KeyPair(cert, key)
 		if err != nil {
 			return nil, err
 		}
+		t.Certificates = []tls.Certificate{pair}
+	}
 
-		caCertPool := x509.NewCertPool()
-		caCertPool.AppendCertsFromPEM(caCert)
+	caCert, err := loadPEM(cfg.TLSCaCertPEM, cfg.TLSCaCertEnv, cfg.TLSCaCertFile)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load CA certificate: %w", err)
+	}
 
-		t.Certificates = []tls.Certificate{cert}
+	if len(caCert) != 0 {
+		caCertPool := x509.NewCertPool()
+		if !caCertPool.AppendCertsFromPEM(caCert) {
+			return nil, errors.New("no valid CA certificate found")
+		}
 		t.RootCAs = caCertPool
 	}
 
 	return t, nil
 }
 
+// loadPEM returns the PEM content, the value of the environment variable or the content of the file, in this order
+func loadPEM(content string, env string, file string) ([]byte, error) {
+	if len(content) != 0 {
+		return []byte(content), nil
+	}
+
+	if len(env) != 0 {
+		if value, ok := os.LookupEnv(env); ok && len(value) != 0 {
+			return []byte(value), nil
+		}
+	}
+
+	if len(file) != 0 {
+		return os.ReadFile(file)
+	}
+
+	return nil, nil
+}
+
 func GetKafkaBroker(cfg *KafkaBrokerConfig, opts ...broker.BrokerOption) (broker.Broker, error) {
+	conf, err := newSaramaConfig(cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	opts = append(
+		opts,
+		broker.WithBrokerAddresses(cfg.Addresses...),
+		BrokerConfig(conf),
+		ClusterConfig(conf))
+
+	return NewKafkaBroker(opts...), nil
+
+}
+
+// newSaramaConfig builds the sarama configuration shared by the broker and the admin client
+func newSaramaConfig(cfg *KafkaBrokerConfig) (*sarama.Config, error) {
 	conf := sarama.NewConfig()
 	conf.Producer.Retry.Max = 1
 	conf.Producer.RequiredAcks = sarama.WaitForAll
@@ -60,33 +158,16 @@ func GetKafkaBroker(cfg *KafkaBrokerConfig, opts ...broker.BrokerOption) (broker
 
 	// Config SASL
 	if cfg.SASLEnabled {
-		conf.Net.SASL.Enable = true
-		conf.Net.SASL.User = cfg.SASLUser
-		conf.Net.SASL.Password = cfg.SASLPassword
-		conf.Net.SASL.Handshake = true
-		if cfg.SASLAlgorithm == "sha512" {
-			conf.Net.SASL.SCRAMClientGeneratorFunc = func() sarama.SCRAMClient { return &XDGSCRAMClient{HashGeneratorFcn: SHA512} }
-			conf.Net.SASL.Mechanism = sarama.SASLTypeSCRAMSHA512
-		} else if cfg.SASLAlgorithm == "sha256" {
-			conf.Net.SASL.SCRAMClientGeneratorFunc = func() sarama.SCRAMClient { return &XDGSCRAMClient{HashGeneratorFcn: SHA256} }
-			conf.Net.SASL.Mechanism = sarama.SASLTypeSCRAMSHA256
-
-		} else {
-			return nil, fmt.Errorf("invalid SHA algorithm \"%s\": can be either \"sha256\" or \"sha512\"", cfg.SASLAlgorithm)
+		if err := configureSASL(conf, cfg); err != nil {
+			return nil, err
 		}
-
 	}
 
 	// Config TLS
 	if cfg.TLSEnabled {
 		conf.Net.TLS.Enable = true
 
-		tlsConfig, err := createTLSConfiguration(
-			cfg.TLSClientCertFile,
-			cfg.TLSClientKeyFile,
-			cfg.TLSCaCertFile,
-			cfg.TLSSkipVerify)
-
+		tlsConfig, err := createTLSConfiguration(cfg)
 		if err != nil {
 			return nil, fmt.Errorf("failed to create TLS configuration: %w", err)
 		}
@@ -94,12 +175,103 @@ func GetKafkaBroker(cfg *KafkaBrokerConfig, opts ...broker.BrokerOption) (broker
 		conf.Net.TLS.Config = tlsConfig
 
 	}
-	opts = append(
-		opts,
-		broker.WithBrokerAddresses(cfg.Addresses...),
-		BrokerConfig(conf),
-		ClusterConfig(conf))
 
-	return NewKafkaBroker(opts...), nil
+	return conf, nil
+}
+
+func configureSASL(conf *sarama.Config, cfg *KafkaBrokerConfig) error {
+	conf.Net.SASL.Enable = true
+	conf.Net.SASL.User = cfg.SASLUser
+	conf.Net.SASL.Password = cfg.SASLPassword
+	conf.Net.SASL.Handshake = true
+
+	mechanism := strings.ToUpper(cfg.SASLMechanism)
+	if len(mechanism) == 0 {
+		switch cfg.SASLAlgorithm {
+		case "sha512":
+			mechanism = SASLMechanismScramSHA512
+		case "sha256":
+			mechanism = SASLMechanismScramSHA256
+		default:
+			return fmt.Errorf("invalid SHA algorithm \"%s\": can be either \"sha256\" or \"sha512\"", cfg.SASLAlgorithm)
+		}
+	}
+
+	switch mechanism {
+	case SASLMechanismPlain:
+		conf.Net.SASL.Mechanism = sarama.SASLTypePlaintext
+	case SASLMechanismScramSHA512:
+		conf.Net.SASL.SCRAMClientGeneratorFunc = func() sarama.SCRAMClient { return &XDGSCRAMClient{HashGeneratorFcn: SHA512} }
+		conf.Net.SASL.Mechanism = sarama.SASLTypeSCRAMSHA512
+	case SASLMechanismScramSHA256:
+		conf.Net.SASL.SCRAMClientGeneratorFunc = func() sarama.SCRAMClient { return &XDGSCRAMClient{HashGeneratorFcn: SHA256} }
+		conf.Net.SASL.Mechanism = sarama.SASLTypeSCRAMSHA256
+	case SASLMechanismOAuthBearer:
+		if cfg.SASLTokenProvider == nil {
+			return errors.New("SASL mechanism OAUTHBEARER requires a token provider")
+		}
+		conf.Net.SASL.Mechanism = sarama.SASLTypeOAuth
+		conf.Net.SASL.TokenProvider = cfg.SASLTokenProvider
+	case SASLMechanismGSSAPI:
+		gssapi, err := newGSSAPIConfig(cfg.SASLGSSAPI)
+		if err != nil {
+			return err
+		}
+		conf.Net.SASL.Mechanism = sarama.SASLTypeGSSAPI
+		conf.Net.SASL.GSSAPI = gssapi
+	default:
+		return fmt.Errorf("invalid SASL mechanism \"%s\": can be one of \"%s\", \"%s\", \"%s\", \"%s\" or \"%s\"",
+			cfg.SASLMechanism,
+			SASLMechanismPlain,
+			SASLMechanismScramSHA256,
+			SASLMechanismScramSHA512,
+			SASLMechanismOAuthBearer,
+			SASLMechanismGSSAPI)
+	}
+
+	return nil
+}
+
+func newGSSAPIConfig(cfg GSSAPIConfig) (sarama.GSSAPIConfig, error) {
+	gssapi := sarama.GSSAPIConfig{
+		KerberosConfigPath: cfg.KerberosConfigPath,
+		ServiceName:        cfg.ServiceName,
+		Realm:              cfg.Realm,
+		Username:           cfg.Username,
+		Password:           cfg.Password,
+		KeyTabPath:         cfg.KeyTabPath,
+		CCachePath:         cfg.CCachePath,
+		DisablePAFXFAST:    cfg.DisablePAFXFAST,
+	}
+
+	switch strings.ToLower(cfg.AuthType) {
+	case GSSAPIAuthTypeUser, "":
+		gssapi.AuthType = sarama.KRB5_USER_AUTH
+	case GSSAPIAuthTypeKeytab:
+		gssapi.AuthType = sarama.KRB5_KEYTAB_AUTH
+	case GSSAPIAuthTypeCCache:
+		gssapi.AuthType = sarama.KRB5_CCACHE_AUTH
+	default:
+		return gssapi, fmt.Errorf("invalid GSSAPI auth type \"%s\": can be one of \"%s\", \"%s\" or \"%s\"",
+			cfg.AuthType, GSSAPIAuthTypeUser, GSSAPIAuthTypeKeytab, GSSAPIAuthTypeCCache)
+	}
+
+	if len(gssapi.ServiceName) == 0 {
+		gssapi.ServiceName = "kafka"
+	}
+
+	return gssapi, nil
+}
+
+// withTLSConfig returns a copy of the config using the TLS configuration of the broker options,
+// unless the sarama config has its own TLS configuration
+func withTLSConfig(config *sarama.Config, tlsConfig *tls.Config) *sarama.Config {
+	if tlsConfig == nil || config.Net.TLS.Config != nil {
+		return config
+	}
 
+	copied := *config
+	copied.Net.TLS.Enable = true
+	copied.Net.TLS.Config = tlsConfig
+	return &copied
 }
@@ -0,0 +1,152 @@
+package kafka
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/IBM/sarama"
+	"github.com/lengocson131002/go-clean-core/transport/broker"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func generateCertificate(t *testing.T) (string, string) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "kafka"},
+		NotBefore:             time.Now(),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	require.NoError(t, err)
+
+	keyDer, err := x509.MarshalECPrivateKey(key)
+	require.NoError(t, err)
+
+	certPEM := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})
+	keyPEM := pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDer})
+	return string(certPEM), string(keyPEM)
+}
+
+func TestSASLMechanisms(t *testing.T) {
+	provider := TokenProviderFunc(func() (*sarama.AccessToken, error) {
+		return &sarama.AccessToken{Token: "token"}, nil
+	})
+
+	tests := []struct {
+		name      string
+		cfg       KafkaBrokerConfig
+		mechanism sarama.SASLMechanism
+		wantErr   bool
+	}{
+		{name: "scram by algorithm", cfg: KafkaBrokerConfig{SASLAlgorithm: "sha512"}, mechanism: sarama.SASLTypeSCRAMSHA512},
+		{name: "invalid algorithm", cfg: KafkaBrokerConfig{SASLAlgorithm: "md5"}, wantErr: true},
+		{name: "plain", cfg: KafkaBrokerConfig{SASLMechanism: "plain"}, mechanism: sarama.SASLTypePlaintext},
+		{name: "scram sha256", cfg: KafkaBrokerConfig{SASLMechanism: SASLMechanismScramSHA256}, mechanism: sarama.SASLTypeSCRAMSHA256},
+		{name: "oauthbearer", cfg: KafkaBrokerConfig{SASLMechanism: SASLMechanismOAuthBearer, SASLTokenProvider: provider}, mechanism: sarama.SASLTypeOAuth},
+		{name: "oauthbearer without provider", cfg: KafkaBrokerConfig{SASLMechanism: SASLMechanismOAuthBearer}, wantErr: true},
+		{name: "gssapi", cfg: KafkaBrokerConfig{SASLMechanism: SASLMechanismGSSAPI, SASLGSSAPI: GSSAPIConfig{AuthType: GSSAPIAuthTypeKeytab, KerberosConfigPath: "/etc/krb5.conf", Realm: "EXAMPLE.COM", Username: "user", KeyTabPath: "/etc/kafka.keytab"}}, mechanism: sarama.SASLTypeGSSAPI},
+		{name: "invalid gssapi auth type", cfg: KafkaBrokerConfig{SASLMechanism: SASLMechanismGSSAPI, SASLGSSAPI: GSSAPIConfig{AuthType: "token"}}, wantErr: true},
+		{name: "unknown mechanism", cfg: KafkaBrokerConfig{SASLMechanism: "AWS_MSK_IAM"}, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.cfg.SASLEnabled = true
+			tt.cfg.SASLUser = "user"
+			tt.cfg.SASLPassword = "password"
+			conf, err := newSaramaConfig(&tt.cfg)
+			if tt.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.mechanism, conf.Net.SASL.Mechanism)
+			assert.NoError(t, conf.Validate())
+		})
+	}
+}
+
+func TestGSSAPIConfig(t *testing.T) {
+	conf, err := newSaramaConfig(&KafkaBrokerConfig{
+		SASLEnabled:   true,
+		SASLMechanism: SASLMechanismGSSAPI,
+		SASLGSSAPI: GSSAPIConfig{
+			AuthType:           GSSAPIAuthTypeKeytab,
+			KerberosConfigPath: "/etc/krb5.conf",
+			Realm:              "EXAMPLE.COM",
+			Username:           "kafka-client",
+			KeyTabPath:         "/etc/kafka.keytab",
+		},
+	})
+	require.NoError(t, err)
+
+	assert.Equal(t, sarama.KRB5_KEYTAB_AUTH, conf.Net.SASL.GSSAPI.AuthType)
+	assert.Equal(t, "kafka", conf.Net.SASL.GSSAPI.ServiceName)
+	assert.Equal(t, "/etc/kafka.keytab", conf.Net.SASL.GSSAPI.KeyTabPath)
+}
+
+func TestTLSFromPEMEnvAndFile(t *testing.T) {
+	cert, key := generateCertificate(t)
+
+	dir := t.TempDir()
+	caFile := filepath.Join(dir, "ca.pem")
+	require.NoError(t, os.WriteFile(caFile, []byte(cert), 0600))
+	t.Setenv("KAFKA_CLIENT_KEY", key)
+
+	conf, err := newSaramaConfig(&KafkaBrokerConfig{
+		TLSEnabled:       true,
+		TLSClientCertPEM: cert,
+		TLSClientKeyEnv:  "KAFKA_CLIENT_KEY",
+		TLSCaCertFile:    caFile,
+	})
+	require.NoError(t, err)
+
+	assert.True(t, conf.Net.TLS.Enable)
+	assert.Len(t, conf.Net.TLS.Config.Certificates, 1)
+	assert.NotNil(t, conf.Net.TLS.Config.RootCAs)
+}
+
+func TestTLSInvalidCA(t *testing.T) {
+	_, err := newSaramaConfig(&KafkaBrokerConfig{
+		TLSEnabled:   true,
+		TLSCaCertPEM: "not a certificate",
+	})
+	assert.Error(t, err)
+}
+
+func TestBrokerOptionsTLSConfig(t *testing.T) {
+	tlsConfig := &tls.Config{ServerName: "kafka"}
+
+	k := NewKafkaBroker(broker.WithBrokerTLSConfig(tlsConfig)).(*kBroker)
+
+	config := k.getBrokerConfig()
+	assert.True(t, config.Net.TLS.Enable)
+	assert.Same(t, tlsConfig, config.Net.TLS.Config)
+	assert.Nil(t, DefaultBrokerConfig.Net.TLS.Config, "the default config must not be modified")
+
+	assert.Same(t, tlsConfig, k.getClusterConfig().Net.TLS.Config)
+	assert.Same(t, tlsConfig, k.getSubscribeConfig(broker.SubscribeOptions{}).Net.TLS.Config)
+
+	// the TLS configuration of the sarama config has precedence
+	own := sarama.NewConfig()
+	own.Net.TLS.Enable = true
+	own.Net.TLS.Config = &tls.Config{ServerName: "own"}
+	k = NewKafkaBroker(broker.WithBrokerTLSConfig(tlsConfig), BrokerConfig(own)).(*kBroker)
+	assert.Same(t, own.Net.TLS.Config, k.getBrokerConfig().Net.TLS.Config)
+}
@@ -2,29 +2,61 @@ package kafka
 
 import (
 	"context"
+	"sync"
+	"time"
 
 	"github.com/IBM/sarama"
 	"github.com/lengocson131002/go-clean-core/logger"
 	"github.com/lengocson131002/go-clean-core/transport/broker"
+	"github.com/lengocson131002/go-clean-core/transport/broker/flowcontrol"
 )
 
 // consumerGroupHandler is the implementation of sarama.ConsumerGroupHandler
 type consumerGroupHandler struct {
-	logger  logger.Logger
-	handler broker.Handler
-	subopts broker.SubscribeOptions
-	kopts   broker.BrokerOptions
-	cg      sarama.ConsumerGroup
-	ready   chan bool
-	codec   Codec
+	logger   logger.Logger
+	handler  broker.Handler
+	schedule *priorityScheduler
+	limiter  *flowcontrol.ConcurrencyLimiter
+	subopts  broker.SubscribeOptions
+	kopts    broker.BrokerOptions
+	cg       sarama.ConsumerGroup
+	ready    chan bool
+	codec    Codec
+	metrics  *Metrics
+
+	assigned PartitionsHook
+	revoked  PartitionsHook
+
+	readyOnce sync.Once
+	mutex     sync.Mutex
+	closing   chan struct{}
+	closed    bool
+	inflight  sync.WaitGroup
+	session   sarama.ConsumerGroupSession
 }
 
-func (h *consumerGroupHandler) Setup(sarama.ConsumerGroupSession) error {
-	close(h.ready)
+func (h *consumerGroupHandler) Setup(session sarama.ConsumerGroupSession) error {
+	h.mutex.Lock()
+	h.session = session
+	h.mutex.Unlock()
+
+	if h.assigned != nil {
+		h.assigned(session.Context(), session.Claims())
+	}
+
+	h.readyOnce.Do(func() {
+		close(h.ready)
+	})
 	return nil
 }
 
-func (*consumerGroupHandler) Cleanup(_ sarama.ConsumerGroupSession) error {
+func (h *consumerGroupHandler) Cleanup(session sarama.ConsumerGroupSession) error {
+	if h.revoked != nil {
+		h.revoked(session.Context(), session.Claims())
+	}
+
+	// all claims are finished here, flush marked offsets before partitions are reassigned
+	session.Commit()
 	return nil
 }
 
@@ -43,28 +75,143 @@ func (h *consumerGroupHandler) ConsumeClaim(session sarama.ConsumerGroupSession,
 				continue
 			}
 
-			m, err := h.codec.Unmarshal(msg)
-			if err != nil {
-				h.logger.Errorf(ctx, "[kafka consumer]: failed to unmarshal consumed message: %v", err)
-				continue
+			// stop taking messages once draining started, the message will be redelivered
+			if !h.begin() {
+				return nil
+			}
+
+			h.metrics.observeConsume(h.subopts.Group, msg, claim)
+			if h.limiter != nil && !h.acquire(session, msg) {
+				h.inflight.Done()
+				return nil
 			}
 
-			p := &publication{m: m, t: msg.Topic, km: msg, cg: h.cg, sess: session}
-
-			err = h.handler(ctx, p)
-			if err == nil && h.subopts.AutoAck {
-				session.MarkMessage(msg, "")
-			} else if err != nil {
-				p.err = err
-				errHandler := h.kopts.ErrorHandler
-				if errHandler != nil {
-					errHandler(ctx, p)
-				} else {
-					h.logger.Errorf(ctx, "[kafka] subscriber error: %v", err)
-				}
+			start := time.Now()
+			err := h.handle(ctx, session, msg)
+			if h.limiter != nil {
+				h.limiter.Release(time.Since(start), err)
 			}
+			h.inflight.Done()
+		case <-h.closing:
+			return nil
 		case <-session.Context().Done():
 			return nil
 		}
 	}
 }
+
+// acquire takes a slot of the in-flight limiter. The partition is paused while waiting, so its messages
+// are not fetched and buffered meanwhile. It returns false when the session ends or draining started.
+func (h *consumerGroupHandler) acquire(session sarama.ConsumerGroupSession, msg *sarama.ConsumerMessage) bool {
+	if h.limiter.TryAcquire() {
+		return true
+	}
+
+	partitions := map[string][]int32{msg.Topic: {msg.Partition}}
+	h.cg.Pause(partitions)
+	defer func() {
+		// draining pauses all partitions, keep them paused
+		if !h.isClosed() {
+			h.cg.Resume(partitions)
+		}
+	}()
+
+	ctx, cancel := context.WithCancel(session.Context())
+	defer cancel()
+	go func() {
+		select {
+		case <-h.closing:
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
+
+	return h.limiter.Acquire(ctx) == nil
+}
+
+// handle calls the handler with the message, it returns the error of the handler
+func (h *consumerGroupHandler) handle(ctx context.Context, session sarama.ConsumerGroupSession, msg *sarama.ConsumerMessage) error {
+	// wait for the turn of the priority of the topic, the message is redelivered when draining started meanwhile
+	if h.schedule != nil {
+		if !h.schedule.acquire(session.Context(), h.closing, msg.Topic) {
+			return nil
+		}
+		defer h.schedule.release()
+	}
+
+	m, err := h.codec.Unmarshal(msg)
+	if err != nil {
+		h.logger.Errorf(ctx, "[kafka consumer]: failed to unmarshal consumed message: %v", err)
+		return nil
+	}
+
+	p := &publication{m: m, t: msg.Topic, km: msg, cg: h.cg, sess: session}
+	ctx = broker.ContextWithEnvelope(ctx, m)
+
+	start := time.Now()
+	err = h.handler(ctx, p)
+	h.metrics.observeHandler(msg.Topic, h.subopts.Group, start, err)
+	if err == nil && h.subopts.AutoAck {
+		session.MarkMessage(msg, "")
+	} else if err != nil {
+		p.err = err
+		errHandler := h.kopts.ErrorHandler
+		if errHandler != nil {
+			errHandler(ctx, p)
+		} else {
+			h.logger.Errorf(ctx, "[kafka] subscriber error: %v", err)
+		}
+	}
+	return err
+}
+
+func (h *consumerGroupHandler) isClosed() bool {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+	return h.closed
+}
+
+// begin registers an in-flight message, it returns false when the handler is draining
+func (h *consumerGroupHandler) begin() bool {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+
+	if h.closed {
+		return false
+	}
+	h.inflight.Add(1)
+	return true
+}
+
+// drain stops fetching new messages, waits for in-flight handlers to finish and commits marked offsets
+func (h *consumerGroupHandler) drain(timeout time.Duration) error {
+	h.mutex.Lock()
+	if h.closed {
+		h.mutex.Unlock()
+		return nil
+	}
+	h.closed = true
+	close(h.closing)
+	session := h.session
+	h.mutex.Unlock()
+
+	h.cg.PauseAll()
+
+	done := make(chan struct{})
+	go func() {
+		h.inflight.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(timeout):
+		return broker.DrainTimeoutError{Timeout: timeout}
+	}
+
+	if session != nil {
+		session.Commit()
+	}
+
+	return nil
+}
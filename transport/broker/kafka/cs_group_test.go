@@ -0,0 +1,273 @@
+package kafka
+
+import (
+	"context"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/IBM/sarama"
+	"github.com/lengocson131002/go-clean-core/transport/broker"
+	"github.com/lengocson131002/go-clean-core/transport/broker/flowcontrol"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type fakeConsumerGroup struct {
+	sarama.ConsumerGroup
+	paused           atomic.Bool
+	pausedPartitions atomic.Int32
+}
+
+func (f *fakeConsumerGroup) PauseAll() {
+	f.paused.Store(true)
+}
+
+func (f *fakeConsumerGroup) Pause(partitions map[string][]int32) {
+	f.pausedPartitions.Add(int32(len(partitions)))
+}
+
+func (f *fakeConsumerGroup) Resume(partitions map[string][]int32) {
+	f.pausedPartitions.Add(-int32(len(partitions)))
+}
+
+type fakeSession struct {
+	sarama.ConsumerGroupSession
+	ctx       context.Context
+	marked    atomic.Int64
+	committed atomic.Int64
+}
+
+func (f *fakeSession) Context() context.Context {
+	return f.ctx
+}
+
+func (f *fakeSession) Claims() map[string][]int32 {
+	return map[string][]int32{"topic": {0}}
+}
+
+func (f *fakeSession) MarkMessage(msg *sarama.ConsumerMessage, metadata string) {
+	f.marked.Store(msg.Offset)
+}
+
+func (f *fakeSession) Commit() {
+	f.committed.Store(f.marked.Load())
+}
+
+type fakeClaim struct {
+	sarama.ConsumerGroupClaim
+	messages chan *sarama.ConsumerMessage
+}
+
+func (f *fakeClaim) Messages() <-chan *sarama.ConsumerMessage {
+	return f.messages
+}
+
+func newTestHandler(handler broker.Handler) (*consumerGroupHandler, *fakeConsumerGroup) {
+	cg := &fakeConsumerGroup{}
+	return &consumerGroupHandler{
+		handler: handler,
+		subopts: broker.SubscribeOptions{AutoAck: true},
+		cg:      cg,
+		logger:  DefaultLogger,
+		ready:   make(chan bool),
+		closing: make(chan struct{}),
+		codec:   DefaultMarshaler{},
+	}, cg
+}
+
+func TestDrainWaitsForInflightHandlers(t *testing.T) {
+	var (
+		started  = make(chan struct{})
+		release  = make(chan struct{})
+		assigned map[string][]int32
+		revoked  map[string][]int32
+	)
+
+	h, cg := newTestHandler(func(ctx context.Context, e broker.Event) error {
+		close(started)
+		<-release
+		return nil
+	})
+	h.assigned = func(ctx context.Context, claims map[string][]int32) { assigned = claims }
+	h.revoked = func(ctx context.Context, claims map[string][]int32) { revoked = claims }
+
+	session := &fakeSession{ctx: context.Background()}
+	claim := &fakeClaim{messages: make(chan *sarama.ConsumerMessage, 2)}
+	claim.messages <- &sarama.ConsumerMessage{Topic: "topic", Offset: 7, Value: []byte("1")}
+	claim.messages <- &sarama.ConsumerMessage{Topic: "topic", Offset: 8, Value: []byte("2")}
+
+	require.NoError(t, h.Setup(session))
+	assert.Equal(t, session.Claims(), assigned)
+
+	consumed := make(chan struct{})
+	go func() {
+		h.ConsumeClaim(session, claim)
+		close(consumed)
+	}()
+	<-started
+
+	drained := make(chan error)
+	go func() {
+		drained <- h.drain(time.Second)
+	}()
+
+	select {
+	case <-drained:
+		t.Fatal("drain returned before the in-flight handler finished")
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	close(release)
+	require.NoError(t, <-drained)
+	<-consumed
+
+	assert.True(t, cg.paused.Load())
+	// the second message was not processed and will be redelivered
+	assert.Equal(t, int64(7), session.committed.Load())
+
+	require.NoError(t, h.Cleanup(session))
+	assert.Equal(t, session.Claims(), revoked)
+}
+
+func TestDrainTimeout(t *testing.T) {
+	release := make(chan struct{})
+	defer close(release)
+
+	started := make(chan struct{})
+	h, _ := newTestHandler(func(ctx context.Context, e broker.Event) error {
+		close(started)
+		<-release
+		return nil
+	})
+
+	session := &fakeSession{ctx: context.Background()}
+	claim := &fakeClaim{messages: make(chan *sarama.ConsumerMessage, 1)}
+	claim.messages <- &sarama.ConsumerMessage{Topic: "topic", Value: []byte("1")}
+
+	require.NoError(t, h.Setup(session))
+	go h.ConsumeClaim(session, claim)
+	<-started
+
+	err := h.drain(10 * time.Millisecond)
+	assert.ErrorAs(t, err, &broker.DrainTimeoutError{})
+}
+
+// consumingGroup leaves the group once the consume loop returns, the loop of sarama waits for the handlers
+type consumingGroup struct {
+	sarama.ConsumerGroup
+	consumed chan struct{}
+}
+
+func (f *consumingGroup) Close() error {
+	<-f.consumed
+	return nil
+}
+
+// closingClient records that the client was closed
+type closingClient struct {
+	sarama.Client
+	closed atomic.Bool
+}
+
+func (f *closingClient) Close() error {
+	f.closed.Store(true)
+	return nil
+}
+
+// newStuckSubscriber returns a subscriber whose handler runs until release is closed
+func newStuckSubscriber(t *testing.T, k *kBroker, release chan struct{}) *subscriber {
+	started := make(chan struct{})
+	h, _ := newTestHandler(func(ctx context.Context, e broker.Event) error {
+		close(started)
+		<-release
+		return nil
+	})
+
+	session := &fakeSession{ctx: context.Background()}
+	claim := &fakeClaim{messages: make(chan *sarama.ConsumerMessage, 1)}
+	claim.messages <- &sarama.ConsumerMessage{Topic: "topic", Value: []byte("1")}
+
+	cg := &consumingGroup{consumed: make(chan struct{})}
+	done := make(chan struct{})
+	require.NoError(t, h.Setup(session))
+	go func() {
+		defer close(done)
+		h.ConsumeClaim(session, claim)
+		close(cg.consumed)
+	}()
+	<-started
+
+	return &subscriber{k: k, cg: cg, t: "topic", handler: h, done: done}
+}
+
+func TestCloseTimeoutWithStuckHandler(t *testing.T) {
+	release := make(chan struct{})
+	defer close(release)
+
+	opts := broker.BrokerOptions{}
+	ShutdownTimeout(50 * time.Millisecond)(&opts)
+	k := &kBroker{opts: opts}
+
+	closed := make(chan error)
+	go func() {
+		closed <- newStuckSubscriber(t, k, release).close()
+	}()
+	select {
+	case err := <-closed:
+		assert.ErrorAs(t, err, &broker.DrainTimeoutError{})
+	case <-time.After(time.Second):
+		t.Fatal("close did not return within the shutdown timeout")
+	}
+
+	// disconnecting still closes the client after a subscriber failed to drain
+	client := &closingClient{}
+	k.c = client
+	k.subs = []*subscriber{newStuckSubscriber(t, k, release)}
+	go func() {
+		closed <- k.Disconnect()
+	}()
+	select {
+	case err := <-closed:
+		assert.ErrorAs(t, err, &broker.DrainTimeoutError{})
+		assert.True(t, client.closed.Load())
+	case <-time.After(time.Second):
+		t.Fatal("disconnect did not return within the shutdown timeout")
+	}
+}
+
+func TestMaxInFlightPausesPartitions(t *testing.T) {
+	var (
+		started = make(chan int64, 2)
+		release = make(chan struct{})
+	)
+
+	h, cg := newTestHandler(func(ctx context.Context, e broker.Event) error {
+		started <- 0
+		<-release
+		return nil
+	})
+	h.limiter = flowcontrol.NewConcurrencyLimiter(1)
+
+	session := &fakeSession{ctx: context.Background()}
+	require.NoError(t, h.Setup(session))
+
+	for partition := int32(0); partition < 2; partition++ {
+		claim := &fakeClaim{messages: make(chan *sarama.ConsumerMessage, 1)}
+		claim.messages <- &sarama.ConsumerMessage{Topic: "topic", Partition: partition, Value: []byte("1")}
+		go h.ConsumeClaim(session, claim)
+	}
+
+	<-started
+	// the other partition waits for the slot with its fetching paused
+	require.Eventually(t, func() bool { return cg.pausedPartitions.Load() == 1 }, time.Second, time.Millisecond)
+	assert.Equal(t, 1, h.limiter.InFlight())
+
+	release <- struct{}{}
+	<-started
+	assert.Equal(t, int32(0), cg.pausedPartitions.Load())
+
+	close(release)
+	require.NoError(t, h.drain(time.Second))
+	assert.Equal(t, 0, h.limiter.InFlight())
+}
@@ -1,8 +1,21 @@
 package kafka
 
-import "github.com/lengocson131002/go-clean-core/logger/logrus"
+import (
+	"time"
+
+	"github.com/lengocson131002/go-clean-core/logger/logrus"
+)
 
 var (
-	DefaultKafkaBroker = "127.0.0.1:9092"
-	DefaultLogger      = logrus.NewLogrusLogger()
+	DefaultKafkaBroker           = "127.0.0.1:9092"
+	DefaultLogger                = logrus.NewLogrusLogger()
+	DefaultShutdownTimeout       = time.Second * 30
+	DefaultSubscribeReadyTimeout = time.Second * 60
+
+	DefaultDelayBuckets = []time.Duration{time.Second * 10, time.Minute, time.Minute * 10, time.Hour}
+	DelayTopicPrefix    = "delay."
+	DelayGroup          = "delay-forwarder"
+
+	PriorityTopicSeparator     = ".priority."
+	DefaultPriorityConcurrency = 1
 )
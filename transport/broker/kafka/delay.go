@@ -0,0 +1,187 @@
+package kafka
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"time"
+
+	"github.com/lengocson131002/go-clean-core/transport/broker"
+)
+
+const (
+	// DelayTargetHeader carries the topic a delayed message is forwarded to when it is due
+	DelayTargetHeader = "delayTarget"
+	// DelayUntilHeader carries the time the message leaves its delay topic
+	DelayUntilHeader = "delayUntil"
+)
+
+var (
+	ErrDelayedDeliveryDisabled = errors.New("delayed delivery is not enabled, see the DelayedDelivery option")
+
+	errForwarderClosed = errors.New("delay forwarder is closed")
+	errSessionEnded    = errors.New("consumer group session ended, the delayed message is consumed again after the rebalance")
+)
+
+// DelayTopic returns the topic holding the messages of the delay bucket, e.g. `delay.1m0s`
+func DelayTopic(bucket time.Duration) string {
+	return DelayTopicPrefix + bucket.String()
+}
+
+// delayBucket returns the longest bucket not longer than the remaining delay, the shortest bucket
+// when the remaining delay is shorter than all of them
+func delayBucket(buckets []time.Duration, remaining time.Duration) time.Duration {
+	bucket := buckets[0]
+	for _, b := range buckets {
+		if b <= remaining {
+			bucket = b
+		}
+	}
+	return bucket
+}
+
+// publishDelayed sends the message to the delay topic of its remaining delay. The messages of a delay topic are due
+// in publish order, the forwarder waits for each of them and sends it to the target topic, or to the next bucket
+// when its delay is longer than the bucket.
+func (k *kBroker) publishDelayed(ctx context.Context, topic string, msg *broker.Message, deliverAt time.Time, options broker.PublishOptions) error {
+	buckets := k.getDelayBuckets()
+	if len(buckets) == 0 {
+		return ErrDelayedDeliveryDisabled
+	}
+
+	remaining := time.Until(deliverAt)
+	bucket := delayBucket(buckets, remaining)
+
+	until := deliverAt
+	if remaining > bucket {
+		until = time.Now().Add(bucket)
+	}
+
+	delayed := copyMessage(msg)
+	delayed.SetHeader(DelayTargetHeader, topic)
+	delayed.SetHeader(DelayUntilHeader, until.UTC().Format(time.RFC3339Nano))
+
+	return k.send(ctx, DelayTopic(bucket), delayed, options)
+}
+
+// delayForwarder consumes the delay topics and forwards the due messages to their target topic
+type delayForwarder struct {
+	k       *kBroker
+	closing chan struct{}
+}
+
+// startDelayForwarder consumes the delay topics with the DelayGroup consumer group,
+// the delay topics must exist unless the cluster creates topics automatically
+func (k *kBroker) startDelayForwarder() error {
+	buckets := k.getDelayBuckets()
+	if len(buckets) == 0 {
+		return nil
+	}
+
+	topics := make([]string, 0, len(buckets))
+	for _, b := range buckets {
+		topics = append(topics, DelayTopic(b))
+	}
+
+	f := &delayForwarder{
+		k:       k,
+		closing: make(chan struct{}),
+	}
+
+	opt := broker.SubscribeOptions{
+		AutoAck: true,
+		Group:   DelayGroup,
+	}
+
+	// the forwarder sees the messages as they are published, e.g. claim check references are not resolved
+	if _, err := k.subscribe(topics, f.handle, opt, nil); err != nil {
+		return err
+	}
+
+	k.scMutex.Lock()
+	k.forwarder = f
+	k.scMutex.Unlock()
+	return nil
+}
+
+// stop interrupts the messages waiting to be due, they are consumed again by the next forwarder
+func (f *delayForwarder) stop() {
+	close(f.closing)
+}
+
+func (f *delayForwarder) handle(ctx context.Context, e broker.Event) error {
+	m := e.Message()
+	target := m.Header(DelayTargetHeader)
+	if len(target) == 0 {
+		f.k.getLogger().Errorf(ctx, "[kafka delay] message %s of %s has no target topic", m.Header(broker.MessageIdHeader), e.Topic())
+		return nil
+	}
+
+	if until, err := time.Parse(time.RFC3339Nano, m.Header(DelayUntilHeader)); err == nil && time.Until(until) > 0 {
+		if err := f.wait(e, until); err != nil {
+			return err
+		}
+	}
+
+	// the delay is longer than the bucket, move on to the next bucket
+	if deliverAt, ok := m.DeliverAt(); ok && time.Until(deliverAt) > 0 {
+		return f.k.publishDelayed(ctx, target, m, deliverAt, broker.PublishOptions{})
+	}
+
+	m.SetHeader(DelayTargetHeader, "")
+	m.SetHeader(DelayUntilHeader, "")
+	return f.k.send(ctx, target, m, broker.PublishOptions{})
+}
+
+// wait blocks until the message is due. The partition is paused meanwhile, so the next messages are not fetched
+// and buffered, and the wait ends with the consumer group session, so a waiting message does not stall rebalances.
+// The message is not marked when the wait is interrupted, it is consumed again by the next owner of the partition.
+func (f *delayForwarder) wait(e broker.Event, until time.Time) error {
+	var sessionDone <-chan struct{}
+	if p, ok := e.(*publication); ok && p.sess != nil {
+		sessionDone = p.sess.Context().Done()
+
+		partitions := map[string][]int32{p.km.Topic: {p.km.Partition}}
+		p.cg.Pause(partitions)
+		defer p.cg.Resume(partitions)
+	}
+
+	timer := time.NewTimer(time.Until(until))
+	defer timer.Stop()
+
+	select {
+	case <-timer.C:
+		return nil
+	case <-sessionDone:
+		return errSessionEnded
+	case <-f.closing:
+		return errForwarderClosed
+	}
+}
+
+func (k *kBroker) getDelayBuckets() []time.Duration {
+	buckets, ok := k.opts.Context.Value(delayBucketsKey{}).([]time.Duration)
+	if !ok {
+		return nil
+	}
+
+	sorted := make([]time.Duration, 0, len(buckets))
+	for _, b := range buckets {
+		if b > 0 {
+			sorted = append(sorted, b)
+		}
+	}
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+	return sorted
+}
+
+func copyMessage(m *broker.Message) *broker.Message {
+	headers := make(map[string]string, len(m.Headers))
+	for k, v := range m.Headers {
+		headers[k] = v
+	}
+	return &broker.Message{
+		Headers: headers,
+		Body:    m.Body,
+	}
+}
@@ -0,0 +1,214 @@
+package kafka
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/IBM/sarama"
+	"github.com/IBM/sarama/mocks"
+	"github.com/lengocson131002/go-clean-core/transport/broker"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func recordHeaders(msg *sarama.ProducerMessage) map[string]string {
+	headers := make(map[string]string, len(msg.Headers))
+	for _, h := range msg.Headers {
+		headers[string(h.Key)] = string(h.Value)
+	}
+	return headers
+}
+
+func TestDelayBucket(t *testing.T) {
+	buckets := []time.Duration{time.Second * 10, time.Minute, time.Hour}
+
+	assert.Equal(t, time.Second*10, delayBucket(buckets, time.Second))
+	assert.Equal(t, time.Second*10, delayBucket(buckets, time.Second*30))
+	assert.Equal(t, time.Minute, delayBucket(buckets, time.Minute*15))
+	assert.Equal(t, time.Hour, delayBucket(buckets, time.Hour*3))
+}
+
+func TestPublishDelayed(t *testing.T) {
+	p := mocks.NewSyncProducer(t, nil)
+	k := NewKafkaBroker(DelayedDelivery(time.Second*10, time.Minute)).(*kBroker)
+	k.p = p
+
+	var sent *sarama.ProducerMessage
+	p.ExpectSendMessageWithMessageCheckerFunctionAndSucceed(func(msg *sarama.ProducerMessage) error {
+		sent = msg
+		return nil
+	})
+
+	deliverAt := time.Now().Add(time.Minute * 15)
+	err := k.Publish(context.Background(), "orders", &broker.Message{Body: []byte("order")},
+		broker.WithPublishAt(deliverAt),
+		broker.WithPublishPriority(broker.PriorityHigh))
+	require.NoError(t, err)
+
+	assert.Equal(t, DelayTopic(time.Minute), sent.Topic)
+	headers := recordHeaders(sent)
+	assert.Equal(t, "orders.priority.high", headers[DelayTargetHeader])
+	assert.Equal(t, deliverAt.UTC().Format(time.RFC3339Nano), headers[broker.DeliverAtHeader])
+
+	until, err := time.Parse(time.RFC3339Nano, headers[DelayUntilHeader])
+	require.NoError(t, err)
+	assert.WithinDuration(t, time.Now().Add(time.Minute), until, time.Second)
+}
+
+func TestPublishDelayedDisabled(t *testing.T) {
+	k := NewKafkaBroker().(*kBroker)
+	k.p = mocks.NewSyncProducer(t, nil)
+
+	err := k.Publish(context.Background(), "orders", &broker.Message{}, broker.WithPublishDelay(time.Minute))
+	assert.ErrorIs(t, err, ErrDelayedDeliveryDisabled)
+}
+
+func TestDelayForwarder(t *testing.T) {
+	p := mocks.NewSyncProducer(t, nil)
+	k := NewKafkaBroker(DelayedDelivery(time.Second*10, time.Minute)).(*kBroker)
+	k.p = p
+	f := &delayForwarder{k: k, closing: make(chan struct{})}
+
+	var sent []*sarama.ProducerMessage
+	checker := func(msg *sarama.ProducerMessage) error {
+		sent = append(sent, msg)
+		return nil
+	}
+	p.ExpectSendMessageWithMessageCheckerFunctionAndSucceed(checker)
+	p.ExpectSendMessageWithMessageCheckerFunctionAndSucceed(checker)
+
+	// due messages are forwarded to the target topic
+	due := &broker.Message{Headers: map[string]string{
+		DelayTargetHeader:      "orders",
+		DelayUntilHeader:       time.Now().Add(-time.Second).Format(time.RFC3339Nano),
+		broker.DeliverAtHeader: time.Now().Add(-time.Second).Format(time.RFC3339Nano),
+	}}
+	require.NoError(t, f.handle(context.Background(), &publication{t: DelayTopic(time.Minute), m: due}))
+	assert.Equal(t, "orders", sent[0].Topic)
+	assert.NotContains(t, recordHeaders(sent[0]), DelayTargetHeader)
+
+	// messages delayed longer than their bucket move on to the next bucket
+	later := &broker.Message{Headers: map[string]string{
+		DelayTargetHeader:      "orders",
+		DelayUntilHeader:       time.Now().Add(-time.Second).Format(time.RFC3339Nano),
+		broker.DeliverAtHeader: time.Now().Add(time.Minute * 5).Format(time.RFC3339Nano),
+	}}
+	require.NoError(t, f.handle(context.Background(), &publication{t: DelayTopic(time.Minute), m: later}))
+	assert.Equal(t, DelayTopic(time.Minute), sent[1].Topic)
+	assert.Equal(t, "orders", recordHeaders(sent[1])[DelayTargetHeader])
+
+	waiting := &broker.Message{Headers: map[string]string{
+		DelayTargetHeader: "orders",
+		DelayUntilHeader:  time.Now().Add(time.Minute).Format(time.RFC3339Nano),
+	}}
+	km := &sarama.ConsumerMessage{Topic: DelayTopic(time.Minute), Partition: 0, Offset: 7}
+
+	// waiting messages are interrupted when the session ends, e.g. on rebalance, the partition is paused meanwhile
+	var (
+		cg              = &fakeConsumerGroup{}
+		sessionCtx, end = context.WithCancel(context.Background())
+		session         = &fakeSession{ctx: sessionCtx}
+		done            = make(chan error)
+	)
+	go func() {
+		done <- f.handle(context.Background(), &publication{t: km.Topic, m: waiting, km: km, cg: cg, sess: session})
+	}()
+	require.Eventually(t, func() bool { return cg.pausedPartitions.Load() == 1 }, time.Second, time.Millisecond)
+	end()
+	assert.ErrorIs(t, <-done, errSessionEnded)
+	assert.Zero(t, cg.pausedPartitions.Load())
+	assert.Zero(t, session.marked.Load())
+
+	// waiting messages are interrupted when the forwarder stops
+	f.stop()
+	session = &fakeSession{ctx: context.Background()}
+	assert.ErrorIs(t, f.handle(context.Background(), &publication{t: km.Topic, m: waiting, km: km, cg: cg, sess: session}), errForwarderClosed)
+}
+
+func TestPriorityTopics(t *testing.T) {
+	assert.Equal(t, "orders", PriorityTopic("orders", broker.PriorityNormal))
+	assert.Equal(t, "orders.priority.low", PriorityTopic("orders", broker.PriorityLow))
+
+	topics := priorityTopics("orders", map[broker.Priority]int{broker.PriorityHigh: 4, broker.PriorityLow: 1})
+	assert.Equal(t, []string{"orders.priority.high", "orders", "orders.priority.low"}, topics)
+}
+
+func TestPriorityScheduler(t *testing.T) {
+	s := newPriorityScheduler("orders", map[broker.Priority]int{
+		broker.PriorityHigh:   3,
+		broker.PriorityNormal: 1,
+	}, 1)
+
+	ctx := context.Background()
+	closing := make(chan struct{})
+
+	// hold the only turn while the others wait
+	require.True(t, s.acquire(ctx, closing, "orders"))
+
+	var (
+		mutex sync.Mutex
+		order []string
+		wg    sync.WaitGroup
+	)
+
+	wait := func(topic string) {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if s.acquire(ctx, closing, topic) {
+				mutex.Lock()
+				order = append(order, topic)
+				mutex.Unlock()
+				s.release()
+			}
+		}()
+	}
+
+	waiting := func() int {
+		s.mutex.Lock()
+		defer s.mutex.Unlock()
+		n := 0
+		for _, w := range s.waiters {
+			n += w.Len()
+		}
+		return n
+	}
+
+	for i := 0; i < 2; i++ {
+		wait("orders")
+	}
+	for i := 0; i < 5; i++ {
+		wait("orders.priority.high")
+	}
+	require.Eventually(t, func() bool { return waiting() == 7 }, time.Second, time.Millisecond)
+
+	s.release()
+	wg.Wait()
+
+	// the holder used the turn of the normal priority in the first round
+	assert.Equal(t, []string{
+		"orders.priority.high", "orders.priority.high", "orders.priority.high",
+		"orders.priority.high", "orders.priority.high", "orders",
+		"orders",
+	}, order)
+}
+
+func TestPrioritySchedulerClosing(t *testing.T) {
+	s := newPriorityScheduler("orders", map[broker.Priority]int{broker.PriorityHigh: 1}, 1)
+	closing := make(chan struct{})
+
+	require.True(t, s.acquire(context.Background(), closing, "orders"))
+
+	done := make(chan bool)
+	go func() {
+		done <- s.acquire(context.Background(), closing, "orders.priority.high")
+	}()
+
+	close(closing)
+	assert.False(t, <-done)
+
+	s.release()
+	assert.Equal(t, 0, s.running)
+}
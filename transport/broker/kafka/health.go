@@ -0,0 +1,114 @@
+package kafka
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	healthchecks "github.com/lengocson131002/go-clean-core/health"
+)
+
+// LagCheck is a consumer group subscription checked by the HealthChecker
+type LagCheck struct {
+	Group string
+	// Topic of the subscription, empty means every topic the group has committed offsets for
+	Topic string
+	// Threshold of the total lag of the subscription, zero disables the lag threshold
+	Threshold int64
+}
+
+type partitionKey struct {
+	group     string
+	topic     string
+	partition int32
+}
+
+// HealthChecker verifies broker connectivity, metadata fetch and consumer group lags.
+// A consumer is reported as stalled when its committed offset did not move
+// between two checks while its lag kept growing.
+type HealthChecker struct {
+	admin  TopicAdmin
+	checks []LagCheck
+
+	mutex    sync.Mutex
+	previous map[partitionKey]PartitionLag
+}
+
+func NewHealthChecker(admin TopicAdmin, checks ...LagCheck) *HealthChecker {
+	return &HealthChecker{
+		admin:    admin,
+		checks:   checks,
+		previous: make(map[partitionKey]PartitionLag),
+	}
+}
+
+// Check implements healthchecks.HealthCheckHandler.
+func (c *HealthChecker) Check(name string) healthchecks.Integration {
+	var (
+		start  = time.Now()
+		errs   = c.check()
+		status = len(errs) == 0
+	)
+
+	return healthchecks.Integration{
+		Name:         name,
+		Status:       status,
+		ResponseTime: time.Since(start).Nanoseconds(),
+		Error:        strings.Join(errs, "; "),
+	}
+}
+
+func (c *HealthChecker) check() []string {
+	cluster, err := c.admin.DescribeCluster()
+	if err != nil {
+		return []string{fmt.Sprintf("failed to connect to kafka brokers: %s", err)}
+	}
+
+	if len(cluster.Brokers) == 0 {
+		return []string{"no kafka broker available"}
+	}
+
+	if _, err := c.admin.DescribeTopics(); err != nil {
+		return []string{fmt.Sprintf("failed to fetch kafka metadata: %s", err)}
+	}
+
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	var errs []string
+	for _, lc := range c.checks {
+		group, err := c.admin.DescribeConsumerGroup(lc.Group)
+		if err != nil {
+			errs = append(errs, fmt.Sprintf("failed to describe consumer group %s: %s", lc.Group, err))
+			continue
+		}
+
+		var totalLag int64
+		for _, lag := range group.Lags {
+			if len(lc.Topic) != 0 && lag.Topic != lc.Topic {
+				continue
+			}
+			totalLag += lag.Lag
+
+			key := partitionKey{group: lc.Group, topic: lag.Topic, partition: lag.Partition}
+			if prev, ok := c.previous[key]; ok && prev.CommittedOffset == lag.CommittedOffset && lag.Lag > prev.Lag {
+				errs = append(errs, fmt.Sprintf("consumer group %s is stalled on %s/%d (offset %d, lag %d -> %d)",
+					lc.Group, lag.Topic, lag.Partition, lag.CommittedOffset, prev.Lag, lag.Lag))
+			}
+			c.previous[key] = lag
+		}
+
+		if lc.Threshold > 0 && totalLag > lc.Threshold {
+			topic := lc.Topic
+			if len(topic) == 0 {
+				topic = "*"
+			}
+			errs = append(errs, fmt.Sprintf("consumer group %s lag on %s is too high (%d > %d)", lc.Group, topic, totalLag, lc.Threshold))
+		}
+	}
+
+	return errs
+}
+
+var _ healthchecks.HealthCheckHandler = (*HealthChecker)(nil)
@@ -0,0 +1,76 @@
+package kafka
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type fakeTopicAdmin struct {
+	TopicAdmin
+
+	clusterErr error
+	group      *ConsumerGroupInfo
+}
+
+func (f *fakeTopicAdmin) DescribeCluster() (*ClusterInfo, error) {
+	if f.clusterErr != nil {
+		return nil, f.clusterErr
+	}
+	return &ClusterInfo{Brokers: []string{"localhost:9092"}}, nil
+}
+
+func (f *fakeTopicAdmin) DescribeTopics(topics ...string) ([]TopicInfo, error) {
+	return nil, nil
+}
+
+func (f *fakeTopicAdmin) DescribeConsumerGroup(group string) (*ConsumerGroupInfo, error) {
+	return f.group, nil
+}
+
+func TestHealthCheckerBrokerDown(t *testing.T) {
+	checker := NewHealthChecker(&fakeTopicAdmin{clusterErr: errors.New("connection refused")})
+
+	result := checker.Check("kafka")
+	assert.False(t, result.Status)
+	assert.Contains(t, result.Error, "connection refused")
+}
+
+func TestHealthCheckerLagThreshold(t *testing.T) {
+	admin := &fakeTopicAdmin{
+		group: &ConsumerGroupInfo{
+			Lags: []PartitionLag{
+				{Topic: "orders", Partition: 0, CommittedOffset: 10, Lag: 50},
+				{Topic: "payments", Partition: 0, CommittedOffset: 10, Lag: 500},
+			},
+		},
+	}
+
+	assert.True(t, NewHealthChecker(admin, LagCheck{Group: "group", Topic: "orders", Threshold: 100}).Check("kafka").Status)
+	assert.False(t, NewHealthChecker(admin, LagCheck{Group: "group", Threshold: 100}).Check("kafka").Status)
+}
+
+func TestHealthCheckerStalledConsumer(t *testing.T) {
+	admin := &fakeTopicAdmin{
+		group: &ConsumerGroupInfo{
+			Lags: []PartitionLag{{Topic: "orders", Partition: 0, CommittedOffset: 10, Lag: 5}},
+		},
+	}
+	checker := NewHealthChecker(admin, LagCheck{Group: "group"})
+	assert.True(t, checker.Check("kafka").Status)
+
+	// progress was made
+	admin.group = &ConsumerGroupInfo{
+		Lags: []PartitionLag{{Topic: "orders", Partition: 0, CommittedOffset: 12, Lag: 6}},
+	}
+	assert.True(t, checker.Check("kafka").Status)
+
+	// no progress while lag grows
+	admin.group = &ConsumerGroupInfo{
+		Lags: []PartitionLag{{Topic: "orders", Partition: 0, CommittedOffset: 12, Lag: 9}},
+	}
+	result := checker.Check("kafka")
+	assert.False(t, result.Status)
+	assert.Contains(t, result.Error, "stalled")
+}
@@ -4,6 +4,7 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"strings"
 	"sync"
 	"time"
 
@@ -11,6 +12,8 @@ import (
 	"github.com/google/uuid"
 	"github.com/lengocson131002/go-clean-core/logger"
 	"github.com/lengocson131002/go-clean-core/transport/broker"
+	"github.com/lengocson131002/go-clean-core/transport/broker/claimcheck"
+	"github.com/lengocson131002/go-clean-core/transport/broker/flowcontrol"
 )
 
 var (
@@ -23,7 +26,7 @@ type kBroker struct {
 	c         sarama.Client        // broker connection client
 	p         sarama.SyncProducer  // sync producer
 	ap        sarama.AsyncProducer // async producer
-	cgs       []sarama.ConsumerGroup
+	subs      []*subscriber
 	connected bool
 	scMutex   sync.Mutex
 	opts      broker.BrokerOptions
@@ -32,7 +35,14 @@ type kBroker struct {
 	resps           sync.Map
 	respSubscribers sync.Map
 
+	// producers of compression codecs other than the one of the broker config
+	cpMutex        sync.Mutex
+	codecProducers map[sarama.CompressionCodec]*codecProducer
+
 	codec Codec
+
+	// forwards the due messages of the delay topics
+	forwarder *delayForwarder
 }
 
 func NewKafkaBroker(opts ...broker.BrokerOption) broker.Broker {
@@ -57,18 +67,25 @@ func NewKafkaBroker(opts ...broker.BrokerOption) broker.Broker {
 		cAddrs = []string{DefaultKafkaBroker}
 	}
 
+	var codec Codec = DefaultMarshaler{}
+	if c, ok := options.Context.Value(codecKey{}).(Codec); ok {
+		codec = c
+	}
+
 	return &kBroker{
 		addrs: cAddrs,
-		codec: DefaultMarshaler{},
+		codec: codec,
 		opts:  options,
 	}
 }
 
 type subscriber struct {
-	k    *kBroker
-	cg   sarama.ConsumerGroup
-	t    string
-	opts broker.SubscribeOptions
+	k       *kBroker
+	cg      sarama.ConsumerGroup
+	t       string
+	opts    broker.SubscribeOptions
+	handler *consumerGroupHandler
+	done    chan struct{}
 }
 
 type publication struct {
@@ -105,23 +122,50 @@ func (s *subscriber) Topic() string {
 	return s.t
 }
 
+// Unsubscribe stops fetching, waits for in-flight handlers, commits offsets and leaves the consumer group
 func (s *subscriber) Unsubscribe() error {
-	if err := s.cg.Close(); err != nil {
-		return err
-	}
-
 	k := s.k
 	k.scMutex.Lock()
-	defer k.scMutex.Unlock()
-
-	for i, cg := range k.cgs {
-		if cg == s.cg {
-			k.cgs = append(k.cgs[:i], k.cgs[i+1:]...)
-			return nil
+	for i, sub := range k.subs {
+		if sub == s {
+			k.subs = append(k.subs[:i], k.subs[i+1:]...)
+			break
 		}
 	}
+	k.scMutex.Unlock()
 
-	return nil
+	return s.close()
+}
+
+// close drains the handlers and leaves the consumer group within the shutdown timeout. Leaving the group waits for the
+// consume loop, which waits for the handlers still running, so close gives up on them when the timeout expires.
+func (s *subscriber) close() error {
+	var (
+		timeout  = s.k.getShutdownTimeout()
+		deadline = time.Now().Add(timeout)
+		drainErr = s.handler.drain(timeout)
+		closed   = make(chan error, 1)
+	)
+
+	go func() {
+		err := s.cg.Close()
+		// wait until the consume loop exits
+		<-s.done
+		closed <- err
+	}()
+
+	timer := time.NewTimer(time.Until(deadline))
+	defer timer.Stop()
+
+	select {
+	case err := <-closed:
+		return errors.Join(drainErr, err)
+	case <-timer.C:
+		if drainErr == nil {
+			drainErr = broker.DrainTimeoutError{Timeout: timeout}
+		}
+		return drainErr
+	}
 }
 
 func (k *kBroker) Address() string {
@@ -155,9 +199,45 @@ func (k *kBroker) Connect() error {
 		return err
 	}
 
+	if err := k.getMetrics().RegisterSaramaRegistry(pconfig.ClientID, pconfig.MetricRegistry); err != nil {
+		k.getLogger().Errorf(k.opts.Context, "failed to export sarama metrics: %s", err)
+	}
+
+	p, ap, err := k.newProducers(c)
+	if err != nil {
+		return err
+	}
+
+	k.scMutex.Lock()
+	k.c = c
+	if p != nil {
+		k.p = p
+	}
+	if ap != nil {
+		k.ap = ap
+	}
+	k.subs = make([]*subscriber, 0)
+	k.connected = true
+
+	// request-reply pattern
+	k.resps = sync.Map{}
+	k.respSubscribers = sync.Map{}
+
+	k.scMutex.Unlock()
+
+	if err := k.startDelayForwarder(); err != nil {
+		return fmt.Errorf("failed to start delay forwarder: %w", err)
+	}
+
+	return nil
+}
+
+// newProducers creates the async producer when the error channel is set, else the sync producer
+func (k *kBroker) newProducers(c sarama.Client) (sarama.SyncProducer, sarama.AsyncProducer, error) {
 	var (
 		ap                   sarama.AsyncProducer
 		p                    sarama.SyncProducer
+		err                  error
 		errChan, successChan = k.getAsyncProduceChan()
 	)
 
@@ -168,7 +248,7 @@ func (k *kBroker) Connect() error {
 	if errChan != nil {
 		ap, err = sarama.NewAsyncProducerFromClient(c)
 		if err != nil {
-			return err
+			return nil, nil, err
 		}
 		// When the ap closed, the Errors() & Successes() channel will be closed
 		// So the goroutine will auto exit
@@ -188,45 +268,69 @@ func (k *kBroker) Connect() error {
 	} else {
 		p, err = sarama.NewSyncProducerFromClient(c)
 		if err != nil {
-			return err
+			return nil, nil, err
 		}
 	}
 
+	return p, ap, nil
+}
+
+func (k *kBroker) Disconnect() error {
 	k.scMutex.Lock()
-	k.c = c
-	if p != nil {
-		k.p = p
-	}
-	if ap != nil {
-		k.ap = ap
-	}
-	k.cgs = make([]sarama.ConsumerGroup, 0)
-	k.connected = true
+	forwarder := k.forwarder
+	k.forwarder = nil
+	k.scMutex.Unlock()
 
-	// request-reply pattern
-	k.resps = sync.Map{}
-	k.respSubscribers = sync.Map{}
+	if forwarder != nil {
+		forwarder.stop()
+	}
 
-	k.scMutex.Unlock()
+	// drain subscribers before closing producers, handlers may still publish.
+	// The lock is released while draining, in-flight handlers may still subscribe, e.g. through PublishAndReceive,
+	// the subscriptions they make are drained by the next round. A subscriber failing to drain in time does not
+	// prevent closing the producers and the client, its error is returned
+	var (
+		errs      []error
+		errsMutex sync.Mutex
+	)
+	for {
+		k.scMutex.Lock()
+		subs := k.subs
+		k.subs = nil
+		k.scMutex.Unlock()
+		if len(subs) == 0 {
+			break
+		}
 
-	return nil
-}
+		var wg sync.WaitGroup
+		for _, sub := range subs {
+			wg.Add(1)
+			go func(sub *subscriber) {
+				defer wg.Done()
+				if err := sub.close(); err != nil {
+					errsMutex.Lock()
+					errs = append(errs, fmt.Errorf("failed to close subscriber of topic %s: %w", sub.t, err))
+					errsMutex.Unlock()
+				}
+			}(sub)
+		}
+		wg.Wait()
+	}
 
-func (k *kBroker) Disconnect() error {
 	k.scMutex.Lock()
 	defer k.scMutex.Unlock()
-	for _, consumer := range k.cgs {
-		consumer.Close()
-	}
-	k.cgs = nil
+
+	k.subs = nil
 	if k.p != nil {
 		k.p.Close()
 	}
 	if k.ap != nil {
 		k.ap.Close()
 	}
+	k.closeCodecProducers()
 	if err := k.c.Close(); err != nil {
-		return err
+		errs = append(errs, err)
+		return errors.Join(errs...)
 	}
 	k.connected = false
 
@@ -234,7 +338,7 @@ func (k *kBroker) Disconnect() error {
 	k.resps = sync.Map{}
 	k.respSubscribers = sync.Map{}
 
-	return nil
+	return errors.Join(errs...)
 }
 
 func (k *kBroker) Init(opts ...broker.BrokerOption) error {
@@ -260,13 +364,65 @@ func (k *kBroker) Options() broker.BrokerOptions {
 }
 
 func (k *kBroker) Publish(ctx context.Context, topic string, msg *broker.Message, opts ...broker.PublishOption) error {
+	return k.getPublishFunc()(ctx, topic, msg, opts...)
+}
+
+// publish is the innermost PublishFunc wrapped by the publish middlewares
+func (k *kBroker) publish(ctx context.Context, topic string, msg *broker.Message, opts ...broker.PublishOption) error {
 	options := broker.PublishOptions{}
 
 	for _, opt := range opts {
 		opt(&options)
 	}
 
-	return k.sendMessage(ctx, topic, msg)
+	broker.PopulateEnvelope(ctx, msg)
+	msg.SetDelivery(options)
+
+	if limit := k.getMaxMessageBytes(); limit > 0 && len(msg.Body) > limit {
+		return broker.MessageTooLargeError{Topic: topic, Size: len(msg.Body), Limit: limit}
+	}
+
+	topic = PriorityTopic(topic, options.Priority)
+
+	if time.Until(options.DeliverAt) > 0 {
+		return k.publishDelayed(ctx, topic, msg, options.DeliverAt, options)
+	}
+
+	return k.send(ctx, topic, msg, options)
+}
+
+// send sends the message with the producer of its compression codec
+func (k *kBroker) send(ctx context.Context, topic string, msg *broker.Message, options broker.PublishOptions) error {
+	codec, ok := k.getCompression(topic, options)
+	if !ok || codec == k.getBrokerConfig().Producer.Compression {
+		return k.sendMessage(ctx, k.p, k.ap, topic, msg)
+	}
+
+	cp, err := k.getCodecProducer(codec)
+	if err != nil {
+		return fmt.Errorf("failed to create %s producer: %w", codec, err)
+	}
+	return k.sendMessage(ctx, cp.p, cp.ap, topic, msg)
+}
+
+// getPublishFunc chains the publish middlewares, the claim check is the innermost one
+// so the middlewares see the original body
+func (k *kBroker) getPublishFunc() broker.PublishFunc {
+	publish := k.publish
+	if cc := k.getClaimCheck(); cc != nil {
+		publish = cc.PublishMiddleware()(publish)
+	}
+	return broker.ChainPublishMiddlewares(publish, k.opts.PublishMiddlewares...)
+}
+
+// getSubscribeMiddlewares returns the subscribe middlewares, the claim check is the outermost one
+// so the middlewares see the resolved body
+func (k *kBroker) getSubscribeMiddlewares() []broker.SubscribeMiddleware {
+	cc := k.getClaimCheck()
+	if cc == nil {
+		return k.opts.SubscribeMiddlewares
+	}
+	return append([]broker.SubscribeMiddleware{cc.SubscribeMiddleware()}, k.opts.SubscribeMiddlewares...)
 }
 
 func (k *kBroker) PublishAndReceive(ctx context.Context, topic string, msg *broker.Message, opts ...broker.PublishOption) (*broker.Message, error) {
@@ -287,7 +443,7 @@ func (k *kBroker) PublishAndReceive(ctx context.Context, topic string, msg *brok
 		msgChan            = make(chan *broker.Message, 1)
 	)
 
-	err := k.sendMessage(ctx, topic, msg)
+	err := k.getPublishFunc()(ctx, topic, msg, opts...)
 	if err != nil {
 		return nil, err
 	}
@@ -298,6 +454,7 @@ func (k *kBroker) PublishAndReceive(ctx context.Context, topic string, msg *brok
 		return nil, fmt.Errorf("missing correlation id in message")
 	}
 	k.resps.Store(correlationId, msgChan)
+	k.getMetrics().requestPending(1)
 
 	// Subscribe for reply topic if didn't
 	go func() {
@@ -320,6 +477,7 @@ func (k *kBroker) PublishAndReceive(ctx context.Context, topic string, msg *brok
 
 					msgChan, msgChanOk := k.resps.LoadAndDelete(cId)
 					if msgChanOk {
+						k.getMetrics().requestPending(-1)
 						msgChan.(chan *broker.Message) <- e.Message()
 					}
 				}()
@@ -340,24 +498,30 @@ func (k *kBroker) PublishAndReceive(ctx context.Context, topic string, msg *brok
 		return nil, err
 	case <-time.After(timeout):
 		// remove processed channel
-		k.resps.Delete(correlationId)
+		if _, ok := k.resps.LoadAndDelete(correlationId); ok {
+			k.getMetrics().requestPending(-1)
+			k.getMetrics().requestTimeout(topic)
+		}
 		return nil, broker.RequestTimeoutResponse{
 			Timeout: timeout,
 		}
 	}
 }
 
-func (k *kBroker) sendMessage(ctx context.Context, topic string, msg *broker.Message) error {
+func (k *kBroker) sendMessage(ctx context.Context, p sarama.SyncProducer, ap sarama.AsyncProducer, topic string, msg *broker.Message) error {
 	kMsg, err := k.codec.Marshal(topic, msg)
 	if err != nil {
 		return fmt.Errorf("failed to marshal to kafka message: %w", err)
 	}
 
-	if k.ap != nil {
-		k.ap.Input() <- kMsg
+	start := time.Now()
+	if ap != nil {
+		ap.Input() <- kMsg
+		k.getMetrics().observePublish(topic, len(msg.Body), start, nil)
 		return nil
-	} else if k.p != nil {
-		_, _, err := k.p.SendMessage(kMsg)
+	} else if p != nil {
+		_, _, err := p.SendMessage(kMsg)
+		k.getMetrics().observePublish(topic, len(msg.Body), start, err)
 		return err
 	}
 	return errors.New(`no connection resources available`)
@@ -374,25 +538,75 @@ func (k *kBroker) Subscribe(topic string, handler broker.Handler, opts ...broker
 	for _, o := range opts {
 		o(&opt)
 	}
+
+	var (
+		h        = broker.ChainSubscriptionHandler(handler, opt, k.getSubscribeMiddlewares()...)
+		weights  = k.getPriorities(opt)
+		topics   = []string{topic}
+		schedule *priorityScheduler
+	)
+
+	// consume the topic of each priority, the scheduler takes turns between them by weight
+	if len(weights) > 0 {
+		topics = priorityTopics(topic, weights)
+		schedule = newPriorityScheduler(topic, weights, k.getPriorityConcurrency(opt))
+	}
+
+	sub, err := k.subscribe(topics, h, opt, schedule)
+	if err != nil {
+		return nil, err
+	}
+
+	k.getLogger().Infof(context.Background(), "Subcribed to topic: %s. Consumer group: %s. Duration: %dms", strings.Join(topics, ","), opt.Group, time.Since(start).Milliseconds())
+	return sub, nil
+}
+
+// subscribe joins the consumer group of the topics and handles their messages with the handler as it is
+func (k *kBroker) subscribe(topics []string, handler broker.Handler, opt broker.SubscribeOptions, schedule *priorityScheduler) (*subscriber, error) {
 	// we need to create a new client per consumer
-	cg, err := k.getSaramaConsumerGroup(opt.Group)
+	config := k.getSubscribeConfig(opt)
+	cg, err := k.getSaramaConsumerGroup(opt.Group, config)
 	if err != nil {
 		return nil, err
 	}
 
+	if err := k.getMetrics().RegisterSaramaRegistry(config.ClientID+"-consumer", config.MetricRegistry); err != nil {
+		k.getLogger().Errorf(k.opts.Context, "failed to export sarama metrics of group %s: %s", opt.Group, err)
+	}
+
 	csHandler := &consumerGroupHandler{
-		handler: handler,
-		subopts: opt,
-		kopts:   k.opts,
-		cg:      cg,
-		logger:  k.getLogger(),
-		ready:   make(chan bool),
-		codec:   k.codec,
+		handler:  handler,
+		schedule: schedule,
+		subopts:  opt,
+		kopts:    k.opts,
+		cg:       cg,
+		logger:   k.getLogger(),
+		ready:    make(chan bool),
+		closing:  make(chan struct{}),
+		codec:    k.codec,
+		metrics:  k.getMetrics(),
+	}
+
+	if opt.Context != nil {
+		if hook, ok := opt.Context.Value(partitionsAssignedKey{}).(PartitionsHook); ok {
+			csHandler.assigned = hook
+		}
+		if hook, ok := opt.Context.Value(partitionsRevokedKey{}).(PartitionsHook); ok {
+			csHandler.revoked = hook
+		}
+		if limiter, ok := opt.Context.Value(maxInFlightKey{}).(*flowcontrol.ConcurrencyLimiter); ok {
+			csHandler.limiter = limiter
+		}
 	}
 
-	ctx := context.Background()
-	topics := []string{topic}
+	var (
+		ctx      = context.Background()
+		setupErr = make(chan error, 1)
+		done     = make(chan struct{})
+	)
+
 	go func() {
+		defer close(done)
 		for {
 			select {
 			case err := <-cg.Errors():
@@ -401,42 +615,64 @@ func (k *kBroker) Subscribe(topic string, handler broker.Handler, opts ...broker
 				}
 			default:
 				err := cg.Consume(ctx, topics, csHandler)
-				switch err {
-				case sarama.ErrClosedConsumerGroup:
-					return
-				case nil:
-					csHandler.ready = make(chan bool)
+				if err == nil {
 					continue
-				default:
+				}
+
+				select {
+				case <-csHandler.ready:
+					if err == sarama.ErrClosedConsumerGroup {
+						return
+					}
 					k.getLogger().Errorf(ctx, "consumer error: %s", err)
+				default:
+					// failed before joining the group
+					setupErr <- err
+					return
 				}
 			}
 		}
 	}()
 
 	// wait until consumer group running
-	<-csHandler.ready
+	readyTimeout := k.getSubscribeReadyTimeout(opt)
+	select {
+	case <-csHandler.ready:
+	case err := <-setupErr:
+		cg.Close()
+		return nil, fmt.Errorf("failed to subscribe to topic %s: %w", strings.Join(topics, ","), err)
+	case <-time.After(readyTimeout):
+		cg.Close()
+		<-done
+		return nil, broker.SubscribeTimeoutError{Topic: strings.Join(topics, ","), Timeout: readyTimeout}
+	}
+
+	sub := &subscriber{
+		k:       k,
+		cg:      cg,
+		opts:    opt,
+		t:       topics[0],
+		handler: csHandler,
+		done:    done,
+	}
 
-	k.getLogger().Infof(ctx, "Subcribed to topic: %s. Consumer group: %s. Duration: %dms", topic, opt.Group, time.Since(start).Milliseconds())
+	k.scMutex.Lock()
+	k.subs = append(k.subs, sub)
+	k.scMutex.Unlock()
 
-	return &subscriber{
-		k:    k,
-		cg:   cg,
-		opts: opt,
-		t:    topic,
-	}, nil
+	return sub, nil
 }
 
 func (k *kBroker) getBrokerConfig() *sarama.Config {
 	if c, ok := k.opts.Context.Value(brokerConfigKey{}).(*sarama.Config); ok {
-		return c
+		return withTLSConfig(c, k.opts.TLSConfig)
 	}
-	return DefaultBrokerConfig
+	return withTLSConfig(DefaultBrokerConfig, k.opts.TLSConfig)
 }
 
 func (k *kBroker) getClusterConfig() *sarama.Config {
 	if c, ok := k.opts.Context.Value(clusterConfigKey{}).(*sarama.Config); ok {
-		return c
+		return withTLSConfig(c, k.opts.TLSConfig)
 	}
 	clusterConfig := DefaultClusterConfig
 
@@ -448,19 +684,84 @@ func (k *kBroker) getClusterConfig() *sarama.Config {
 	clusterConfig.Consumer.Return.Errors = true
 	clusterConfig.Consumer.Offsets.Initial = sarama.OffsetOldest
 
-	return clusterConfig
+	return withTLSConfig(clusterConfig, k.opts.TLSConfig)
 }
 
-func (k *kBroker) getSaramaConsumerGroup(groupID string) (sarama.ConsumerGroup, error) {
-	config := k.getClusterConfig()
-	cg, err := sarama.NewConsumerGroup(k.addrs, groupID, config)
-	if err != nil {
-		return nil, err
+// getSubscribeConfig returns a copy of the cluster config with the subscription specific settings applied
+func (k *kBroker) getSubscribeConfig(opt broker.SubscribeOptions) *sarama.Config {
+	config := *k.getClusterConfig()
+	if opt.Context == nil {
+		return &config
 	}
-	k.scMutex.Lock()
-	defer k.scMutex.Unlock()
-	k.cgs = append(k.cgs, cg)
-	return cg, nil
+
+	if c, ok := opt.Context.Value(subscribeConfigKey{}).(*sarama.Config); ok {
+		config = *withTLSConfig(c, k.opts.TLSConfig)
+	}
+
+	if strategies, ok := opt.Context.Value(rebalanceStrategyKey{}).([]sarama.BalanceStrategy); ok && len(strategies) > 0 {
+		config.Consumer.Group.Rebalance.GroupStrategies = strategies
+	}
+
+	return &config
+}
+
+func (k *kBroker) getSaramaConsumerGroup(groupID string, config *sarama.Config) (sarama.ConsumerGroup, error) {
+	return sarama.NewConsumerGroup(k.addrs, groupID, config)
+}
+
+func (k *kBroker) getMaxMessageBytes() int {
+	if max, ok := k.opts.Context.Value(maxMessageBytesKey{}).(int); ok {
+		return max
+	}
+	return k.getBrokerConfig().Producer.MaxMessageBytes
+}
+
+func (k *kBroker) getClaimCheck() *claimcheck.ClaimCheck {
+	if cc, ok := k.opts.Context.Value(claimCheckKey{}).(*claimcheck.ClaimCheck); ok {
+		return cc
+	}
+	return nil
+}
+
+func (k *kBroker) getPriorities(opt broker.SubscribeOptions) map[broker.Priority]int {
+	if opt.Context != nil {
+		if weights, ok := opt.Context.Value(prioritiesKey{}).(map[broker.Priority]int); ok {
+			return weights
+		}
+	}
+	return nil
+}
+
+func (k *kBroker) getPriorityConcurrency(opt broker.SubscribeOptions) int {
+	if opt.Context != nil {
+		if n, ok := opt.Context.Value(priorityConcurrencyKey{}).(int); ok && n > 0 {
+			return n
+		}
+	}
+	return DefaultPriorityConcurrency
+}
+
+func (k *kBroker) getMetrics() *Metrics {
+	if m, ok := k.opts.Context.Value(metricsKey{}).(*Metrics); ok {
+		return m
+	}
+	return nil
+}
+
+func (k *kBroker) getShutdownTimeout() time.Duration {
+	if t, ok := k.opts.Context.Value(shutdownTimeoutKey{}).(time.Duration); ok && t > 0 {
+		return t
+	}
+	return DefaultShutdownTimeout
+}
+
+func (k *kBroker) getSubscribeReadyTimeout(opt broker.SubscribeOptions) time.Duration {
+	if opt.Context != nil {
+		if t, ok := opt.Context.Value(subscribeReadyTimeoutKey{}).(time.Duration); ok && t > 0 {
+			return t
+		}
+	}
+	return DefaultSubscribeReadyTimeout
 }
 
 func (k *kBroker) getLogger() logger.Logger {
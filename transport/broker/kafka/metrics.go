@@ -0,0 +1,307 @@
+package kafka
+
+import (
+	"errors"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/IBM/sarama"
+	"github.com/prometheus/client_golang/prometheus"
+	gometrics "github.com/rcrowley/go-metrics"
+)
+
+const (
+	MetricLabelTopic     = "topic"
+	MetricLabelGroup     = "group"
+	MetricLabelPartition = "partition"
+	MetricLabelBroker    = "broker"
+	MetricLabelClient    = "client"
+	MetricLabelStatus    = "status"
+
+	metricStatusSuccess = "success"
+	metricStatusError   = "error"
+
+	saramaMetricPrefix = "kafka_sarama_"
+)
+
+var (
+	saramaBrokerMetric = regexp.MustCompile(`^(.+)-for-broker-(.+)$`)
+	saramaTopicMetric  = regexp.MustCompile(`^(.+)-for-topic-(.+)$`)
+	invalidMetricChars = regexp.MustCompile(`[^a-zA-Z0-9_]`)
+)
+
+// Metrics exports producer and consumer metrics of the kafka broker to prometheus
+type Metrics struct {
+	MessagesOut         *prometheus.CounterVec
+	BytesOut            *prometheus.CounterVec
+	PublishLatency      *prometheus.HistogramVec
+	MessagesIn          *prometheus.CounterVec
+	BytesIn             *prometheus.CounterVec
+	HandlerDuration     *prometheus.HistogramVec
+	HandlerErrors       *prometheus.CounterVec
+	ConsumerLag         *prometheus.GaugeVec
+	RequestReplyPending prometheus.Gauge
+	RequestReplyTimeout *prometheus.CounterVec
+}
+
+// saramaRegistries records the sarama registries exported to the default registerer and their client labels.
+// Copies of a sarama.Config share its registry, so the brokers and subscriptions created from the same config
+// must export it once.
+var saramaRegistries = struct {
+	sync.Mutex
+	registries map[gometrics.Registry]string
+	clients    map[string]bool
+}{
+	registries: make(map[gometrics.Registry]string),
+	clients:    make(map[string]bool),
+}
+
+// NewMetrics registers the kafka collectors to the default prometheus registerer
+func NewMetrics() (*Metrics, error) {
+	m := &Metrics{
+		MessagesOut: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "kafka_messages_out_total",
+			Help: "Total published messages, partitioned by topic and status",
+		}, []string{MetricLabelTopic, MetricLabelStatus}),
+		BytesOut: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "kafka_bytes_out_total",
+			Help: "Total published message bytes, partitioned by topic",
+		}, []string{MetricLabelTopic}),
+		PublishLatency: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Name: "kafka_publish_duration_seconds",
+			Help: "Publish latency in seconds, partitioned by topic",
+		}, []string{MetricLabelTopic}),
+		MessagesIn: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "kafka_messages_in_total",
+			Help: "Total consumed messages, partitioned by topic and consumer group",
+		}, []string{MetricLabelTopic, MetricLabelGroup}),
+		BytesIn: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "kafka_bytes_in_total",
+			Help: "Total consumed message bytes, partitioned by topic and consumer group",
+		}, []string{MetricLabelTopic, MetricLabelGroup}),
+		HandlerDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Name: "kafka_handler_duration_seconds",
+			Help: "Subscription handler time in seconds, partitioned by topic and consumer group",
+		}, []string{MetricLabelTopic, MetricLabelGroup}),
+		HandlerErrors: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "kafka_handler_errors_total",
+			Help: "Total subscription handler errors, partitioned by topic and consumer group",
+		}, []string{MetricLabelTopic, MetricLabelGroup}),
+		ConsumerLag: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Name: "kafka_consumer_lag",
+			Help: "Messages behind the high water mark, partitioned by topic, consumer group and partition",
+		}, []string{MetricLabelTopic, MetricLabelGroup, MetricLabelPartition}),
+		RequestReplyPending: prometheus.NewGauge(prometheus.GaugeOpts{
+			Name: "kafka_request_reply_pending",
+			Help: "Requests waiting for a reply",
+		}),
+		RequestReplyTimeout: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "kafka_request_reply_timeouts_total",
+			Help: "Total requests without reply before the timeout, partitioned by topic",
+		}, []string{MetricLabelTopic}),
+	}
+
+	var err error
+	if m.MessagesOut, err = registerCollector(m.MessagesOut); err != nil {
+		return nil, err
+	}
+	if m.BytesOut, err = registerCollector(m.BytesOut); err != nil {
+		return nil, err
+	}
+	if m.PublishLatency, err = registerCollector(m.PublishLatency); err != nil {
+		return nil, err
+	}
+	if m.MessagesIn, err = registerCollector(m.MessagesIn); err != nil {
+		return nil, err
+	}
+	if m.BytesIn, err = registerCollector(m.BytesIn); err != nil {
+		return nil, err
+	}
+	if m.HandlerDuration, err = registerCollector(m.HandlerDuration); err != nil {
+		return nil, err
+	}
+	if m.HandlerErrors, err = registerCollector(m.HandlerErrors); err != nil {
+		return nil, err
+	}
+	if m.ConsumerLag, err = registerCollector(m.ConsumerLag); err != nil {
+		return nil, err
+	}
+	if m.RequestReplyPending, err = registerCollector(m.RequestReplyPending); err != nil {
+		return nil, err
+	}
+	if m.RequestReplyTimeout, err = registerCollector(m.RequestReplyTimeout); err != nil {
+		return nil, err
+	}
+
+	return m, nil
+}
+
+// RegisterSaramaRegistry exports the go-metrics of a sarama client, see sarama.Config.MetricRegistry.
+// The series are labeled by the client, a numeric suffix is appended when the client is already used
+// by another registry. A registry is exported once, whatever the Metrics it is registered through.
+func (m *Metrics) RegisterSaramaRegistry(client string, registry gometrics.Registry) error {
+	if m == nil || registry == nil {
+		return nil
+	}
+
+	saramaRegistries.Lock()
+	defer saramaRegistries.Unlock()
+
+	if _, ok := saramaRegistries.registries[registry]; ok {
+		return nil
+	}
+
+	label := client
+	for i := 2; saramaRegistries.clients[label]; i++ {
+		label = client + "-" + strconv.Itoa(i)
+	}
+
+	if err := prometheus.DefaultRegisterer.Register(NewSaramaCollector(label, registry)); err != nil {
+		return err
+	}
+
+	saramaRegistries.registries[registry] = label
+	saramaRegistries.clients[label] = true
+	return nil
+}
+
+func (m *Metrics) observePublish(topic string, size int, start time.Time, err error) {
+	if m == nil {
+		return
+	}
+
+	m.PublishLatency.WithLabelValues(topic).Observe(time.Since(start).Seconds())
+	if err != nil {
+		m.MessagesOut.WithLabelValues(topic, metricStatusError).Inc()
+		return
+	}
+	m.MessagesOut.WithLabelValues(topic, metricStatusSuccess).Inc()
+	m.BytesOut.WithLabelValues(topic).Add(float64(size))
+}
+
+func (m *Metrics) observeConsume(group string, msg *sarama.ConsumerMessage, claim sarama.ConsumerGroupClaim) {
+	if m == nil {
+		return
+	}
+
+	m.MessagesIn.WithLabelValues(msg.Topic, group).Inc()
+	m.BytesIn.WithLabelValues(msg.Topic, group).Add(float64(len(msg.Value)))
+
+	lag := claim.HighWaterMarkOffset() - msg.Offset - 1
+	if lag < 0 {
+		lag = 0
+	}
+	m.ConsumerLag.WithLabelValues(msg.Topic, group, strconv.Itoa(int(msg.Partition))).Set(float64(lag))
+}
+
+func (m *Metrics) observeHandler(topic string, group string, start time.Time, err error) {
+	if m == nil {
+		return
+	}
+
+	m.HandlerDuration.WithLabelValues(topic, group).Observe(time.Since(start).Seconds())
+	if err != nil {
+		m.HandlerErrors.WithLabelValues(topic, group).Inc()
+	}
+}
+
+func (m *Metrics) requestPending(delta float64) {
+	if m == nil {
+		return
+	}
+	m.RequestReplyPending.Add(delta)
+}
+
+func (m *Metrics) requestTimeout(topic string) {
+	if m == nil {
+		return
+	}
+	m.RequestReplyTimeout.WithLabelValues(topic).Inc()
+}
+
+// registerCollector returns the already registered collector when the same one was registered before
+func registerCollector[T prometheus.Collector](collector T) (T, error) {
+	if err := prometheus.DefaultRegisterer.Register(collector); err != nil {
+		var are prometheus.AlreadyRegisteredError
+		if errors.As(err, &are) {
+			if existing, ok := are.ExistingCollector.(T); ok {
+				return existing, nil
+			}
+		}
+		return collector, err
+	}
+	return collector, nil
+}
+
+// SaramaCollector is an unchecked prometheus collector reading a sarama go-metrics registry.
+// Metrics suffixed by `-for-broker-<id>` and `-for-topic-<topic>` are exported with broker and topic labels.
+// All the series carry a constant client label, the collectors of different registries must use different clients.
+type SaramaCollector struct {
+	client   string
+	registry gometrics.Registry
+}
+
+func NewSaramaCollector(client string, registry gometrics.Registry) *SaramaCollector {
+	return &SaramaCollector{client: client, registry: registry}
+}
+
+// Describe implements prometheus.Collector.
+// The metrics of the registry are only known when collecting, so the collector is unchecked.
+func (c *SaramaCollector) Describe(chan<- *prometheus.Desc) {}
+
+// Collect implements prometheus.Collector.
+func (c *SaramaCollector) Collect(ch chan<- prometheus.Metric) {
+	constLabels := prometheus.Labels{MetricLabelClient: c.client}
+	c.registry.Each(func(name string, metric interface{}) {
+		var (
+			metricName  = saramaMetricPrefix
+			labelNames  []string
+			labelValues []string
+		)
+
+		if match := saramaBrokerMetric.FindStringSubmatch(name); match != nil {
+			metricName += "broker_" + sanitizeMetricName(match[1])
+			labelNames = []string{MetricLabelBroker}
+			labelValues = []string{match[2]}
+		} else if match := saramaTopicMetric.FindStringSubmatch(name); match != nil {
+			metricName += "topic_" + sanitizeMetricName(match[1])
+			labelNames = []string{MetricLabelTopic}
+			labelValues = []string{match[2]}
+		} else {
+			metricName += sanitizeMetricName(name)
+		}
+
+		switch m := metric.(type) {
+		case gometrics.Meter:
+			desc := prometheus.NewDesc(metricName+"_total", "sarama meter "+name, labelNames, constLabels)
+			ch <- prometheus.MustNewConstMetric(desc, prometheus.CounterValue, float64(m.Snapshot().Count()), labelValues...)
+		case gometrics.Counter:
+			desc := prometheus.NewDesc(metricName, "sarama counter "+name, labelNames, constLabels)
+			ch <- prometheus.MustNewConstMetric(desc, prometheus.GaugeValue, float64(m.Snapshot().Count()), labelValues...)
+		case gometrics.Gauge:
+			desc := prometheus.NewDesc(metricName, "sarama gauge "+name, labelNames, constLabels)
+			ch <- prometheus.MustNewConstMetric(desc, prometheus.GaugeValue, float64(m.Snapshot().Value()), labelValues...)
+		case gometrics.Histogram:
+			var (
+				snapshot  = m.Snapshot()
+				quantiles = []float64{0.5, 0.75, 0.95, 0.99}
+				values    = snapshot.Percentiles(quantiles)
+				summary   = make(map[float64]float64, len(quantiles))
+			)
+			for i, q := range quantiles {
+				summary[q] = values[i]
+			}
+			desc := prometheus.NewDesc(metricName, "sarama histogram "+name, labelNames, constLabels)
+			ch <- prometheus.MustNewConstSummary(desc, uint64(snapshot.Count()), float64(snapshot.Sum()), summary, labelValues...)
+		}
+	})
+}
+
+func sanitizeMetricName(name string) string {
+	return strings.ToLower(invalidMetricChars.ReplaceAllString(name, "_"))
+}
+
+var _ prometheus.Collector = (*SaramaCollector)(nil)
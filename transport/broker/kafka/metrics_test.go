@@ -0,0 +1,80 @@
+package kafka
+
+import (
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+	gometrics "github.com/rcrowley/go-metrics"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSaramaCollector(t *testing.T) {
+	registry := gometrics.NewRegistry()
+	gometrics.GetOrRegisterMeter("incoming-byte-rate", registry).Mark(10)
+	gometrics.GetOrRegisterMeter("incoming-byte-rate-for-broker-1", registry).Mark(4)
+	gometrics.GetOrRegisterMeter("record-send-rate-for-topic-orders", registry).Mark(2)
+	gometrics.GetOrRegisterHistogram("request-latency-in-ms", registry, gometrics.NewUniformSample(10)).Update(5)
+
+	reg := prometheus.NewRegistry()
+	require.NoError(t, reg.Register(NewSaramaCollector("orders-service", registry)))
+
+	families, err := reg.Gather()
+	require.NoError(t, err)
+
+	values := map[string]float64{}
+	labels := map[string]map[string]string{}
+	for _, family := range families {
+		for _, metric := range family.GetMetric() {
+			if metric.GetCounter() != nil {
+				values[family.GetName()] = metric.GetCounter().GetValue()
+			}
+			if metric.GetSummary() != nil {
+				values[family.GetName()] = float64(metric.GetSummary().GetSampleCount())
+			}
+			labels[family.GetName()] = map[string]string{}
+			for _, label := range metric.GetLabel() {
+				labels[family.GetName()][label.GetName()] = label.GetValue()
+			}
+		}
+	}
+
+	assert.Equal(t, float64(10), values["kafka_sarama_incoming_byte_rate_total"])
+	assert.Equal(t, float64(4), values["kafka_sarama_broker_incoming_byte_rate_total"])
+	assert.Equal(t, "1", labels["kafka_sarama_broker_incoming_byte_rate_total"][MetricLabelBroker])
+	assert.Equal(t, "orders-service", labels["kafka_sarama_broker_incoming_byte_rate_total"][MetricLabelClient])
+	assert.Equal(t, float64(2), values["kafka_sarama_topic_record_send_rate_total"])
+	assert.Equal(t, "orders", labels["kafka_sarama_topic_record_send_rate_total"][MetricLabelTopic])
+	assert.Equal(t, float64(1), values["kafka_sarama_request_latency_in_ms"])
+}
+
+func TestRegisterSaramaRegistry(t *testing.T) {
+	var (
+		shared = gometrics.NewRegistry()
+		other  = gometrics.NewRegistry()
+	)
+	gometrics.GetOrRegisterMeter("record-send-rate", shared).Mark(1)
+	gometrics.GetOrRegisterMeter("record-send-rate", other).Mark(1)
+
+	// the registry is exported once across the Metrics instances
+	require.NoError(t, (&Metrics{}).RegisterSaramaRegistry("registry-test", shared))
+	require.NoError(t, (&Metrics{}).RegisterSaramaRegistry("registry-test", shared))
+	require.NoError(t, (&Metrics{}).RegisterSaramaRegistry("registry-test", other))
+
+	saramaRegistries.Lock()
+	assert.Equal(t, "registry-test", saramaRegistries.registries[shared])
+	assert.Equal(t, "registry-test-2", saramaRegistries.registries[other])
+	saramaRegistries.Unlock()
+
+	_, err := prometheus.DefaultGatherer.Gather()
+	assert.NoError(t, err)
+}
+
+func TestNilMetrics(t *testing.T) {
+	var m *Metrics
+	assert.NotPanics(t, func() {
+		m.requestPending(1)
+		m.requestTimeout("topic")
+		assert.NoError(t, m.RegisterSaramaRegistry("client", gometrics.NewRegistry()))
+	})
+}
@@ -2,9 +2,12 @@ package kafka
 
 import (
 	"context"
+	"time"
 
 	"github.com/IBM/sarama"
 	"github.com/lengocson131002/go-clean-core/transport/broker"
+	"github.com/lengocson131002/go-clean-core/transport/broker/claimcheck"
+	"github.com/lengocson131002/go-clean-core/transport/broker/flowcontrol"
 )
 
 var (
@@ -50,3 +53,129 @@ func AsyncProducer(errors chan<- *sarama.ProducerError, successes chan<- *sarama
 	}
 	return opt
 }
+
+type shutdownTimeoutKey struct{}
+
+// ShutdownTimeout is the maximum time Unsubscribe and Disconnect wait for in-flight handlers
+func ShutdownTimeout(timeout time.Duration) broker.BrokerOption {
+	return setBrokerOption(shutdownTimeoutKey{}, timeout)
+}
+
+// PartitionsHook is called with the claimed partitions of each topic
+type PartitionsHook func(ctx context.Context, claims map[string][]int32)
+
+type partitionsAssignedKey struct{}
+type partitionsRevokedKey struct{}
+
+// PartitionsAssigned is called when a new consumer group session starts, after a rebalance
+func PartitionsAssigned(hook PartitionsHook) broker.SubscribeOption {
+	return setSubscribeOption(partitionsAssignedKey{}, hook)
+}
+
+// PartitionsRevoked is called when the consumer group session ends, before partitions are reassigned.
+// In-flight handlers of the session are finished and marked offsets are committed right after the hook.
+func PartitionsRevoked(hook PartitionsHook) broker.SubscribeOption {
+	return setSubscribeOption(partitionsRevokedKey{}, hook)
+}
+
+type rebalanceStrategyKey struct{}
+
+// RebalanceStrategy sets the partition assignor of the subscription
+func RebalanceStrategy(strategies ...sarama.BalanceStrategy) broker.SubscribeOption {
+	return setSubscribeOption(rebalanceStrategyKey{}, strategies)
+}
+
+// StickyRebalance uses the sticky assignor which keeps partitions on their current members during a rebalance.
+// It is not the cooperative protocol, sarama only implements eager rebalancing, so partitions are still
+// revoked at the start of each rebalance, but most of them are assigned back to the same member.
+func StickyRebalance() broker.SubscribeOption {
+	return RebalanceStrategy(sarama.NewBalanceStrategySticky())
+}
+
+type subscribeReadyTimeoutKey struct{}
+
+// SubscribeReadyTimeout is the maximum time Subscribe waits for the consumer group to join
+func SubscribeReadyTimeout(timeout time.Duration) broker.SubscribeOption {
+	return setSubscribeOption(subscribeReadyTimeoutKey{}, timeout)
+}
+
+type metricsKey struct{}
+
+// BrokerMetrics exports producer, consumer and sarama client metrics to prometheus
+func BrokerMetrics(m *Metrics) broker.BrokerOption {
+	return setBrokerOption(metricsKey{}, m)
+}
+
+type compressionKey struct{}
+
+// Compression publishes the message with the given codec instead of the codec of the broker config
+func Compression(codec sarama.CompressionCodec) broker.PublishOption {
+	return setPublishOption(compressionKey{}, codec)
+}
+
+type topicCompressionKey struct{}
+
+// TopicCompression sets the compression codec per topic, the Compression publish option has precedence
+func TopicCompression(codecs map[string]sarama.CompressionCodec) broker.BrokerOption {
+	return setBrokerOption(topicCompressionKey{}, codecs)
+}
+
+type maxMessageBytesKey struct{}
+
+// MaxMessageBytes is the maximum body size of a published message,
+// the Producer.MaxMessageBytes of the broker config by default
+func MaxMessageBytes(max int) broker.BrokerOption {
+	return setBrokerOption(maxMessageBytesKey{}, max)
+}
+
+type claimCheckKey struct{}
+
+// ClaimCheck stores bodies larger than the threshold in the store and publishes a reference instead,
+// subscribers of the broker resolve the reference before the handler and its middlewares are called
+func ClaimCheck(store claimcheck.Store, threshold int) broker.BrokerOption {
+	return setBrokerOption(claimCheckKey{}, claimcheck.New(store, threshold))
+}
+
+type codecKey struct{}
+
+// MessageCodec sets the codec mapping broker messages to kafka records, DefaultMarshaler by default
+func MessageCodec(c Codec) broker.BrokerOption {
+	return setBrokerOption(codecKey{}, c)
+}
+
+type delayBucketsKey struct{}
+
+// DelayedDelivery enables the WithPublishDelay and WithPublishAt publish options. Delayed messages wait in the delay topic
+// of the longest bucket not longer than their delay, DefaultDelayBuckets when no bucket is given. The broker forwards
+// the due messages of the delay topics to their topic with the DelayGroup consumer group.
+func DelayedDelivery(buckets ...time.Duration) broker.BrokerOption {
+	if len(buckets) == 0 {
+		buckets = DefaultDelayBuckets
+	}
+	return setBrokerOption(delayBucketsKey{}, buckets)
+}
+
+type prioritiesKey struct{}
+
+// Priorities consumes the topic of each priority, see PriorityTopic, taking turns between them by weight.
+// E.g. with weights high: 8, normal: 2 and low: 1, the handler takes 8 high priority messages, then 2 normal
+// and 1 low priority messages while all of them have a backlog.
+func Priorities(weights map[broker.Priority]int) broker.SubscribeOption {
+	return setSubscribeOption(prioritiesKey{}, weights)
+}
+
+type priorityConcurrencyKey struct{}
+
+// PriorityConcurrency is the number of messages of a Priorities subscription handled at once, DefaultPriorityConcurrency by default
+func PriorityConcurrency(n int) broker.SubscribeOption {
+	return setSubscribeOption(priorityConcurrencyKey{}, n)
+}
+
+type maxInFlightKey struct{}
+
+// MaxInFlight limits the messages of the subscription handled at once across its partitions. A partition waiting
+// for a slot is paused instead of buffering fetched messages. An adaptive limiter shrinks the limit on handler
+// errors and latency, see flowcontrol.NewAdaptiveLimiter.
+func MaxInFlight(limiter *flowcontrol.ConcurrencyLimiter) broker.SubscribeOption {
+	return setSubscribeOption(maxInFlightKey{}, limiter)
+}
@@ -0,0 +1,164 @@
+package kafka
+
+import (
+	"container/list"
+	"context"
+	"sort"
+	"sync"
+
+	"github.com/lengocson131002/go-clean-core/transport/broker"
+)
+
+// PriorityTopic returns the topic of the messages of the priority, the topic itself for PriorityNormal,
+// e.g. `orders.priority.high` for PriorityHigh
+func PriorityTopic(topic string, p broker.Priority) string {
+	if p == broker.PriorityNormal {
+		return topic
+	}
+	return topic + PriorityTopicSeparator + p.String()
+}
+
+// priorityTopics returns the topics of the priorities, highest priority first.
+// The topic itself is always consumed as PriorityNormal.
+func priorityTopics(topic string, weights map[broker.Priority]int) []string {
+	levels := priorityLevels(weights)
+	topics := make([]string, 0, len(levels))
+	for _, p := range levels {
+		topics = append(topics, PriorityTopic(topic, p))
+	}
+	return topics
+}
+
+func priorityLevels(weights map[broker.Priority]int) []broker.Priority {
+	levels := make([]broker.Priority, 0, len(weights)+1)
+	for p := range weights {
+		levels = append(levels, p)
+	}
+	if _, ok := weights[broker.PriorityNormal]; !ok {
+		levels = append(levels, broker.PriorityNormal)
+	}
+	sort.Slice(levels, func(i, j int) bool { return levels[i] > levels[j] })
+	return levels
+}
+
+// priorityScheduler takes turns between the messages waiting in the priority topics of a subscription.
+// In each round a priority gets as many turns as its weight while it has waiting messages, higher priorities first,
+// so lower priorities progress even under a backlog of higher ones.
+type priorityScheduler struct {
+	mutex   sync.Mutex
+	levels  []broker.Priority
+	weights map[broker.Priority]int
+	credits map[broker.Priority]int
+	waiters map[broker.Priority]*list.List
+	topics  map[string]broker.Priority
+	running int
+	limit   int
+}
+
+func newPriorityScheduler(topic string, weights map[broker.Priority]int, limit int) *priorityScheduler {
+	s := &priorityScheduler{
+		levels:  priorityLevels(weights),
+		weights: make(map[broker.Priority]int),
+		credits: make(map[broker.Priority]int),
+		waiters: make(map[broker.Priority]*list.List),
+		topics:  make(map[string]broker.Priority),
+		limit:   limit,
+	}
+
+	if s.limit <= 0 {
+		s.limit = 1
+	}
+
+	for _, p := range s.levels {
+		weight := weights[p]
+		if weight <= 0 {
+			weight = 1
+		}
+		s.weights[p] = weight
+		s.credits[p] = weight
+		s.waiters[p] = list.New()
+		s.topics[PriorityTopic(topic, p)] = p
+	}
+
+	return s
+}
+
+// acquire waits for the turn of the priority of the topic, it returns false when the context is done
+// or the subscriber is closing before the turn came
+func (s *priorityScheduler) acquire(ctx context.Context, closing <-chan struct{}, topic string) bool {
+	s.mutex.Lock()
+	p := s.topics[topic]
+	turn := make(chan struct{})
+	el := s.waiters[p].PushBack(turn)
+	s.dispatch()
+	s.mutex.Unlock()
+
+	select {
+	case <-turn:
+		return true
+	case <-ctx.Done():
+	case <-closing:
+	}
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	select {
+	case <-turn:
+		// the turn came meanwhile, give it to the next one
+		s.running--
+		s.dispatch()
+	default:
+		s.waiters[p].Remove(el)
+	}
+	return false
+}
+
+func (s *priorityScheduler) release() {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.running--
+	s.dispatch()
+}
+
+// dispatch gives turns to the waiting messages while the concurrency limit is not reached, the caller holds the mutex
+func (s *priorityScheduler) dispatch() {
+	for s.running < s.limit {
+		p, ok := s.next()
+		if !ok {
+			return
+		}
+
+		waiters := s.waiters[p]
+		turn := waiters.Remove(waiters.Front()).(chan struct{})
+		s.credits[p]--
+		s.running++
+		close(turn)
+	}
+}
+
+// next returns the highest waiting priority with turns left in the round, a new round starts
+// when the waiting priorities have spent their turns
+func (s *priorityScheduler) next() (broker.Priority, bool) {
+	for round := 0; round < 2; round++ {
+		waiting := false
+		for _, p := range s.levels {
+			if s.waiters[p].Len() == 0 {
+				continue
+			}
+			waiting = true
+			if s.credits[p] > 0 {
+				return p, true
+			}
+		}
+
+		if !waiting {
+			return broker.PriorityNormal, false
+		}
+
+		for p, weight := range s.weights {
+			s.credits[p] = weight
+		}
+	}
+	return broker.PriorityNormal, false
+}
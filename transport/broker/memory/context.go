@@ -0,0 +1,17 @@
+package memory
+
+import (
+	"context"
+
+	"github.com/lengocson131002/go-clean-core/transport/broker"
+)
+
+// setBrokerOption returns a function to setup a context with given value
+func setBrokerOption(k, v interface{}) broker.BrokerOption {
+	return func(o *broker.BrokerOptions) {
+		if o.Context == nil {
+			o.Context = context.Background()
+		}
+		o.Context = context.WithValue(o.Context, k, v)
+	}
+}
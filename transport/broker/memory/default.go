@@ -0,0 +1,16 @@
+package memory
+
+import (
+	"time"
+
+	"github.com/lengocson131002/go-clean-core/logger/logrus"
+)
+
+var (
+	DefaultLogger          = logrus.NewLogrusLogger()
+	DefaultShutdownTimeout = time.Second * 30
+	RequestReplyTimeout    = time.Second * 60
+
+	// DefaultReplyTopicPrefix prefixes the reply topic of each broker instance
+	DefaultReplyTopicPrefix = "reply."
+)
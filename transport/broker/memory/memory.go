@@ -0,0 +1,487 @@
+package memory
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/lengocson131002/go-clean-core/logger"
+	"github.com/lengocson131002/go-clean-core/transport/broker"
+)
+
+var (
+	ErrNotConnected = errors.New("memory broker is not connected")
+)
+
+// memoryBroker delivers messages between the subscribers of the process, e.g. for tests and local development.
+// Messages are not persisted, the pending and delayed ones are dropped on disconnect.
+type memoryBroker struct {
+	opts broker.BrokerOptions
+
+	mutex     sync.Mutex
+	connected bool
+	queues    map[string]map[string]*queue // topic -> group -> queue
+	subs      []*subscriber
+	timers    map[*time.Timer]struct{}
+
+	// request-reply pattern
+	replyTopic string
+	replySubs  map[string]broker.Subscriber
+	resps      sync.Map
+}
+
+func NewMemoryBroker(opts ...broker.BrokerOption) broker.Broker {
+	options := broker.BrokerOptions{
+		Context: context.Background(),
+		Logger:  DefaultLogger,
+	}
+
+	for _, o := range opts {
+		o(&options)
+	}
+
+	return &memoryBroker{
+		opts: options,
+	}
+}
+
+type subscriber struct {
+	b       *memoryBroker
+	t       string
+	group   string
+	q       *queue
+	opts    broker.SubscribeOptions
+	closing chan struct{}
+	done    chan struct{}
+
+	closeOnce sync.Once
+	closeErr  error
+}
+
+type publication struct {
+	t     string
+	err   error
+	m     *broker.Message
+	mutex sync.Mutex
+	acked bool
+}
+
+func (p *publication) Topic() string {
+	return p.t
+}
+
+func (p *publication) Message() *broker.Message {
+	return p.m
+}
+
+// Ack implements broker.Event, messages are removed from the queue when they are taken so it only records the ack
+func (p *publication) Ack() error {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	p.acked = true
+	return nil
+}
+
+func (p *publication) Error() error {
+	return p.err
+}
+
+func (s *subscriber) Options() broker.SubscribeOptions {
+	return s.opts
+}
+
+func (s *subscriber) Topic() string {
+	return s.t
+}
+
+// Unsubscribe stops taking messages and waits for the in-flight handler.
+// The pending messages of the group are dropped when it was the last subscriber of the group.
+func (s *subscriber) Unsubscribe() error {
+	b := s.b
+	b.mutex.Lock()
+	for i, sub := range b.subs {
+		if sub == s {
+			b.subs = append(b.subs[:i], b.subs[i+1:]...)
+			break
+		}
+	}
+	b.mutex.Unlock()
+
+	return s.close()
+}
+
+func (s *subscriber) close() error {
+	s.closeOnce.Do(func() {
+		s.closeErr = s.drain()
+	})
+	return s.closeErr
+}
+
+func (s *subscriber) drain() error {
+	b := s.b
+
+	b.mutex.Lock()
+	s.q.subs--
+	if s.q.subs == 0 {
+		delete(b.queues[s.t], s.group)
+		if len(b.queues[s.t]) == 0 {
+			delete(b.queues, s.t)
+		}
+	}
+	b.mutex.Unlock()
+
+	close(s.closing)
+
+	timeout := b.getShutdownTimeout()
+	select {
+	case <-s.done:
+		return nil
+	case <-time.After(timeout):
+		return broker.DrainTimeoutError{Timeout: timeout}
+	}
+}
+
+func (s *subscriber) run(h broker.Handler) {
+	defer close(s.done)
+
+	for {
+		select {
+		case <-s.closing:
+			return
+		default:
+		}
+
+		m, ok := s.q.pop()
+		if !ok {
+			select {
+			case <-s.q.notify:
+			case <-s.closing:
+				return
+			}
+			continue
+		}
+
+		s.handle(h, m)
+	}
+}
+
+func (s *subscriber) handle(h broker.Handler, m *broker.Message) {
+	b := s.b
+	ctx := broker.ContextWithEnvelope(context.Background(), m)
+	p := &publication{t: s.t, m: m}
+
+	err := h(ctx, p)
+	if err == nil {
+		if s.opts.AutoAck {
+			p.Ack()
+		}
+		return
+	}
+
+	p.err = err
+	if errHandler := b.opts.ErrorHandler; errHandler != nil {
+		errHandler(ctx, p)
+	} else {
+		b.getLogger().Errorf(ctx, "[memory] subscriber error: %v", err)
+	}
+}
+
+func (b *memoryBroker) Address() string {
+	return "memory"
+}
+
+func (b *memoryBroker) Connect() error {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	if b.connected {
+		return nil
+	}
+
+	b.queues = make(map[string]map[string]*queue)
+	b.subs = make([]*subscriber, 0)
+	b.timers = make(map[*time.Timer]struct{})
+	b.replyTopic = DefaultReplyTopicPrefix + uuid.New().String()
+	b.replySubs = make(map[string]broker.Subscriber)
+	b.connected = true
+
+	return nil
+}
+
+// Disconnect drops the delayed messages and drains the subscribers
+func (b *memoryBroker) Disconnect() error {
+	b.mutex.Lock()
+	if !b.connected {
+		b.mutex.Unlock()
+		return nil
+	}
+	for t := range b.timers {
+		t.Stop()
+	}
+	b.timers = nil
+	subs := b.subs
+	b.subs = nil
+	b.mutex.Unlock()
+
+	var wg sync.WaitGroup
+	for _, sub := range subs {
+		wg.Add(1)
+		go func(sub *subscriber) {
+			defer wg.Done()
+			if err := sub.close(); err != nil {
+				b.getLogger().Errorf(b.opts.Context, "failed to close subscriber of topic %s: %s", sub.t, err)
+			}
+		}(sub)
+	}
+	wg.Wait()
+
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	b.queues = nil
+	b.replySubs = nil
+	b.connected = false
+	return nil
+}
+
+func (b *memoryBroker) Init(opts ...broker.BrokerOption) error {
+	for _, o := range opts {
+		o(&b.opts)
+	}
+	return nil
+}
+
+func (b *memoryBroker) Options() broker.BrokerOptions {
+	return b.opts
+}
+
+func (b *memoryBroker) Publish(ctx context.Context, topic string, msg *broker.Message, opts ...broker.PublishOption) error {
+	return b.getPublishFunc()(ctx, topic, msg, opts...)
+}
+
+// publish is the innermost PublishFunc wrapped by the publish middlewares. Delayed messages are kept in timers
+// until they are due, then every consumer group of the topic gets a copy queued by priority.
+func (b *memoryBroker) publish(ctx context.Context, topic string, msg *broker.Message, opts ...broker.PublishOption) error {
+	options := broker.PublishOptions{}
+	for _, opt := range opts {
+		opt(&options)
+	}
+
+	broker.PopulateEnvelope(ctx, msg)
+	msg.SetDelivery(options)
+
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	if !b.connected {
+		return ErrNotConnected
+	}
+
+	if delay := time.Until(options.DeliverAt); delay > 0 {
+		m := copyMessage(msg)
+		var t *time.Timer
+		t = time.AfterFunc(delay, func() {
+			b.mutex.Lock()
+			defer b.mutex.Unlock()
+			if _, ok := b.timers[t]; !ok {
+				return
+			}
+			delete(b.timers, t)
+			b.deliver(topic, m, options.Priority)
+		})
+		b.timers[t] = struct{}{}
+		return nil
+	}
+
+	b.deliver(topic, msg, options.Priority)
+	return nil
+}
+
+// deliver queues a copy of the message for each consumer group of the topic, the caller holds the mutex
+func (b *memoryBroker) deliver(topic string, msg *broker.Message, priority broker.Priority) {
+	for _, q := range b.queues[topic] {
+		q.push(copyMessage(msg), priority)
+	}
+}
+
+func (b *memoryBroker) getPublishFunc() broker.PublishFunc {
+	return broker.ChainPublishMiddlewares(b.publish, b.opts.PublishMiddlewares...)
+}
+
+// PublishAndReceive publishes the message with the reply topic in the ReplyToHeader header and waits for the reply.
+// The responder publishes the reply to that topic with the same CorrelationIdHeader header.
+func (b *memoryBroker) PublishAndReceive(ctx context.Context, topic string, msg *broker.Message, opts ...broker.PublishOption) (*broker.Message, error) {
+	options := broker.PublishOptions{
+		Timeout: RequestReplyTimeout,
+	}
+
+	for _, opt := range opts {
+		opt(&options)
+	}
+
+	replyTo, err := b.ensureReplySubscriber(options.ReplyToTopic)
+	if err != nil {
+		return nil, err
+	}
+
+	if msg.Headers == nil {
+		msg.Headers = make(map[string]string)
+	}
+
+	correlationId, ok := msg.Headers[broker.CorrelationIdHeader]
+	if !ok || len(correlationId) == 0 {
+		correlationId = uuid.New().String()
+		msg.Headers[broker.CorrelationIdHeader] = correlationId
+	}
+	msg.Headers[broker.ReplyToHeader] = replyTo
+
+	msgChan := make(chan *broker.Message, 1)
+	b.resps.Store(correlationId, msgChan)
+	defer b.resps.Delete(correlationId)
+
+	if err := b.getPublishFunc()(ctx, topic, msg, opts...); err != nil {
+		return nil, err
+	}
+
+	select {
+	case reply := <-msgChan:
+		return reply, nil
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	case <-time.After(options.Timeout):
+		return nil, broker.RequestTimeoutResponse{
+			Timeout: options.Timeout,
+		}
+	}
+}
+
+// ensureReplySubscriber subscribes to the reply topic once and returns its name
+func (b *memoryBroker) ensureReplySubscriber(replyTo string) (string, error) {
+	b.mutex.Lock()
+	if !b.connected {
+		b.mutex.Unlock()
+		return "", ErrNotConnected
+	}
+	if len(replyTo) == 0 {
+		replyTo = b.replyTopic
+	}
+	_, ok := b.replySubs[replyTo]
+	b.mutex.Unlock()
+
+	if ok {
+		return replyTo, nil
+	}
+
+	sub, err := b.subscribe(replyTo, func(ctx context.Context, e broker.Event) error {
+		if msgChan, ok := b.resps.LoadAndDelete(e.Message().Header(broker.CorrelationIdHeader)); ok {
+			msgChan.(chan *broker.Message) <- e.Message()
+		}
+		return nil
+	}, broker.SubscribeOptions{AutoAck: true})
+	if err != nil {
+		return "", err
+	}
+
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	if _, ok := b.replySubs[replyTo]; ok {
+		// subscribed concurrently
+		go sub.Unsubscribe()
+		return replyTo, nil
+	}
+	b.replySubs[replyTo] = sub
+	return replyTo, nil
+}
+
+// Subscribe consumes the messages of the topic. Subscribers of the same group share the messages,
+// without group every subscriber gets every message.
+func (b *memoryBroker) Subscribe(topic string, handler broker.Handler, opts ...broker.SubscribeOption) (broker.Subscriber, error) {
+	opt := broker.SubscribeOptions{
+		AutoAck: true,
+	}
+
+	for _, o := range opts {
+		o(&opt)
+	}
+
+	sub, err := b.subscribe(topic, broker.ChainSubscriptionHandler(handler, opt, b.opts.SubscribeMiddlewares...), opt)
+	if err != nil {
+		return nil, err
+	}
+
+	b.getLogger().Infof(b.opts.Context, "Subcribed to topic: %s. Group: %s", topic, sub.group)
+	return sub, nil
+}
+
+func (b *memoryBroker) subscribe(topic string, h broker.Handler, opt broker.SubscribeOptions) (*subscriber, error) {
+	group := opt.Group
+	if len(group) == 0 {
+		group = uuid.New().String()
+	}
+
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	if !b.connected {
+		return nil, ErrNotConnected
+	}
+
+	groups, ok := b.queues[topic]
+	if !ok {
+		groups = make(map[string]*queue)
+		b.queues[topic] = groups
+	}
+
+	q, ok := groups[group]
+	if !ok {
+		q = newQueue()
+		groups[group] = q
+	}
+	q.subs++
+
+	sub := &subscriber{
+		b:       b,
+		t:       topic,
+		group:   group,
+		q:       q,
+		opts:    opt,
+		closing: make(chan struct{}),
+		done:    make(chan struct{}),
+	}
+
+	go sub.run(h)
+
+	b.subs = append(b.subs, sub)
+	return sub, nil
+}
+
+func (b *memoryBroker) getShutdownTimeout() time.Duration {
+	if t, ok := b.opts.Context.Value(shutdownTimeoutKey{}).(time.Duration); ok && t > 0 {
+		return t
+	}
+	return DefaultShutdownTimeout
+}
+
+func (b *memoryBroker) getLogger() logger.Logger {
+	logger := b.opts.Logger
+	if logger == nil {
+		logger = DefaultLogger
+	}
+	return logger
+}
+
+func (b *memoryBroker) String() string {
+	return "memory broker implementation"
+}
+
+func copyMessage(m *broker.Message) *broker.Message {
+	headers := make(map[string]string, len(m.Headers))
+	for k, v := range m.Headers {
+		headers[k] = v
+	}
+	return &broker.Message{
+		Headers: headers,
+		Body:    append([]byte(nil), m.Body...),
+	}
+}
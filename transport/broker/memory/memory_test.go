@@ -0,0 +1,148 @@
+package memory
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/lengocson131002/go-clean-core/transport/broker"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newConnectedBroker(t *testing.T) broker.Broker {
+	b := NewMemoryBroker()
+	require.NoError(t, b.Connect())
+	t.Cleanup(func() { b.Disconnect() })
+	return b
+}
+
+func TestPublishSubscribe(t *testing.T) {
+	b := newConnectedBroker(t)
+	ctx := context.Background()
+
+	var all, shared atomic.Int32
+	received := make(chan *broker.Message, 10)
+
+	_, err := b.Subscribe("orders", func(ctx context.Context, e broker.Event) error {
+		all.Add(1)
+		received <- e.Message()
+		return nil
+	})
+	require.NoError(t, err)
+
+	for i := 0; i < 2; i++ {
+		_, err := b.Subscribe("orders", func(ctx context.Context, e broker.Event) error {
+			shared.Add(1)
+			return nil
+		}, broker.WithSubscribeGroup("billing"))
+		require.NoError(t, err)
+	}
+
+	for i := 0; i < 4; i++ {
+		require.NoError(t, b.Publish(ctx, "orders", &broker.Message{Body: []byte("order")}))
+	}
+
+	assert.Eventually(t, func() bool { return all.Load() == 4 && shared.Load() == 4 }, time.Second, 10*time.Millisecond)
+
+	m := <-received
+	assert.Equal(t, "order", string(m.Body))
+	assert.NotEmpty(t, m.Header(broker.MessageIdHeader))
+}
+
+func TestDelayedDelivery(t *testing.T) {
+	b := newConnectedBroker(t)
+
+	received := make(chan time.Time, 1)
+	_, err := b.Subscribe("reminders", func(ctx context.Context, e broker.Event) error {
+		_, ok := e.Message().DeliverAt()
+		assert.True(t, ok)
+		received <- time.Now()
+		return nil
+	})
+	require.NoError(t, err)
+
+	start := time.Now()
+	require.NoError(t, b.Publish(context.Background(), "reminders", &broker.Message{}, broker.WithPublishDelay(100*time.Millisecond)))
+
+	select {
+	case at := <-received:
+		assert.GreaterOrEqual(t, at.Sub(start), 100*time.Millisecond)
+	case <-time.After(time.Second):
+		t.Fatal("delayed message was not delivered")
+	}
+}
+
+func TestPriorityDelivery(t *testing.T) {
+	b := newConnectedBroker(t)
+	ctx := context.Background()
+
+	var (
+		mutex   sync.Mutex
+		order   []broker.Priority
+		blocked = make(chan struct{})
+		started = make(chan struct{}, 1)
+	)
+
+	_, err := b.Subscribe("jobs", func(ctx context.Context, e broker.Event) error {
+		if e.Message().Header("block") != "" {
+			started <- struct{}{}
+			<-blocked
+			return nil
+		}
+		mutex.Lock()
+		order = append(order, e.Message().Priority())
+		mutex.Unlock()
+		return nil
+	})
+	require.NoError(t, err)
+
+	// keep the subscriber busy while the others are queued
+	require.NoError(t, b.Publish(ctx, "jobs", &broker.Message{Headers: map[string]string{"block": "true"}}))
+	<-started
+
+	require.NoError(t, b.Publish(ctx, "jobs", &broker.Message{}, broker.WithPublishPriority(broker.PriorityLow)))
+	require.NoError(t, b.Publish(ctx, "jobs", &broker.Message{}))
+	require.NoError(t, b.Publish(ctx, "jobs", &broker.Message{}, broker.WithPublishPriority(broker.PriorityHigh)))
+	close(blocked)
+
+	assert.Eventually(t, func() bool {
+		mutex.Lock()
+		defer mutex.Unlock()
+		return len(order) == 3
+	}, time.Second, 10*time.Millisecond)
+	assert.Equal(t, []broker.Priority{broker.PriorityHigh, broker.PriorityNormal, broker.PriorityLow}, order)
+}
+
+func TestPublishAndReceive(t *testing.T) {
+	b := newConnectedBroker(t)
+	ctx := context.Background()
+
+	_, err := b.Subscribe("ping", func(ctx context.Context, e broker.Event) error {
+		reply := broker.NewReply(e.Message(), []byte("pong"))
+		return b.Publish(ctx, e.Message().Header(broker.ReplyToHeader), reply)
+	})
+	require.NoError(t, err)
+
+	reply, err := b.PublishAndReceive(ctx, "ping", &broker.Message{Body: []byte("ping")}, broker.WithPublishTimeout(time.Second))
+	require.NoError(t, err)
+	assert.Equal(t, "pong", string(reply.Body))
+}
+
+func TestUnsubscribe(t *testing.T) {
+	b := newConnectedBroker(t)
+
+	var count atomic.Int32
+	sub, err := b.Subscribe("orders", func(ctx context.Context, e broker.Event) error {
+		count.Add(1)
+		return nil
+	})
+	require.NoError(t, err)
+	require.NoError(t, sub.Unsubscribe())
+
+	require.NoError(t, b.Publish(context.Background(), "orders", &broker.Message{}))
+	time.Sleep(20 * time.Millisecond)
+	assert.Equal(t, int32(0), count.Load())
+}
@@ -0,0 +1,14 @@
+package memory
+
+import (
+	"time"
+
+	"github.com/lengocson131002/go-clean-core/transport/broker"
+)
+
+type shutdownTimeoutKey struct{}
+
+// ShutdownTimeout is the maximum time Unsubscribe and Disconnect wait for in-flight handlers
+func ShutdownTimeout(timeout time.Duration) broker.BrokerOption {
+	return setBrokerOption(shutdownTimeoutKey{}, timeout)
+}
@@ -0,0 +1,93 @@
+package memory
+
+import (
+	"container/heap"
+	"sync"
+
+	"github.com/lengocson131002/go-clean-core/transport/broker"
+)
+
+type item struct {
+	m        *broker.Message
+	priority broker.Priority
+	seq      uint64
+}
+
+// items is a heap of messages, higher priorities first then in publish order
+type items []*item
+
+func (h items) Len() int { return len(h) }
+
+func (h items) Less(i, j int) bool {
+	if h[i].priority != h[j].priority {
+		return h[i].priority > h[j].priority
+	}
+	return h[i].seq < h[j].seq
+}
+
+func (h items) Swap(i, j int) { h[i], h[j] = h[j], h[i] }
+
+func (h *items) Push(x interface{}) { *h = append(*h, x.(*item)) }
+
+func (h *items) Pop() interface{} {
+	old := *h
+	n := len(old)
+	it := old[n-1]
+	old[n-1] = nil
+	*h = old[:n-1]
+	return it
+}
+
+// queue holds the pending messages of a consumer group, the subscribers of the group take turns
+type queue struct {
+	mutex  sync.Mutex
+	items  items
+	seq    uint64
+	notify chan struct{}
+	subs   int
+}
+
+func newQueue() *queue {
+	return &queue{
+		notify: make(chan struct{}, 1),
+	}
+}
+
+func (q *queue) push(m *broker.Message, priority broker.Priority) {
+	q.mutex.Lock()
+	q.seq++
+	heap.Push(&q.items, &item{m: m, priority: priority, seq: q.seq})
+	q.mutex.Unlock()
+
+	q.signal()
+}
+
+func (q *queue) pop() (*broker.Message, bool) {
+	q.mutex.Lock()
+	if len(q.items) == 0 {
+		q.mutex.Unlock()
+		return nil, false
+	}
+	it := heap.Pop(&q.items).(*item)
+	remaining := len(q.items)
+	q.mutex.Unlock()
+
+	// wake another subscriber of the group for the remaining messages
+	if remaining > 0 {
+		q.signal()
+	}
+	return it.m, true
+}
+
+func (q *queue) len() int {
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+	return len(q.items)
+}
+
+func (q *queue) signal() {
+	select {
+	case q.notify <- struct{}{}:
+	default:
+	}
+}
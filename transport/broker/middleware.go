@@ -0,0 +1,33 @@
+package broker
+
+import "context"
+
+// PublishFunc publishes a message to a topic.
+type PublishFunc func(ctx context.Context, topic string, m *Message, opts ...PublishOption) error
+
+// PublishMiddleware wraps a PublishFunc with cross-cutting behavior.
+type PublishMiddleware func(next PublishFunc) PublishFunc
+
+// SubscribeMiddleware wraps a subscription Handler with cross-cutting behavior.
+type SubscribeMiddleware func(next Handler) Handler
+
+// ChainPublishMiddlewares wraps the publish function, the first middleware is the outermost one.
+func ChainPublishMiddlewares(publish PublishFunc, mws ...PublishMiddleware) PublishFunc {
+	for i := len(mws) - 1; i >= 0; i-- {
+		publish = mws[i](publish)
+	}
+	return publish
+}
+
+// ChainSubscribeMiddlewares wraps the handler, the first middleware is the outermost one.
+func ChainSubscribeMiddlewares(handler Handler, mws ...SubscribeMiddleware) Handler {
+	for i := len(mws) - 1; i >= 0; i-- {
+		handler = mws[i](handler)
+	}
+	return handler
+}
+
+// ChainSubscriptionHandler wraps the handler with the middlewares of the subscription, then with the middlewares of the broker.
+func ChainSubscriptionHandler(handler Handler, opts SubscribeOptions, mws ...SubscribeMiddleware) Handler {
+	return ChainSubscribeMiddlewares(ChainSubscribeMiddlewares(handler, opts.Middlewares...), mws...)
+}
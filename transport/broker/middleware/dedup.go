@@ -0,0 +1,26 @@
+package middleware
+
+import (
+	"time"
+
+	"github.com/lengocson131002/go-clean-core/transport/broker"
+	"github.com/lengocson131002/go-clean-core/transport/broker/dedup"
+)
+
+// Deduplication skips messages whose id was already handled successfully within the ttl.
+// Messages without a message id header are always handled.
+//
+// It records the ids in a dedup.MemoryStore of the default capacity, use dedup.Middleware
+// for a shared store or a transactional one.
+func Deduplication(ttl time.Duration) broker.SubscribeMiddleware {
+	return dedup.Middleware(dedup.NewMemoryStore(0), dedup.WithTTL(ttl), dedup.WithKeyFunc(topicMessageIdKey))
+}
+
+// topicMessageIdKey scopes the message id by topic, the same id can be used on different topics, e.g. request and reply
+func topicMessageIdKey(e broker.Event) string {
+	id := dedup.MessageIdKey(e)
+	if len(id) == 0 {
+		return ""
+	}
+	return e.Topic() + "/" + id
+}
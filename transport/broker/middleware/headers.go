@@ -0,0 +1,26 @@
+package middleware
+
+import (
+	"context"
+
+	"github.com/lengocson131002/go-clean-core/transport/broker"
+)
+
+// StampHeaders sets the given headers on every published message unless they are already set
+func StampHeaders(headers map[string]string) broker.PublishMiddleware {
+	return func(next broker.PublishFunc) broker.PublishFunc {
+		return func(ctx context.Context, topic string, m *broker.Message, opts ...broker.PublishOption) error {
+			if m.Headers == nil {
+				m.Headers = make(map[string]string, len(headers))
+			}
+
+			for key, value := range headers {
+				if _, ok := m.Headers[key]; !ok {
+					m.Headers[key] = value
+				}
+			}
+
+			return next(ctx, topic, m, opts...)
+		}
+	}
+}
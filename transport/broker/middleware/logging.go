@@ -0,0 +1,53 @@
+package middleware
+
+import (
+	"context"
+	"time"
+
+	"github.com/lengocson131002/go-clean-core/logger"
+	"github.com/lengocson131002/go-clean-core/transport/broker"
+)
+
+// PublishLogging logs every published message with its duration and error
+func PublishLogging(log logger.Logger) broker.PublishMiddleware {
+	return func(next broker.PublishFunc) broker.PublishFunc {
+		return func(ctx context.Context, topic string, m *broker.Message, opts ...broker.PublishOption) error {
+			start := time.Now()
+			err := next(ctx, topic, m, opts...)
+			if err != nil {
+				log.Errorf(ctx, "[broker] failed to publish message to topic %s. Headers: %v. Duration: %dms. Error: %v", topic, m.Headers, time.Since(start).Milliseconds(), err)
+				return err
+			}
+
+			log.Infof(ctx, "[broker] published message to topic %s. Headers: %v. Duration: %dms", topic, m.Headers, time.Since(start).Milliseconds())
+			log.Debugf(ctx, "[broker] published message body: %s", logger.MaskSensitiveData(string(m.Body)))
+			return nil
+		}
+	}
+}
+
+// SubscribeLogging logs every consumed message with its handling duration and error
+func SubscribeLogging(log logger.Logger) broker.SubscribeMiddleware {
+	return func(next broker.Handler) broker.Handler {
+		return func(ctx context.Context, e broker.Event) error {
+			var (
+				start   = time.Now()
+				headers map[string]string
+			)
+
+			if m := e.Message(); m != nil {
+				headers = m.Headers
+				log.Debugf(ctx, "[broker] consumed message body: %s", logger.MaskSensitiveData(string(m.Body)))
+			}
+
+			err := next(ctx, e)
+			if err != nil {
+				log.Errorf(ctx, "[broker] failed to handle message of topic %s. Headers: %v. Duration: %dms. Error: %v", e.Topic(), headers, time.Since(start).Milliseconds(), err)
+				return err
+			}
+
+			log.Infof(ctx, "[broker] handled message of topic %s. Headers: %v. Duration: %dms", e.Topic(), headers, time.Since(start).Milliseconds())
+			return nil
+		}
+	}
+}
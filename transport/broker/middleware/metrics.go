@@ -0,0 +1,114 @@
+package middleware
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/lengocson131002/go-clean-core/transport/broker"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+const (
+	MetricLabelTopic  = "topic"
+	MetricLabelStatus = "status"
+
+	metricStatusSuccess = "success"
+	metricStatusError   = "error"
+)
+
+type PrometheusMetrics struct {
+	PublishedCounter  *prometheus.CounterVec
+	PublishHistogram  *prometheus.HistogramVec
+	ConsumedCounter   *prometheus.CounterVec
+	HandlingHistogram *prometheus.HistogramVec
+}
+
+// NewPrometheusMetrics registers the broker collectors to the default prometheus registerer
+func NewPrometheusMetrics() (*PrometheusMetrics, error) {
+	publishedCounter := prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "broker_messages_published_total",
+		Help: "Total published messages, partitioned by topic and status",
+	}, []string{MetricLabelTopic, MetricLabelStatus})
+
+	publishHistogram := prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Name: "broker_publish_duration_seconds",
+		Help: "Publish latency in seconds, partitioned by topic",
+	}, []string{MetricLabelTopic})
+
+	consumedCounter := prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "broker_messages_consumed_total",
+		Help: "Total consumed messages, partitioned by topic and status",
+	}, []string{MetricLabelTopic, MetricLabelStatus})
+
+	handlingHistogram := prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Name: "broker_handle_duration_seconds",
+		Help: "Message handling time in seconds, partitioned by topic",
+	}, []string{MetricLabelTopic})
+
+	m := &PrometheusMetrics{}
+	var err error
+	if m.PublishedCounter, err = register(publishedCounter); err != nil {
+		return nil, err
+	}
+	if m.PublishHistogram, err = register(publishHistogram); err != nil {
+		return nil, err
+	}
+	if m.ConsumedCounter, err = register(consumedCounter); err != nil {
+		return nil, err
+	}
+	if m.HandlingHistogram, err = register(handlingHistogram); err != nil {
+		return nil, err
+	}
+
+	return m, nil
+}
+
+// register returns the already registered collector when the same one was registered before
+func register[T prometheus.Collector](collector T) (T, error) {
+	if err := prometheus.DefaultRegisterer.Register(collector); err != nil {
+		var are prometheus.AlreadyRegisteredError
+		if errors.As(err, &are) {
+			if existing, ok := are.ExistingCollector.(T); ok {
+				return existing, nil
+			}
+		}
+		return collector, err
+	}
+	return collector, nil
+}
+
+func (p *PrometheusMetrics) PublishMiddleware() broker.PublishMiddleware {
+	return func(next broker.PublishFunc) broker.PublishFunc {
+		return func(ctx context.Context, topic string, m *broker.Message, opts ...broker.PublishOption) error {
+			start := time.Now()
+			err := next(ctx, topic, m, opts...)
+
+			p.PublishHistogram.WithLabelValues(topic).Observe(time.Since(start).Seconds())
+			p.PublishedCounter.WithLabelValues(topic, status(err)).Inc()
+
+			return err
+		}
+	}
+}
+
+func (p *PrometheusMetrics) SubscribeMiddleware() broker.SubscribeMiddleware {
+	return func(next broker.Handler) broker.Handler {
+		return func(ctx context.Context, e broker.Event) error {
+			start := time.Now()
+			err := next(ctx, e)
+
+			p.HandlingHistogram.WithLabelValues(e.Topic()).Observe(time.Since(start).Seconds())
+			p.ConsumedCounter.WithLabelValues(e.Topic(), status(err)).Inc()
+
+			return err
+		}
+	}
+}
+
+func status(err error) string {
+	if err != nil {
+		return metricStatusError
+	}
+	return metricStatusSuccess
+}
@@ -0,0 +1,112 @@
+package middleware
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/lengocson131002/go-clean-core/logger/logrus"
+	"github.com/lengocson131002/go-clean-core/transport/broker"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type testEvent struct {
+	topic string
+	m     *broker.Message
+}
+
+func (e *testEvent) Topic() string            { return e.topic }
+func (e *testEvent) Message() *broker.Message { return e.m }
+func (e *testEvent) Ack() error               { return nil }
+func (e *testEvent) Error() error             { return nil }
+
+func TestChainOrder(t *testing.T) {
+	var calls []string
+	trace := func(name string) broker.SubscribeMiddleware {
+		return func(next broker.Handler) broker.Handler {
+			return func(ctx context.Context, e broker.Event) error {
+				calls = append(calls, name)
+				return next(ctx, e)
+			}
+		}
+	}
+
+	handler := broker.ChainSubscribeMiddlewares(func(ctx context.Context, e broker.Event) error {
+		calls = append(calls, "handler")
+		return nil
+	}, trace("first"), trace("second"))
+
+	require.NoError(t, handler(context.Background(), &testEvent{}))
+	assert.Equal(t, []string{"first", "second", "handler"}, calls)
+}
+
+func TestRecovery(t *testing.T) {
+	handler := Recovery(logrus.NewLogrusLogger())(func(ctx context.Context, e broker.Event) error {
+		panic("boom")
+	})
+
+	err := handler(context.Background(), &testEvent{topic: "topic"})
+	assert.ErrorAs(t, err, &PanicError{})
+}
+
+func TestDeduplication(t *testing.T) {
+	var (
+		count   int
+		failing = true
+	)
+	handler := Deduplication(time.Minute)(func(ctx context.Context, e broker.Event) error {
+		count++
+		if failing {
+			failing = false
+			return errors.New("failed")
+		}
+		return nil
+	})
+
+	event := &testEvent{
+		topic: "topic",
+		m:     &broker.Message{Headers: map[string]string{broker.MessageIdHeader: "1"}},
+	}
+
+	assert.Error(t, handler(context.Background(), event))
+	assert.NoError(t, handler(context.Background(), event))
+	assert.NoError(t, handler(context.Background(), event))
+	assert.Equal(t, 2, count)
+
+	// same id on another topic
+	assert.NoError(t, handler(context.Background(), &testEvent{topic: "reply", m: event.m}))
+	assert.Equal(t, 3, count)
+}
+
+func TestStampHeaders(t *testing.T) {
+	var published *broker.Message
+	publish := broker.ChainPublishMiddlewares(func(ctx context.Context, topic string, m *broker.Message, opts ...broker.PublishOption) error {
+		published = m
+		return nil
+	}, StampHeaders(map[string]string{"source": "core", "tenant": "default"}))
+
+	err := publish(context.Background(), "topic", &broker.Message{Headers: map[string]string{"tenant": "vn"}})
+	require.NoError(t, err)
+	assert.Equal(t, "core", published.Headers["source"])
+	assert.Equal(t, "vn", published.Headers["tenant"])
+}
+
+func TestPrometheusMetrics(t *testing.T) {
+	metrics, err := NewPrometheusMetrics()
+	require.NoError(t, err)
+
+	// registering twice reuses the collectors
+	again, err := NewPrometheusMetrics()
+	require.NoError(t, err)
+	assert.Same(t, metrics.ConsumedCounter, again.ConsumedCounter)
+
+	handler := metrics.SubscribeMiddleware()(func(ctx context.Context, e broker.Event) error {
+		return errors.New("failed")
+	})
+	handler(context.Background(), &testEvent{topic: "metrics.topic"})
+
+	assert.Equal(t, float64(1), testutil.ToFloat64(metrics.ConsumedCounter.WithLabelValues("metrics.topic", metricStatusError)))
+}
@@ -0,0 +1,34 @@
+package middleware
+
+import (
+	"context"
+	"fmt"
+	"runtime/debug"
+
+	"github.com/lengocson131002/go-clean-core/logger"
+	"github.com/lengocson131002/go-clean-core/transport/broker"
+)
+
+type PanicError struct {
+	Value interface{}
+}
+
+func (e PanicError) Error() string {
+	return fmt.Sprintf("Handler panic: %v", e.Value)
+}
+
+// Recovery turns a panic of the handler into a PanicError so the subscriber keeps consuming
+func Recovery(log logger.Logger) broker.SubscribeMiddleware {
+	return func(next broker.Handler) broker.Handler {
+		return func(ctx context.Context, e broker.Event) (err error) {
+			defer func() {
+				if p := recover(); p != nil {
+					log.Errorf(ctx, "[broker] recovered from panic while handling message of topic %s: %v\n%s", e.Topic(), p, debug.Stack())
+					err = PanicError{Value: p}
+				}
+			}()
+
+			return next(ctx, e)
+		}
+	}
+}
@@ -0,0 +1,56 @@
+package nats
+
+import (
+	"github.com/google/uuid"
+	"github.com/lengocson131002/go-clean-core/transport/broker"
+	"github.com/nats-io/nats.go"
+)
+
+const (
+	CorrelationIdHeader = broker.CorrelationIdHeader
+	ReplyToHeader       = broker.ReplyToHeader
+)
+
+// marshal maps the message to a NATS message, the message id is also set as
+// the `Nats-Msg-Id` header used by JetStream to discard duplicated publishes
+func marshal(topic string, msg *broker.Message) *nats.Msg {
+	if len(msg.Headers) == 0 {
+		msg.Headers = make(map[string]string)
+	}
+
+	correlationId, ok := msg.Headers[CorrelationIdHeader]
+	if !ok || len(correlationId) == 0 {
+		correlationId = uuid.New().String()
+		msg.Headers[CorrelationIdHeader] = correlationId
+	}
+
+	header := make(nats.Header, len(msg.Headers)+1)
+	for key, value := range msg.Headers {
+		header.Set(key, value)
+	}
+
+	if id := msg.Headers[broker.MessageIdHeader]; len(id) != 0 {
+		header.Set(nats.MsgIdHdr, id)
+	}
+
+	return &nats.Msg{
+		Subject: topic,
+		Header:  header,
+		Data:    msg.Body,
+	}
+}
+
+func unmarshal(header nats.Header, data []byte) *broker.Message {
+	headers := make(map[string]string, len(header))
+	for key := range header {
+		if key == nats.MsgIdHdr {
+			continue
+		}
+		headers[key] = header.Get(key)
+	}
+
+	return &broker.Message{
+		Headers: headers,
+		Body:    data,
+	}
+}
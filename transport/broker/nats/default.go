@@ -0,0 +1,18 @@
+package nats
+
+import (
+	"time"
+
+	"github.com/lengocson131002/go-clean-core/logger/logrus"
+	"github.com/nats-io/nats.go"
+)
+
+var (
+	DefaultNatsBroker      = nats.DefaultURL
+	DefaultLogger          = logrus.NewLogrusLogger()
+	DefaultAckWait         = time.Second * 30
+	DefaultMaxAckPending   = 100
+	DefaultInactiveTimeout = time.Minute * 5
+	DefaultShutdownTimeout = time.Second * 30
+	RequestReplyTimeout    = time.Second * 60
+)
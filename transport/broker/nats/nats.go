@@ -0,0 +1,726 @@
+package nats
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/lengocson131002/go-clean-core/logger"
+	"github.com/lengocson131002/go-clean-core/transport/broker"
+	"github.com/nats-io/nats.go"
+	"github.com/nats-io/nats.go/jetstream"
+)
+
+var (
+	ErrNotConnected = errors.New("nats broker is not connected")
+)
+
+var invalidNameReplacer = strings.NewReplacer(".", "_", "*", "_", ">", "_", " ", "_", "/", "_", "\\", "_")
+
+type nBroker struct {
+	addrs []string
+	opts  broker.BrokerOptions
+
+	mutex     sync.Mutex
+	nc        *nats.Conn
+	js        jetstream.JetStream
+	connected bool
+	subs      []*subscriber
+
+	// subject -> stream name
+	streams sync.Map
+
+	// request-reply pattern
+	replyMutex sync.Mutex
+	inbox      string
+	replySubs  map[string]*nats.Subscription
+	resps      sync.Map
+}
+
+func NewNatsBroker(opts ...broker.BrokerOption) broker.Broker {
+	options := broker.BrokerOptions{
+		Context: context.Background(),
+		Logger:  DefaultLogger,
+	}
+
+	for _, o := range opts {
+		o(&options)
+	}
+
+	n := &nBroker{
+		opts: options,
+	}
+	n.addrs = n.getAddresses()
+
+	return n
+}
+
+type subscriber struct {
+	n        *nBroker
+	t        string
+	stream   string
+	consumer string
+	durable  bool
+	opts     broker.SubscribeOptions
+	cc       jetstream.ConsumeContext
+	// core NATS subscription of the RequestReply subscribers, they have no stream nor consumer
+	ns *nats.Subscription
+
+	mutex    sync.Mutex
+	closed   bool
+	inflight sync.WaitGroup
+
+	closeOnce sync.Once
+	closeErr  error
+}
+
+type publication struct {
+	t     string
+	err   error
+	msg   jetstream.Msg
+	m     *broker.Message
+	mutex sync.Mutex
+	acked bool
+}
+
+func (p *publication) Topic() string {
+	return p.t
+}
+
+func (p *publication) Message() *broker.Message {
+	return p.m
+}
+
+// Ack implements broker.Event. A message is acknowledged once, further calls are ignored.
+// Requests received on core NATS are not acknowledged.
+func (p *publication) Ack() error {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	if p.acked || p.msg == nil {
+		return nil
+	}
+	p.acked = true
+	return p.msg.Ack()
+}
+
+// Nack asks the server to redeliver the message
+func (p *publication) Nack() error {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	if p.acked || p.msg == nil {
+		return nil
+	}
+	p.acked = true
+	return p.msg.Nak()
+}
+
+func (p *publication) Error() error {
+	return p.err
+}
+
+func (s *subscriber) Options() broker.SubscribeOptions {
+	return s.opts
+}
+
+func (s *subscriber) Topic() string {
+	return s.t
+}
+
+// Unsubscribe stops the consumer and waits for in-flight handlers.
+// Consumers without group are deleted, durable consumers of a group are kept.
+func (s *subscriber) Unsubscribe() error {
+	n := s.n
+	n.mutex.Lock()
+	for i, sub := range n.subs {
+		if sub == s {
+			n.subs = append(n.subs[:i], n.subs[i+1:]...)
+			break
+		}
+	}
+	n.mutex.Unlock()
+
+	return s.close()
+}
+
+func (s *subscriber) close() error {
+	s.closeOnce.Do(func() {
+		s.closeErr = s.drain()
+	})
+	return s.closeErr
+}
+
+// begin registers an in-flight message, it returns false when the subscriber is draining
+func (s *subscriber) begin() bool {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if s.closed {
+		return false
+	}
+	s.inflight.Add(1)
+	return true
+}
+
+func (s *subscriber) drain() error {
+	s.mutex.Lock()
+	s.closed = true
+	s.mutex.Unlock()
+
+	if s.ns != nil {
+		if err := s.ns.Unsubscribe(); err != nil && !errors.Is(err, nats.ErrConnectionClosed) {
+			s.n.getLogger().Errorf(s.n.opts.Context, "failed to unsubscribe topic %s: %s", s.t, err)
+		}
+	} else {
+		s.cc.Stop()
+	}
+
+	timeout := s.n.getShutdownTimeout()
+	done := make(chan struct{})
+	go func() {
+		s.inflight.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(timeout):
+		return broker.DrainTimeoutError{Timeout: timeout}
+	}
+
+	if s.durable || len(s.consumer) == 0 {
+		return nil
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	if err := s.n.js.DeleteConsumer(ctx, s.stream, s.consumer); err != nil && !errors.Is(err, jetstream.ErrConsumerNotFound) {
+		return err
+	}
+	return nil
+}
+
+func (n *nBroker) Address() string {
+	if len(n.addrs) > 0 {
+		return n.addrs[0]
+	}
+	return DefaultNatsBroker
+}
+
+func (n *nBroker) Connect() error {
+	n.mutex.Lock()
+	defer n.mutex.Unlock()
+
+	if n.connected {
+		return nil
+	}
+
+	nc, err := nats.Connect(strings.Join(n.addrs, ","), n.getNatsOptions()...)
+	if err != nil {
+		return err
+	}
+
+	js, err := jetstream.New(nc)
+	if err != nil {
+		nc.Close()
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(n.opts.Context, nats.GetDefaultOptions().Timeout)
+	defer cancel()
+
+	for _, cfg := range n.getStreams() {
+		if _, err := js.CreateOrUpdateStream(ctx, cfg); err != nil {
+			nc.Close()
+			return fmt.Errorf("failed to create stream %s: %w", cfg.Name, err)
+		}
+	}
+
+	n.nc = nc
+	n.js = js
+	n.streams = sync.Map{}
+	n.subs = make([]*subscriber, 0)
+	n.connected = true
+
+	return nil
+}
+
+func (n *nBroker) Disconnect() error {
+	n.mutex.Lock()
+	if !n.connected {
+		n.mutex.Unlock()
+		return nil
+	}
+	subs := n.subs
+	n.subs = nil
+	n.mutex.Unlock()
+
+	// drain subscribers before closing the connection, handlers may still publish
+	var wg sync.WaitGroup
+	for _, sub := range subs {
+		wg.Add(1)
+		go func(sub *subscriber) {
+			defer wg.Done()
+			if err := sub.close(); err != nil {
+				n.getLogger().Errorf(n.opts.Context, "failed to close subscriber of topic %s: %s", sub.t, err)
+			}
+		}(sub)
+	}
+	wg.Wait()
+
+	n.replyMutex.Lock()
+	for _, sub := range n.replySubs {
+		sub.Unsubscribe()
+	}
+	n.replySubs = nil
+	n.inbox = ""
+	n.replyMutex.Unlock()
+
+	n.mutex.Lock()
+	defer n.mutex.Unlock()
+	n.connected = false
+	err := n.nc.Flush()
+	n.nc.Close()
+	return err
+}
+
+func (n *nBroker) Init(opts ...broker.BrokerOption) error {
+	for _, o := range opts {
+		o(&n.opts)
+	}
+	n.addrs = n.getAddresses()
+	return nil
+}
+
+func (n *nBroker) Options() broker.BrokerOptions {
+	return n.opts
+}
+
+func (n *nBroker) Publish(ctx context.Context, topic string, msg *broker.Message, opts ...broker.PublishOption) error {
+	return n.getPublishFunc()(ctx, topic, msg, opts...)
+}
+
+// publish is the innermost PublishFunc wrapped by the publish middlewares.
+// Subjects captured by a stream are published to JetStream and wait for the ack,
+// others, such as reply inboxes, are published to core NATS.
+func (n *nBroker) publish(ctx context.Context, topic string, msg *broker.Message, opts ...broker.PublishOption) error {
+	options := broker.PublishOptions{}
+	for _, opt := range opts {
+		opt(&options)
+	}
+
+	if options.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, options.Timeout)
+		defer cancel()
+	}
+
+	n.mutex.Lock()
+	nc, js, connected := n.nc, n.js, n.connected
+	n.mutex.Unlock()
+	if !connected {
+		return ErrNotConnected
+	}
+
+	broker.PopulateEnvelope(ctx, msg)
+	m := marshal(topic, msg)
+
+	// requests bypass JetStream, they are received by the RequestReply subscribers
+	if request, _ := ctx.Value(requestKey{}).(bool); request {
+		m.Reply = msg.Headers[ReplyToHeader]
+		return nc.PublishMsg(m)
+	}
+
+	stream, err := n.lookupStream(ctx, js, topic)
+	if err != nil {
+		return err
+	}
+
+	if len(stream) == 0 {
+		m.Reply = msg.Headers[ReplyToHeader]
+		return nc.PublishMsg(m)
+	}
+
+	_, err = js.PublishMsg(ctx, m)
+	return err
+}
+
+func (n *nBroker) getPublishFunc() broker.PublishFunc {
+	return broker.ChainPublishMiddlewares(n.publish, n.opts.PublishMiddlewares...)
+}
+
+// lookupStream returns the stream capturing the subject. Found streams are cached per subject,
+// subjects without stream are looked up again since the stream may be created by another service.
+func (n *nBroker) lookupStream(ctx context.Context, js jetstream.JetStream, subject string) (string, error) {
+	if strings.HasPrefix(subject, nats.InboxPrefix) {
+		return "", nil
+	}
+
+	if stream, ok := n.streams.Load(subject); ok {
+		return stream.(string), nil
+	}
+
+	stream, err := js.StreamNameBySubject(ctx, subject)
+	if err != nil {
+		if errors.Is(err, jetstream.ErrStreamNotFound) {
+			return "", nil
+		}
+		return "", err
+	}
+
+	n.streams.Store(subject, stream)
+	return stream, nil
+}
+
+// ensureStream returns the stream capturing the topic, a stream named after the topic is created when there is none
+func (n *nBroker) ensureStream(ctx context.Context, js jetstream.JetStream, topic string) (string, error) {
+	stream, err := n.lookupStream(ctx, js, topic)
+	if err != nil || len(stream) != 0 {
+		return stream, err
+	}
+
+	s, err := js.CreateOrUpdateStream(ctx, jetstream.StreamConfig{
+		Name:     invalidNameReplacer.Replace(topic),
+		Subjects: []string{topic},
+	})
+	if err != nil {
+		return "", fmt.Errorf("failed to create stream for topic %s: %w", topic, err)
+	}
+
+	stream = s.CachedInfo().Config.Name
+	n.streams.Store(topic, stream)
+	return stream, nil
+}
+
+// requestKey marks the publishes of PublishAndReceive in the context given to the publish middlewares
+type requestKey struct{}
+
+// PublishAndReceive publishes the request on core NATS with the reply subject in the ReplyToHeader header and
+// waits for the reply on a core NATS subscription. The responders subscribe the topic with RequestReply and
+// publish the reply to that subject with the same CorrelationIdHeader header.
+func (n *nBroker) PublishAndReceive(ctx context.Context, topic string, msg *broker.Message, opts ...broker.PublishOption) (*broker.Message, error) {
+	options := broker.PublishOptions{
+		Timeout: RequestReplyTimeout,
+	}
+
+	for _, opt := range opts {
+		opt(&options)
+	}
+
+	if msg.Headers == nil {
+		msg.Headers = make(map[string]string)
+	}
+
+	correlationId, ok := msg.Headers[CorrelationIdHeader]
+	if !ok || len(correlationId) == 0 {
+		correlationId = uuid.New().String()
+		msg.Headers[CorrelationIdHeader] = correlationId
+	}
+
+	replyTo, err := n.ensureReplySubscription(options.ReplyToTopic, correlationId)
+	if err != nil {
+		return nil, err
+	}
+	msg.Headers[ReplyToHeader] = replyTo
+
+	msgChan := make(chan *broker.Message, 1)
+	n.resps.Store(correlationId, msgChan)
+	defer n.resps.Delete(correlationId)
+
+	if err := n.getPublishFunc()(context.WithValue(ctx, requestKey{}, true), topic, msg, opts...); err != nil {
+		return nil, err
+	}
+
+	select {
+	case reply := <-msgChan:
+		return reply, nil
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	case <-time.After(options.Timeout):
+		return nil, broker.RequestTimeoutResponse{
+			Timeout: options.Timeout,
+		}
+	}
+}
+
+// ensureReplySubscription subscribes to the inbox of this instance, or to the shared reply subject,
+// and returns the subject the reply is expected on
+func (n *nBroker) ensureReplySubscription(replyTo string, correlationId string) (string, error) {
+	n.replyMutex.Lock()
+	defer n.replyMutex.Unlock()
+
+	n.mutex.Lock()
+	nc, connected := n.nc, n.connected
+	n.mutex.Unlock()
+	if !connected {
+		return "", ErrNotConnected
+	}
+
+	if len(n.inbox) == 0 {
+		n.inbox = nc.NewRespInbox()
+		n.replySubs = make(map[string]*nats.Subscription)
+	}
+
+	subject := replyTo
+	if len(subject) == 0 {
+		subject = n.inbox + ".*"
+		replyTo = n.inbox + "." + correlationId
+	}
+
+	if _, ok := n.replySubs[subject]; ok {
+		return replyTo, nil
+	}
+
+	// shared reply subjects are received by every instance and filtered by correlation id
+	sub, err := nc.Subscribe(subject, func(m *nats.Msg) {
+		reply := unmarshal(m.Header, m.Data)
+		if msgChan, ok := n.resps.LoadAndDelete(reply.Headers[CorrelationIdHeader]); ok {
+			msgChan.(chan *broker.Message) <- reply
+		}
+	})
+	if err != nil {
+		return "", fmt.Errorf("failed to subscribe reply subject %s: %w", subject, err)
+	}
+
+	n.replySubs[subject] = sub
+	return replyTo, nil
+}
+
+// Subscribe consumes the topic with a JetStream pull consumer. Subscribers of the same group share
+// a durable consumer, so each message is handled by one of them; without group an ephemeral consumer is used.
+// With RequestReply, the topic is subscribed on core NATS instead.
+func (n *nBroker) Subscribe(topic string, handler broker.Handler, opts ...broker.SubscribeOption) (broker.Subscriber, error) {
+	opt := broker.SubscribeOptions{
+		AutoAck: true,
+	}
+
+	for _, o := range opts {
+		o(&opt)
+	}
+
+	n.mutex.Lock()
+	nc, js, connected := n.nc, n.js, n.connected
+	n.mutex.Unlock()
+	if !connected {
+		return nil, ErrNotConnected
+	}
+
+	if n.isRequestReply(opt) {
+		return n.subscribeRequests(nc, topic, handler, opt)
+	}
+
+	ctx, cancel := context.WithTimeout(n.opts.Context, nats.GetDefaultOptions().Timeout)
+	defer cancel()
+
+	stream, err := n.ensureStream(ctx, js, topic)
+	if err != nil {
+		return nil, err
+	}
+
+	cfg := n.getConsumerConfig(topic, opt)
+	cons, err := js.CreateOrUpdateConsumer(ctx, stream, cfg)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create consumer of topic %s: %w", topic, err)
+	}
+
+	sub := &subscriber{
+		n:        n,
+		t:        topic,
+		stream:   stream,
+		consumer: cons.CachedInfo().Name,
+		durable:  len(cfg.Durable) != 0,
+		opts:     opt,
+	}
+
+	h := broker.ChainSubscriptionHandler(handler, opt, n.opts.SubscribeMiddlewares...)
+
+	sub.cc, err = cons.Consume(func(msg jetstream.Msg) {
+		// stop taking messages once draining started, the message will be redelivered
+		if !sub.begin() {
+			msg.Nak()
+			return
+		}
+		defer sub.inflight.Done()
+		n.handle(h, &publication{t: topic, msg: msg, m: unmarshal(msg.Headers(), msg.Data())}, opt)
+	}, jetstream.PullMaxMessages(cfg.MaxAckPending), jetstream.ConsumeErrHandler(func(cc jetstream.ConsumeContext, err error) {
+		n.getLogger().Errorf(n.opts.Context, "[nats consumer] %s: %v", topic, err)
+	}))
+	if err != nil {
+		return nil, err
+	}
+
+	n.mutex.Lock()
+	n.subs = append(n.subs, sub)
+	n.mutex.Unlock()
+
+	n.getLogger().Infof(n.opts.Context, "Subcribed to topic: %s. Stream: %s. Consumer: %s", topic, stream, sub.consumer)
+
+	return sub, nil
+}
+
+// subscribeRequests consumes the requests of PublishAndReceive with a core NATS subscription, subscribers
+// of the same group share a queue group. Requests are not persisted, nor redelivered when the handler fails.
+func (n *nBroker) subscribeRequests(nc *nats.Conn, topic string, handler broker.Handler, opt broker.SubscribeOptions) (broker.Subscriber, error) {
+	sub := &subscriber{
+		n:    n,
+		t:    topic,
+		opts: opt,
+	}
+
+	h := broker.ChainSubscriptionHandler(handler, opt, n.opts.SubscribeMiddlewares...)
+	cb := func(msg *nats.Msg) {
+		if !sub.begin() {
+			return
+		}
+		defer sub.inflight.Done()
+		n.handle(h, &publication{t: topic, m: unmarshal(msg.Header, msg.Data)}, opt)
+	}
+
+	var err error
+	if len(opt.Group) != 0 {
+		sub.ns, err = nc.QueueSubscribe(topic, opt.Group, cb)
+	} else {
+		sub.ns, err = nc.Subscribe(topic, cb)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to subscribe request topic %s: %w", topic, err)
+	}
+
+	n.mutex.Lock()
+	n.subs = append(n.subs, sub)
+	n.mutex.Unlock()
+
+	n.getLogger().Infof(n.opts.Context, "Subcribed to request topic: %s", topic)
+
+	return sub, nil
+}
+
+func (n *nBroker) handle(h broker.Handler, p *publication, opt broker.SubscribeOptions) {
+	ctx := broker.ContextWithEnvelope(context.Background(), p.m)
+
+	err := h(ctx, p)
+	if err == nil {
+		if opt.AutoAck {
+			if err := p.Ack(); err != nil {
+				n.getLogger().Errorf(ctx, "[nats] failed to ack message: %v", err)
+			}
+		}
+		return
+	}
+
+	p.err = err
+	if nakErr := p.Nack(); nakErr != nil {
+		n.getLogger().Errorf(ctx, "[nats] failed to nak message: %v", nakErr)
+	}
+
+	if errHandler := n.opts.ErrorHandler; errHandler != nil {
+		errHandler(ctx, p)
+	} else {
+		n.getLogger().Errorf(ctx, "[nats] subscriber error: %v", err)
+	}
+}
+
+func (n *nBroker) isRequestReply(opt broker.SubscribeOptions) bool {
+	if opt.Context != nil {
+		if requestReply, ok := opt.Context.Value(requestReplyKey{}).(bool); ok {
+			return requestReply
+		}
+	}
+	return false
+}
+
+func (n *nBroker) getConsumerConfig(topic string, opt broker.SubscribeOptions) jetstream.ConsumerConfig {
+	cfg := jetstream.ConsumerConfig{}
+	if opt.Context != nil {
+		if c, ok := opt.Context.Value(consumerConfigKey{}).(jetstream.ConsumerConfig); ok {
+			cfg = c
+		}
+		if wait, ok := opt.Context.Value(ackWaitKey{}).(time.Duration); ok {
+			cfg.AckWait = wait
+		}
+		if max, ok := opt.Context.Value(maxDeliverKey{}).(int); ok {
+			cfg.MaxDeliver = max
+		}
+		if max, ok := opt.Context.Value(maxAckPendingKey{}).(int); ok {
+			cfg.MaxAckPending = max
+		}
+		if policy, ok := opt.Context.Value(deliverPolicyKey{}).(jetstream.DeliverPolicy); ok {
+			cfg.DeliverPolicy = policy
+		}
+	}
+
+	cfg.FilterSubject = topic
+	cfg.AckPolicy = jetstream.AckExplicitPolicy
+	if cfg.AckWait <= 0 {
+		cfg.AckWait = DefaultAckWait
+	}
+	if cfg.MaxAckPending <= 0 {
+		cfg.MaxAckPending = DefaultMaxAckPending
+	}
+
+	if len(opt.Group) != 0 {
+		cfg.Durable = invalidNameReplacer.Replace(fmt.Sprintf("%s-%s", opt.Group, topic))
+	} else {
+		cfg.Durable = ""
+		cfg.Name = ""
+		if cfg.InactiveThreshold <= 0 {
+			cfg.InactiveThreshold = DefaultInactiveTimeout
+		}
+	}
+
+	return cfg
+}
+
+func (n *nBroker) getAddresses() []string {
+	var addrs []string
+	for _, addr := range n.opts.Addrs {
+		if len(addr) == 0 {
+			continue
+		}
+		addrs = append(addrs, addr)
+	}
+	if len(addrs) == 0 {
+		addrs = []string{DefaultNatsBroker}
+	}
+	return addrs
+}
+
+func (n *nBroker) getNatsOptions() []nats.Option {
+	var opts []nats.Option
+	if n.opts.TLSConfig != nil {
+		opts = append(opts, nats.Secure(n.opts.TLSConfig))
+	}
+	if o, ok := n.opts.Context.Value(natsOptionsKey{}).([]nats.Option); ok {
+		opts = append(opts, o...)
+	}
+	return opts
+}
+
+func (n *nBroker) getStreams() []jetstream.StreamConfig {
+	if s, ok := n.opts.Context.Value(streamsKey{}).([]jetstream.StreamConfig); ok {
+		return s
+	}
+	return nil
+}
+
+func (n *nBroker) getShutdownTimeout() time.Duration {
+	if t, ok := n.opts.Context.Value(shutdownTimeoutKey{}).(time.Duration); ok && t > 0 {
+		return t
+	}
+	return DefaultShutdownTimeout
+}
+
+func (n *nBroker) getLogger() logger.Logger {
+	logger := n.opts.Logger
+	if logger == nil {
+		logger = DefaultLogger
+	}
+	return logger
+}
+
+func (n *nBroker) String() string {
+	return "nats broker implementation"
+}
@@ -0,0 +1,172 @@
+package nats
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/lengocson131002/go-clean-core/transport/broker"
+	"github.com/nats-io/nats-server/v2/server"
+	"github.com/nats-io/nats.go/jetstream"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func runServer(t *testing.T) *server.Server {
+	s, err := server.NewServer(&server.Options{
+		Host:      "127.0.0.1",
+		Port:      server.RANDOM_PORT,
+		JetStream: true,
+		StoreDir:  t.TempDir(),
+		NoLog:     true,
+		NoSigs:    true,
+	})
+	require.NoError(t, err)
+
+	go s.Start()
+	if !s.ReadyForConnections(5 * time.Second) {
+		t.Fatal("nats server is not ready")
+	}
+	t.Cleanup(s.Shutdown)
+	return s
+}
+
+func getNatsBroker(t *testing.T) broker.Broker {
+	s := runServer(t)
+
+	br := NewNatsBroker(broker.WithBrokerAddresses(s.ClientURL()))
+	require.NoError(t, br.Connect())
+	t.Cleanup(func() {
+		br.Disconnect()
+	})
+	return br
+}
+
+func TestPublishSubscribe(t *testing.T) {
+	br := getNatsBroker(t)
+
+	received := make(chan *broker.Message, 1)
+	_, err := br.Subscribe("go.clean.test.nats", func(ctx context.Context, e broker.Event) error {
+		received <- e.Message()
+		return nil
+	})
+	require.NoError(t, err)
+
+	err = br.Publish(context.Background(), "go.clean.test.nats", &broker.Message{
+		Headers: map[string]string{"key": "value"},
+		Body:    []byte("hello"),
+	})
+	require.NoError(t, err)
+
+	select {
+	case msg := <-received:
+		assert.Equal(t, "hello", string(msg.Body))
+		assert.Equal(t, "value", msg.Headers["key"])
+		assert.NotEmpty(t, msg.Headers[CorrelationIdHeader])
+	case <-time.After(5 * time.Second):
+		t.Fatal("message was not received")
+	}
+}
+
+func TestSubscribeGroupSharesConsumer(t *testing.T) {
+	br := getNatsBroker(t)
+
+	var (
+		first, second atomic.Int32
+		wg            sync.WaitGroup
+		total         = 20
+	)
+	wg.Add(total)
+
+	for _, counter := range []*atomic.Int32{&first, &second} {
+		counter := counter
+		_, err := br.Subscribe("go.clean.test.nats.group", func(ctx context.Context, e broker.Event) error {
+			counter.Add(1)
+			wg.Done()
+			time.Sleep(5 * time.Millisecond)
+			return nil
+		}, broker.WithSubscribeGroup("workers"), MaxAckPending(1))
+		require.NoError(t, err)
+	}
+
+	for i := 0; i < total; i++ {
+		require.NoError(t, br.Publish(context.Background(), "go.clean.test.nats.group", &broker.Message{Body: []byte("job")}))
+	}
+
+	wg.Wait()
+	assert.Equal(t, int32(total), first.Load()+second.Load())
+	assert.NotZero(t, first.Load())
+	assert.NotZero(t, second.Load())
+}
+
+func TestPublishAndReceive(t *testing.T) {
+	br := getNatsBroker(t)
+
+	_, err := br.Subscribe("go.clean.test.nats.request", func(ctx context.Context, e broker.Event) error {
+		msg := e.Message()
+		return br.Publish(ctx, msg.Headers[ReplyToHeader], &broker.Message{
+			Headers: map[string]string{CorrelationIdHeader: msg.Headers[CorrelationIdHeader]},
+			Body:    append([]byte("re: "), msg.Body...),
+		})
+	}, broker.WithSubscribeGroup("responders"), RequestReply())
+	require.NoError(t, err)
+
+	reply, err := br.PublishAndReceive(context.Background(), "go.clean.test.nats.request", &broker.Message{
+		Body: []byte("ping"),
+	}, broker.WithPublishTimeout(5*time.Second))
+	require.NoError(t, err)
+	assert.Equal(t, "re: ping", string(reply.Body))
+
+	// requests do not go through JetStream
+	_, err = br.(*nBroker).js.StreamNameBySubject(context.Background(), "go.clean.test.nats.request")
+	assert.ErrorIs(t, err, jetstream.ErrStreamNotFound)
+}
+
+func TestHandlerErrorRedeliversMessage(t *testing.T) {
+	br := getNatsBroker(t)
+
+	var attempts atomic.Int32
+	done := make(chan struct{})
+	_, err := br.Subscribe("go.clean.test.nats.error", func(ctx context.Context, e broker.Event) error {
+		if attempts.Add(1) == 1 {
+			return errors.New("failed")
+		}
+		close(done)
+		return nil
+	}, broker.WithSubscribeGroup("retry"))
+	require.NoError(t, err)
+
+	require.NoError(t, br.Publish(context.Background(), "go.clean.test.nats.error", &broker.Message{Body: []byte("x")}))
+
+	select {
+	case <-done:
+		assert.Equal(t, int32(2), attempts.Load())
+	case <-time.After(5 * time.Second):
+		t.Fatal("message was not redelivered")
+	}
+}
+
+func TestUnsubscribeWaitsForInflightHandler(t *testing.T) {
+	br := getNatsBroker(t)
+
+	var (
+		started  = make(chan struct{})
+		finished atomic.Bool
+	)
+	sub, err := br.Subscribe("go.clean.test.nats.drain", func(ctx context.Context, e broker.Event) error {
+		close(started)
+		time.Sleep(50 * time.Millisecond)
+		finished.Store(true)
+		return nil
+	})
+	require.NoError(t, err)
+
+	require.NoError(t, br.Publish(context.Background(), "go.clean.test.nats.drain", &broker.Message{Body: []byte("x")}))
+	<-started
+
+	require.NoError(t, sub.Unsubscribe())
+	assert.True(t, finished.Load())
+}
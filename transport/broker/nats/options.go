@@ -0,0 +1,75 @@
+package nats
+
+import (
+	"time"
+
+	"github.com/lengocson131002/go-clean-core/transport/broker"
+	"github.com/nats-io/nats.go"
+	"github.com/nats-io/nats.go/jetstream"
+)
+
+type natsOptionsKey struct{}
+
+// NatsOptions are passed to the connection, e.g. credentials, reconnect or ping settings
+func NatsOptions(opts ...nats.Option) broker.BrokerOption {
+	return setBrokerOption(natsOptionsKey{}, opts)
+}
+
+type streamsKey struct{}
+
+// Streams are created or updated on connect. Topics without stream capturing
+// them get a stream named after the topic on subscribe, unless subscribed with RequestReply.
+func Streams(streams ...jetstream.StreamConfig) broker.BrokerOption {
+	return setBrokerOption(streamsKey{}, streams)
+}
+
+type shutdownTimeoutKey struct{}
+
+// ShutdownTimeout is the maximum time Unsubscribe and Disconnect wait for in-flight handlers
+func ShutdownTimeout(timeout time.Duration) broker.BrokerOption {
+	return setBrokerOption(shutdownTimeoutKey{}, timeout)
+}
+
+type requestReplyKey struct{}
+
+// RequestReply subscribes the topic on core NATS for the requests sent by PublishAndReceive.
+// No stream is created for the topic, requests are not persisted nor redelivered.
+func RequestReply() broker.SubscribeOption {
+	return setSubscribeOption(requestReplyKey{}, true)
+}
+
+type consumerConfigKey struct{}
+
+// ConsumerConfig is the base configuration of the JetStream consumer of a subscription.
+// Durable name, filter subject and ack policy are set by the broker.
+func ConsumerConfig(cfg jetstream.ConsumerConfig) broker.SubscribeOption {
+	return setSubscribeOption(consumerConfigKey{}, cfg)
+}
+
+type ackWaitKey struct{}
+
+// AckWait is the time the server waits for an ack before redelivering the message
+func AckWait(wait time.Duration) broker.SubscribeOption {
+	return setSubscribeOption(ackWaitKey{}, wait)
+}
+
+type maxDeliverKey struct{}
+
+// MaxDeliver is the maximum number of deliveries of a message, unlimited by default
+func MaxDeliver(max int) broker.SubscribeOption {
+	return setSubscribeOption(maxDeliverKey{}, max)
+}
+
+type maxAckPendingKey struct{}
+
+// MaxAckPending is the number of unacknowledged messages delivered to the subscription
+func MaxAckPending(max int) broker.SubscribeOption {
+	return setSubscribeOption(maxAckPendingKey{}, max)
+}
+
+type deliverPolicyKey struct{}
+
+// DeliverPolicy sets where a new consumer starts in the stream, all messages by default
+func DeliverPolicy(policy jetstream.DeliverPolicy) broker.SubscribeOption {
+	return setSubscribeOption(deliverPolicyKey{}, policy)
+}
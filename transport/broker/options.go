@@ -23,6 +23,12 @@ type BrokerOptions struct {
 	Addrs []string
 
 	TLSConfig *tls.Config
+
+	// Middlewares wrapping every Publish call
+	PublishMiddlewares []PublishMiddleware
+
+	// Middlewares wrapping every subscription handler
+	SubscribeMiddlewares []SubscribeMiddleware
 }
 
 func WithBrokerContext(ctx context.Context) BrokerOption {
@@ -55,6 +61,18 @@ func WithBrokerTLSConfig(t *tls.Config) BrokerOption {
 	}
 }
 
+func WithPublishMiddlewares(mws ...PublishMiddleware) BrokerOption {
+	return func(opts *BrokerOptions) {
+		opts.PublishMiddlewares = append(opts.PublishMiddlewares, mws...)
+	}
+}
+
+func WithSubscribeMiddlewares(mws ...SubscribeMiddleware) BrokerOption {
+	return func(opts *BrokerOptions) {
+		opts.SubscribeMiddlewares = append(opts.SubscribeMiddlewares, mws...)
+	}
+}
+
 type PublishOption func(*PublishOptions)
 
 type PublishOptions struct {
@@ -62,6 +80,12 @@ type PublishOptions struct {
 	Timeout            time.Duration
 	ReplyToTopic       string
 	ReplyConsumerGroup string
+
+	// DeliverAt delays the delivery of the message until the given time
+	DeliverAt time.Time
+
+	// Priority of the message, PriorityNormal by default
+	Priority Priority
 }
 
 func WithPublishContext(ctx context.Context) PublishOption {
@@ -88,6 +112,27 @@ func WithReplyConsumerGroup(cg string) PublishOption {
 	}
 }
 
+// WithPublishDelay delivers the message after the delay
+func WithPublishDelay(delay time.Duration) PublishOption {
+	return func(opts *PublishOptions) {
+		opts.DeliverAt = time.Now().Add(delay)
+	}
+}
+
+// WithPublishAt delivers the message at the given time
+func WithPublishAt(t time.Time) PublishOption {
+	return func(opts *PublishOptions) {
+		opts.DeliverAt = t
+	}
+}
+
+// WithPublishPriority delivers the message before the messages of lower priorities
+func WithPublishPriority(p Priority) PublishOption {
+	return func(opts *PublishOptions) {
+		opts.Priority = p
+	}
+}
+
 type SubscribeOption func(*SubscribeOptions)
 
 type SubscribeOptions struct {
@@ -98,6 +143,10 @@ type SubscribeOptions struct {
 	// AutoAck defaults to true. When a handler returns
 	// with a nil error the message is acked.
 	AutoAck bool
+
+	// Middlewares wrapping the handler of this subscription only,
+	// they run inside the middlewares of the broker
+	Middlewares []SubscribeMiddleware
 }
 
 func WithSubscribeContext(ctx context.Context) SubscribeOption {
@@ -117,3 +166,9 @@ func WithSubscribeAutoAck(autoAck bool) SubscribeOption {
 		opts.AutoAck = autoAck
 	}
 }
+
+func WithSubscriptionMiddlewares(mws ...SubscribeMiddleware) SubscribeOption {
+	return func(opts *SubscribeOptions) {
+		opts.Middlewares = append(opts.Middlewares, mws...)
+	}
+}
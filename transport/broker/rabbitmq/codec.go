@@ -0,0 +1,81 @@
+package rabbitmq
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/lengocson131002/go-clean-core/transport/broker"
+	amqp "github.com/rabbitmq/amqp091-go"
+)
+
+const (
+	CorrelationIdHeader = broker.CorrelationIdHeader
+	ReplyToHeader       = broker.ReplyToHeader
+)
+
+// marshal maps the message to an AMQP publishing, correlation id, reply to, message id,
+// type and content type headers are also set as AMQP properties
+func marshal(msg *broker.Message) amqp.Publishing {
+	if len(msg.Headers) == 0 {
+		msg.Headers = make(map[string]string)
+	}
+
+	correlationId, ok := msg.Headers[CorrelationIdHeader]
+	if !ok || len(correlationId) == 0 {
+		correlationId = uuid.New().String()
+		msg.Headers[CorrelationIdHeader] = correlationId
+	}
+
+	headers := make(amqp.Table, len(msg.Headers))
+	for key, value := range msg.Headers {
+		headers[key] = value
+	}
+
+	return amqp.Publishing{
+		Headers:       headers,
+		DeliveryMode:  amqp.Persistent,
+		Timestamp:     time.Now(),
+		CorrelationId: correlationId,
+		ReplyTo:       msg.Headers[ReplyToHeader],
+		MessageId:     msg.Headers[broker.MessageIdHeader],
+		Type:          msg.Headers[broker.MessageTypeHeader],
+		ContentType:   msg.Headers[broker.ContentTypeHeader],
+		Body:          msg.Body,
+	}
+}
+
+func unmarshal(d amqp.Delivery) *broker.Message {
+	headers := make(map[string]string, len(d.Headers)+5)
+	for key, value := range d.Headers {
+		switch v := value.(type) {
+		case string:
+			headers[key] = v
+		case []byte:
+			headers[key] = string(v)
+		default:
+			headers[key] = fmt.Sprint(v)
+		}
+	}
+
+	if len(d.CorrelationId) != 0 {
+		headers[CorrelationIdHeader] = d.CorrelationId
+	}
+	if len(d.ReplyTo) != 0 {
+		headers[ReplyToHeader] = d.ReplyTo
+	}
+	if len(d.MessageId) != 0 {
+		headers[broker.MessageIdHeader] = d.MessageId
+	}
+	if len(d.Type) != 0 {
+		headers[broker.MessageTypeHeader] = d.Type
+	}
+	if len(d.ContentType) != 0 {
+		headers[broker.ContentTypeHeader] = d.ContentType
+	}
+
+	return &broker.Message{
+		Headers: headers,
+		Body:    d.Body,
+	}
+}
@@ -0,0 +1,299 @@
+package rabbitmq
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+
+	amqp "github.com/rabbitmq/amqp091-go"
+)
+
+// fakeServer is an in-process AMQP stand-in supporting exchanges with exact routing keys,
+// shared queues with round-robin consumers, publisher confirms, acks/nacks and dropped connections.
+type fakeServer struct {
+	mutex    sync.Mutex
+	queues   map[string]*fakeQueue
+	bindings map[string][]string // exchange/key -> queues
+	conns    []*fakeConnection
+	seq      int
+	nacked   int
+}
+
+type fakeQueue struct {
+	name      string
+	consumers []*fakeConsumer
+	next      int
+	pending   []amqp.Delivery
+	tag       uint64
+}
+
+type fakeConsumer struct {
+	tag      string
+	channel  *fakeChannel
+	autoAck  bool
+	messages chan amqp.Delivery
+}
+
+func newFakeServer() *fakeServer {
+	return &fakeServer{
+		queues:   make(map[string]*fakeQueue),
+		bindings: make(map[string][]string),
+	}
+}
+
+func (s *fakeServer) dial(url string, config amqp.Config) (amqpConnection, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	conn := &fakeConnection{server: s}
+	s.conns = append(s.conns, conn)
+	return conn, nil
+}
+
+// drop closes the open connections as a network failure would
+func (s *fakeServer) drop() {
+	s.mutex.Lock()
+	conns := s.conns
+	s.conns = nil
+	for _, q := range s.queues {
+		for _, cons := range q.consumers {
+			close(cons.messages)
+		}
+		q.consumers = nil
+	}
+	s.mutex.Unlock()
+
+	for _, conn := range conns {
+		conn.shutdown(&amqp.Error{Code: amqp.ConnectionForced, Reason: "CONNECTION_FORCED"})
+	}
+}
+
+func (s *fakeServer) route(exchange, key string, msg amqp.Publishing) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	for _, name := range s.bindings[exchange+"/"+key] {
+		q := s.queues[name]
+		q.tag++
+		d := amqp.Delivery{
+			Acknowledger:  &fakeAcknowledger{server: s, queue: q},
+			Headers:       msg.Headers,
+			CorrelationId: msg.CorrelationId,
+			ReplyTo:       msg.ReplyTo,
+			MessageId:     msg.MessageId,
+			Timestamp:     msg.Timestamp,
+			DeliveryTag:   q.tag,
+			Exchange:      exchange,
+			RoutingKey:    key,
+			Body:          msg.Body,
+		}
+		s.deliver(q, d)
+	}
+}
+
+// deliver must be called with the server lock held
+func (s *fakeServer) deliver(q *fakeQueue, d amqp.Delivery) {
+	if len(q.consumers) == 0 {
+		q.pending = append(q.pending, d)
+		return
+	}
+	c := q.consumers[q.next%len(q.consumers)]
+	q.next++
+	c.messages <- d
+}
+
+type fakeAcknowledger struct {
+	server *fakeServer
+	queue  *fakeQueue
+}
+
+func (a *fakeAcknowledger) Ack(tag uint64, multiple bool) error {
+	return nil
+}
+
+func (a *fakeAcknowledger) Nack(tag uint64, multiple bool, requeue bool) error {
+	a.server.mutex.Lock()
+	defer a.server.mutex.Unlock()
+	a.server.nacked++
+	return nil
+}
+
+func (a *fakeAcknowledger) Reject(tag uint64, requeue bool) error {
+	return a.Nack(tag, false, requeue)
+}
+
+type fakeConnection struct {
+	server   *fakeServer
+	mutex    sync.Mutex
+	notify   []chan *amqp.Error
+	channels []*fakeChannel
+	closed   bool
+}
+
+func (c *fakeConnection) Channel() (amqpChannel, error) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	if c.closed {
+		return nil, amqp.ErrClosed
+	}
+	ch := &fakeChannel{server: c.server}
+	c.channels = append(c.channels, ch)
+	return ch, nil
+}
+
+func (c *fakeConnection) NotifyClose(receiver chan *amqp.Error) chan *amqp.Error {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	c.notify = append(c.notify, receiver)
+	return receiver
+}
+
+func (c *fakeConnection) Close() error {
+	c.shutdown(nil)
+	return nil
+}
+
+func (c *fakeConnection) shutdown(err *amqp.Error) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	if c.closed {
+		return
+	}
+	c.closed = true
+	for _, ch := range c.channels {
+		ch.Close()
+	}
+	for _, receiver := range c.notify {
+		if err != nil {
+			receiver <- err
+		}
+		close(receiver)
+	}
+}
+
+type fakeChannel struct {
+	server   *fakeServer
+	mutex    sync.Mutex
+	confirms chan amqp.Confirmation
+	tag      uint64
+	closed   bool
+}
+
+func (c *fakeChannel) ExchangeDeclare(name, kind string, durable, autoDelete, internal, noWait bool, args amqp.Table) error {
+	return nil
+}
+
+func (c *fakeChannel) QueueDeclare(name string, durable, autoDelete, exclusive, noWait bool, args amqp.Table) (amqp.Queue, error) {
+	s := c.server
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if len(name) == 0 {
+		s.seq++
+		name = fmt.Sprintf("amq.gen-%d", s.seq)
+	}
+	if _, ok := s.queues[name]; !ok {
+		s.queues[name] = &fakeQueue{name: name}
+	}
+	return amqp.Queue{Name: name}, nil
+}
+
+func (c *fakeChannel) QueueBind(name, key, exchange string, noWait bool, args amqp.Table) error {
+	s := c.server
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	routing := exchange + "/" + key
+	for _, q := range s.bindings[routing] {
+		if q == name {
+			return nil
+		}
+	}
+	s.bindings[routing] = append(s.bindings[routing], name)
+	return nil
+}
+
+func (c *fakeChannel) Qos(prefetchCount, prefetchSize int, global bool) error {
+	return nil
+}
+
+func (c *fakeChannel) Confirm(noWait bool) error {
+	return nil
+}
+
+func (c *fakeChannel) NotifyPublish(confirm chan amqp.Confirmation) chan amqp.Confirmation {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	c.confirms = confirm
+	return confirm
+}
+
+func (c *fakeChannel) PublishWithContext(ctx context.Context, exchange, key string, mandatory, immediate bool, msg amqp.Publishing) error {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	if c.closed {
+		return errors.New("channel closed")
+	}
+	c.server.route(exchange, key, msg)
+	c.tag++
+	if c.confirms != nil {
+		c.confirms <- amqp.Confirmation{DeliveryTag: c.tag, Ack: true}
+	}
+	return nil
+}
+
+func (c *fakeChannel) Consume(queue, consumer string, autoAck, exclusive, noLocal, noWait bool, args amqp.Table) (<-chan amqp.Delivery, error) {
+	s := c.server
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	q, ok := s.queues[queue]
+	if !ok {
+		return nil, fmt.Errorf("queue %s not found", queue)
+	}
+
+	cons := &fakeConsumer{tag: consumer, channel: c, autoAck: autoAck, messages: make(chan amqp.Delivery, 100)}
+	q.consumers = append(q.consumers, cons)
+
+	pending := q.pending
+	q.pending = nil
+	for _, d := range pending {
+		s.deliver(q, d)
+	}
+
+	return cons.messages, nil
+}
+
+func (c *fakeChannel) Cancel(consumer string, noWait bool) error {
+	s := c.server
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	for _, q := range s.queues {
+		for i, cons := range q.consumers {
+			if cons.tag == consumer {
+				q.consumers = append(q.consumers[:i], q.consumers[i+1:]...)
+				close(cons.messages)
+				return nil
+			}
+		}
+	}
+	return nil
+}
+
+func (c *fakeChannel) Close() error {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	if !c.closed && c.confirms != nil {
+		close(c.confirms)
+	}
+	c.closed = true
+	return nil
+}
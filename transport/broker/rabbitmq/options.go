@@ -0,0 +1,76 @@
+package rabbitmq
+
+import (
+	"time"
+
+	"github.com/lengocson131002/go-clean-core/transport/broker"
+	amqp "github.com/rabbitmq/amqp091-go"
+)
+
+type exchangeKey struct{}
+
+type exchange struct {
+	name string
+	kind string
+}
+
+// Exchange sets the exchange every topic is published to, topics are used as routing keys
+func Exchange(name string, kind string) broker.BrokerOption {
+	return setBrokerOption(exchangeKey{}, exchange{name: name, kind: kind})
+}
+
+type shutdownTimeoutKey struct{}
+
+// ShutdownTimeout is the maximum time Unsubscribe and Disconnect wait for in-flight handlers
+func ShutdownTimeout(timeout time.Duration) broker.BrokerOption {
+	return setBrokerOption(shutdownTimeoutKey{}, timeout)
+}
+
+type reconnectBackoffKey struct{}
+
+type reconnectBackoff struct {
+	backoff time.Duration
+	max     time.Duration
+}
+
+// ReconnectBackoff sets the delay before reconnecting when the connection is lost,
+// it doubles after each failed attempt up to max
+func ReconnectBackoff(backoff time.Duration, max time.Duration) broker.BrokerOption {
+	return setBrokerOption(reconnectBackoffKey{}, reconnectBackoff{backoff: backoff, max: max})
+}
+
+type amqpConfigKey struct{}
+
+// AmqpConfig sets the connection configuration, e.g. heartbeat, vhost or SASL mechanisms
+func AmqpConfig(c amqp.Config) broker.BrokerOption {
+	return setBrokerOption(amqpConfigKey{}, c)
+}
+
+type dialerKey struct{}
+
+// withDialer replaces the dialer, used to run the broker against an in-process stand-in
+func withDialer(d dialer) broker.BrokerOption {
+	return setBrokerOption(dialerKey{}, d)
+}
+
+type prefetchCountKey struct{}
+
+// PrefetchCount is the number of unacknowledged deliveries sent to the subscriber
+func PrefetchCount(count int) broker.SubscribeOption {
+	return setSubscribeOption(prefetchCountKey{}, count)
+}
+
+type requeueOnErrorKey struct{}
+
+// RequeueOnError requeues the message when the handler returns an error.
+// By default, the message is rejected and dead-lettered if the queue is configured to.
+func RequeueOnError(requeue bool) broker.SubscribeOption {
+	return setSubscribeOption(requeueOnErrorKey{}, requeue)
+}
+
+type queueArgumentsKey struct{}
+
+// QueueArguments are passed to the queue declaration, e.g. `x-dead-letter-exchange`
+func QueueArguments(args amqp.Table) broker.SubscribeOption {
+	return setSubscribeOption(queueArgumentsKey{}, args)
+}
@@ -0,0 +1,89 @@
+package rabbitmq
+
+import (
+	"context"
+	"sync"
+
+	amqp "github.com/rabbitmq/amqp091-go"
+)
+
+// publisher is a channel in confirm mode. The confirms are drained by a dedicated goroutine and routed
+// by delivery tag to the publishes waiting for them, so a publish giving up never blocks the others.
+type publisher struct {
+	ch amqpChannel
+
+	// publishes are serialized, the delivery tags follow the publish order
+	mutex sync.Mutex
+	tag   uint64
+
+	waitingMutex sync.Mutex
+	waiting      map[uint64]chan amqp.Confirmation
+	closed       bool
+}
+
+func newPublisher(ch amqpChannel) *publisher {
+	p := &publisher{
+		ch:      ch,
+		waiting: make(map[uint64]chan amqp.Confirmation),
+	}
+	go p.route(ch.NotifyPublish(make(chan amqp.Confirmation, 16)))
+	return p
+}
+
+// publish sends the message and returns the channel receiving its confirm, the channel is closed
+// without confirm when the underlying channel is closed
+func (p *publisher) publish(ctx context.Context, exchange string, key string, msg amqp.Publishing) (uint64, chan amqp.Confirmation, error) {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	var (
+		tag     = p.tag + 1
+		confirm = make(chan amqp.Confirmation, 1)
+	)
+
+	// the waiter is registered first, the confirm may be routed before the publish returns
+	p.waitingMutex.Lock()
+	if p.closed {
+		p.waitingMutex.Unlock()
+		return 0, nil, ErrNotConnected
+	}
+	p.waiting[tag] = confirm
+	p.waitingMutex.Unlock()
+
+	if err := p.ch.PublishWithContext(ctx, exchange, key, false, false, msg); err != nil {
+		p.forget(tag)
+		return 0, nil, err
+	}
+	p.tag = tag
+
+	return tag, confirm, nil
+}
+
+// forget drops the waiter of a publish whose caller gave up, its confirm is discarded
+func (p *publisher) forget(tag uint64) {
+	p.waitingMutex.Lock()
+	delete(p.waiting, tag)
+	p.waitingMutex.Unlock()
+}
+
+// route delivers the confirms until the channel is closed, then releases the publishes still waiting
+func (p *publisher) route(confirms chan amqp.Confirmation) {
+	for confirm := range confirms {
+		p.waitingMutex.Lock()
+		waiter, ok := p.waiting[confirm.DeliveryTag]
+		delete(p.waiting, confirm.DeliveryTag)
+		p.waitingMutex.Unlock()
+
+		if ok {
+			waiter <- confirm
+		}
+	}
+
+	p.waitingMutex.Lock()
+	defer p.waitingMutex.Unlock()
+	p.closed = true
+	for tag, waiter := range p.waiting {
+		close(waiter)
+		delete(p.waiting, tag)
+	}
+}
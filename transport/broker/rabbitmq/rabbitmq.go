@@ -0,0 +1,765 @@
+package rabbitmq
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/lengocson131002/go-clean-core/logger"
+	"github.com/lengocson131002/go-clean-core/transport/broker"
+	amqp "github.com/rabbitmq/amqp091-go"
+)
+
+var (
+	ErrNotConnected = errors.New("rabbitmq broker is not connected")
+)
+
+type PublishNackError struct {
+	Topic string
+}
+
+func (e PublishNackError) Error() string {
+	return fmt.Sprintf("Message published to %s was not acknowledged by the broker", e.Topic)
+}
+
+type rBroker struct {
+	addrs []string
+	opts  broker.BrokerOptions
+
+	mutex     sync.Mutex
+	conn      amqpConnection
+	connected bool
+	subs      []*subscriber
+	// closed by Disconnect, it stops reconnecting. Nil when the broker is not started.
+	closing chan struct{}
+
+	pubMutex sync.Mutex
+	pub      *publisher
+
+	// request-reply pattern
+	replyMutex sync.Mutex
+	replyCh    amqpChannel
+	replyQueue string
+	replyKeys  map[string]bool
+	resps      sync.Map
+}
+
+func NewRabbitMQBroker(opts ...broker.BrokerOption) broker.Broker {
+	options := broker.BrokerOptions{
+		Context: context.Background(),
+		Logger:  DefaultLogger,
+	}
+
+	for _, o := range opts {
+		o(&options)
+	}
+
+	r := &rBroker{
+		opts: options,
+	}
+	r.addrs = r.getAddresses()
+
+	return r
+}
+
+type subscriber struct {
+	r       *rBroker
+	t       string
+	opts    broker.SubscribeOptions
+	handler broker.Handler
+	requeue bool
+
+	// the consumer on the current connection, replaced when the broker reconnects
+	mutex  sync.Mutex
+	ch     amqpChannel
+	tag    string
+	queue  string
+	done   chan struct{}
+	closed bool
+
+	closeOnce sync.Once
+	closeErr  error
+}
+
+type publication struct {
+	t     string
+	err   error
+	d     amqp.Delivery
+	m     *broker.Message
+	mutex sync.Mutex
+	acked bool
+}
+
+func (p *publication) Topic() string {
+	return p.t
+}
+
+func (p *publication) Message() *broker.Message {
+	return p.m
+}
+
+// Ack implements broker.Event. A delivery is acknowledged once, further calls are ignored.
+func (p *publication) Ack() error {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	if p.acked {
+		return nil
+	}
+	p.acked = true
+	return p.d.Ack(false)
+}
+
+// Nack rejects the delivery, the message is requeued or dead-lettered
+func (p *publication) Nack(requeue bool) error {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	if p.acked {
+		return nil
+	}
+	p.acked = true
+	return p.d.Nack(false, requeue)
+}
+
+func (p *publication) Error() error {
+	return p.err
+}
+
+func (s *subscriber) Options() broker.SubscribeOptions {
+	return s.opts
+}
+
+func (s *subscriber) Topic() string {
+	return s.t
+}
+
+// Unsubscribe cancels the consumer, waits for in-flight handlers and closes the channel
+func (s *subscriber) Unsubscribe() error {
+	r := s.r
+	r.mutex.Lock()
+	for i, sub := range r.subs {
+		if sub == s {
+			r.subs = append(r.subs[:i], r.subs[i+1:]...)
+			break
+		}
+	}
+	r.mutex.Unlock()
+
+	return s.close()
+}
+
+func (s *subscriber) close() error {
+	s.closeOnce.Do(func() {
+		s.closeErr = s.cancel()
+	})
+	return s.closeErr
+}
+
+func (s *subscriber) cancel() error {
+	s.mutex.Lock()
+	s.closed = true
+	ch, tag, done := s.ch, s.tag, s.done
+	s.mutex.Unlock()
+
+	// the deliveries channel is closed once the server confirmed the cancellation
+	if err := ch.Cancel(tag, false); err != nil {
+		ch.Close()
+		return err
+	}
+
+	timeout := s.r.getShutdownTimeout()
+	select {
+	case <-done:
+	case <-time.After(timeout):
+		ch.Close()
+		return broker.DrainTimeoutError{Timeout: timeout}
+	}
+
+	return ch.Close()
+}
+
+// consume declares the queue of the subscriber on the connection and handles its deliveries
+func (s *subscriber) consume(conn amqpConnection) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if s.closed {
+		return nil
+	}
+
+	r := s.r
+	ch, err := conn.Channel()
+	if err != nil {
+		return err
+	}
+
+	if err := ch.Qos(r.getPrefetchCount(s.opts), 0, false); err != nil {
+		ch.Close()
+		return err
+	}
+
+	var (
+		queueName = ""
+		shared    = len(s.opts.Group) != 0
+		args      amqp.Table
+	)
+
+	if shared {
+		queueName = fmt.Sprintf("%s.%s", s.t, s.opts.Group)
+	}
+
+	if s.opts.Context != nil {
+		if a, ok := s.opts.Context.Value(queueArgumentsKey{}).(amqp.Table); ok {
+			args = a
+		}
+	}
+
+	q, err := ch.QueueDeclare(queueName, shared, !shared, !shared, false, args)
+	if err != nil {
+		ch.Close()
+		return fmt.Errorf("failed to declare queue %s: %w", queueName, err)
+	}
+
+	if err := ch.QueueBind(q.Name, s.t, r.getExchange().name, false, nil); err != nil {
+		ch.Close()
+		return fmt.Errorf("failed to bind queue %s to topic %s: %w", q.Name, s.t, err)
+	}
+
+	tag := uuid.New().String()
+	deliveries, err := ch.Consume(q.Name, tag, false, false, false, false, nil)
+	if err != nil {
+		ch.Close()
+		return fmt.Errorf("failed to consume queue %s: %w", q.Name, err)
+	}
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for d := range deliveries {
+			r.handle(s.handler, s.t, d, s.opts, s.requeue)
+		}
+	}()
+
+	s.ch, s.tag, s.queue, s.done = ch, tag, q.Name, done
+	return nil
+}
+
+func (r *rBroker) Address() string {
+	if len(r.addrs) > 0 {
+		return r.addrs[0]
+	}
+	return DefaultRabbitMQBroker
+}
+
+// Connect dials the broker. When the connection is lost, the broker reconnects in the background with
+// the ReconnectBackoff delays, declares the exchange again and resubscribes the subscribers.
+// Publish and Subscribe return ErrNotConnected until it is reconnected.
+func (r *rBroker) Connect() error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	if r.closing != nil {
+		return nil
+	}
+
+	if err := r.connect(); err != nil {
+		return err
+	}
+
+	r.subs = make([]*subscriber, 0)
+	r.closing = make(chan struct{})
+
+	return nil
+}
+
+// connect dials the first reachable address and opens the publisher channel, it must be called with the lock held
+func (r *rBroker) connect() error {
+	var (
+		conn    amqpConnection
+		err     error
+		config  = r.getAmqpConfig()
+		dialFnc = r.getDialer()
+	)
+
+	for _, addr := range r.addrs {
+		conn, err = dialFnc(addr, config)
+		if err == nil {
+			break
+		}
+	}
+	if err != nil {
+		return err
+	}
+
+	go r.watch(conn, conn.NotifyClose(make(chan *amqp.Error, 1)))
+
+	ch, err := conn.Channel()
+	if err != nil {
+		conn.Close()
+		return err
+	}
+
+	ex := r.getExchange()
+	if err := ch.ExchangeDeclare(ex.name, ex.kind, true, false, false, false, nil); err != nil {
+		conn.Close()
+		return fmt.Errorf("failed to declare exchange %s: %w", ex.name, err)
+	}
+
+	if err := ch.Confirm(false); err != nil {
+		conn.Close()
+		return fmt.Errorf("failed to enable publisher confirms: %w", err)
+	}
+
+	r.conn = conn
+	r.connected = true
+	r.pubMutex.Lock()
+	r.pub = newPublisher(ch)
+	r.pubMutex.Unlock()
+
+	return nil
+}
+
+// watch waits until the connection is closed, and reconnects unless it was closed by Disconnect
+func (r *rBroker) watch(conn amqpConnection, closed chan *amqp.Error) {
+	// the channel is closed without error when the connection is closed by the client
+	err, ok := <-closed
+	if !ok || err == nil {
+		return
+	}
+
+	r.mutex.Lock()
+	if r.conn != conn || r.closing == nil {
+		r.mutex.Unlock()
+		return
+	}
+	r.connected = false
+	closing := r.closing
+	r.mutex.Unlock()
+
+	r.getLogger().Errorf(r.opts.Context, "[rabbitmq] connection lost, reconnecting: %s", err)
+
+	r.pubMutex.Lock()
+	r.pub = nil
+	r.pubMutex.Unlock()
+
+	// the reply queue is exclusive to the lost connection, it is declared again by the next request
+	r.replyMutex.Lock()
+	r.replyCh = nil
+	r.replyQueue = ""
+	r.replyKeys = nil
+	r.replyMutex.Unlock()
+
+	r.reconnect(closing)
+}
+
+// reconnect dials until it succeeds or the broker is disconnected, then resubscribes the subscribers
+func (r *rBroker) reconnect(closing chan struct{}) {
+	backoff, maxBackoff := r.getReconnectBackoff()
+	for {
+		select {
+		case <-closing:
+			return
+		case <-time.After(backoff):
+		}
+
+		r.mutex.Lock()
+		if r.closing != closing {
+			r.mutex.Unlock()
+			return
+		}
+		err := r.connect()
+		conn, subs := r.conn, append([]*subscriber(nil), r.subs...)
+		r.mutex.Unlock()
+
+		if err != nil {
+			r.getLogger().Errorf(r.opts.Context, "[rabbitmq] failed to reconnect: %s", err)
+			backoff = min(2*backoff, maxBackoff)
+			continue
+		}
+
+		for _, sub := range subs {
+			if err := sub.consume(conn); err != nil {
+				r.getLogger().Errorf(r.opts.Context, "[rabbitmq] failed to resubscribe to topic %s: %s", sub.t, err)
+			}
+		}
+
+		r.getLogger().Infof(r.opts.Context, "[rabbitmq] reconnected, %d subscribers resubscribed", len(subs))
+		return
+	}
+}
+
+func (r *rBroker) Disconnect() error {
+	r.mutex.Lock()
+	if r.closing == nil {
+		r.mutex.Unlock()
+		return nil
+	}
+	close(r.closing)
+	r.closing = nil
+	subs := r.subs
+	r.subs = nil
+	r.mutex.Unlock()
+
+	// drain subscribers before closing the publisher, handlers may still publish
+	var wg sync.WaitGroup
+	for _, sub := range subs {
+		wg.Add(1)
+		go func(sub *subscriber) {
+			defer wg.Done()
+			if err := sub.close(); err != nil {
+				r.getLogger().Errorf(r.opts.Context, "failed to close subscriber of topic %s: %s", sub.t, err)
+			}
+		}(sub)
+	}
+	wg.Wait()
+
+	r.replyMutex.Lock()
+	if r.replyCh != nil {
+		r.replyCh.Close()
+	}
+	r.replyCh = nil
+	r.replyQueue = ""
+	r.replyKeys = nil
+	r.replyMutex.Unlock()
+
+	r.pubMutex.Lock()
+	r.pub = nil
+	r.pubMutex.Unlock()
+
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	// the connection is already closed when it was lost
+	if !r.connected {
+		return nil
+	}
+	r.connected = false
+	return r.conn.Close()
+}
+
+func (r *rBroker) Init(opts ...broker.BrokerOption) error {
+	for _, o := range opts {
+		o(&r.opts)
+	}
+	r.addrs = r.getAddresses()
+	return nil
+}
+
+func (r *rBroker) Options() broker.BrokerOptions {
+	return r.opts
+}
+
+func (r *rBroker) Publish(ctx context.Context, topic string, msg *broker.Message, opts ...broker.PublishOption) error {
+	return r.getPublishFunc()(ctx, topic, msg, opts...)
+}
+
+// publish is the innermost PublishFunc wrapped by the publish middlewares, it waits for the publisher confirm
+func (r *rBroker) publish(ctx context.Context, topic string, msg *broker.Message, opts ...broker.PublishOption) error {
+	options := broker.PublishOptions{}
+	for _, opt := range opts {
+		opt(&options)
+	}
+
+	if options.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, options.Timeout)
+		defer cancel()
+	}
+
+	broker.PopulateEnvelope(ctx, msg)
+	pub := marshal(msg)
+
+	r.pubMutex.Lock()
+	p := r.pub
+	r.pubMutex.Unlock()
+
+	if p == nil {
+		return ErrNotConnected
+	}
+
+	tag, confirms, err := p.publish(ctx, r.getExchange().name, topic, pub)
+	if err != nil {
+		return err
+	}
+
+	select {
+	case confirm, ok := <-confirms:
+		if !ok {
+			return ErrNotConnected
+		}
+		if !confirm.Ack {
+			return PublishNackError{Topic: topic}
+		}
+		return nil
+	case <-ctx.Done():
+		p.forget(tag)
+		return ctx.Err()
+	}
+}
+
+func (r *rBroker) getPublishFunc() broker.PublishFunc {
+	return broker.ChainPublishMiddlewares(r.publish, r.opts.PublishMiddlewares...)
+}
+
+// PublishAndReceive publishes the message with `reply_to` and `correlation_id` properties and
+// waits for the reply. Replies are routed through the exchange to a queue exclusive to this broker instance,
+// the responder publishes the reply to the topic in the ReplyToHeader header.
+func (r *rBroker) PublishAndReceive(ctx context.Context, topic string, msg *broker.Message, opts ...broker.PublishOption) (*broker.Message, error) {
+	options := broker.PublishOptions{
+		Timeout: RequestReplyTimeout,
+	}
+
+	for _, opt := range opts {
+		opt(&options)
+	}
+
+	replyTo, err := r.ensureReplyQueue(options.ReplyToTopic)
+	if err != nil {
+		return nil, err
+	}
+
+	if msg.Headers == nil {
+		msg.Headers = make(map[string]string)
+	}
+
+	correlationId, ok := msg.Headers[CorrelationIdHeader]
+	if !ok || len(correlationId) == 0 {
+		correlationId = uuid.New().String()
+		msg.Headers[CorrelationIdHeader] = correlationId
+	}
+	msg.Headers[ReplyToHeader] = replyTo
+
+	msgChan := make(chan *broker.Message, 1)
+	r.resps.Store(correlationId, msgChan)
+	defer r.resps.Delete(correlationId)
+
+	if err := r.getPublishFunc()(ctx, topic, msg, opts...); err != nil {
+		return nil, err
+	}
+
+	select {
+	case reply := <-msgChan:
+		return reply, nil
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	case <-time.After(options.Timeout):
+		return nil, broker.RequestTimeoutResponse{
+			Timeout: options.Timeout,
+		}
+	}
+}
+
+// ensureReplyQueue declares the reply queue of this instance and binds it to the reply routing key
+func (r *rBroker) ensureReplyQueue(replyKey string) (string, error) {
+	r.replyMutex.Lock()
+	defer r.replyMutex.Unlock()
+
+	r.mutex.Lock()
+	conn, connected := r.conn, r.connected
+	r.mutex.Unlock()
+	if !connected {
+		return "", ErrNotConnected
+	}
+
+	exchange := r.getExchange().name
+	if r.replyCh == nil {
+		ch, err := conn.Channel()
+		if err != nil {
+			return "", err
+		}
+
+		q, err := ch.QueueDeclare("", false, true, true, false, nil)
+		if err != nil {
+			ch.Close()
+			return "", fmt.Errorf("failed to declare reply queue: %w", err)
+		}
+
+		if err := ch.QueueBind(q.Name, q.Name, exchange, false, nil); err != nil {
+			ch.Close()
+			return "", fmt.Errorf("failed to bind reply queue: %w", err)
+		}
+
+		deliveries, err := ch.Consume(q.Name, "", true, true, false, false, nil)
+		if err != nil {
+			ch.Close()
+			return "", fmt.Errorf("failed to consume reply queue: %w", err)
+		}
+
+		go func() {
+			for d := range deliveries {
+				if msgChan, ok := r.resps.LoadAndDelete(d.CorrelationId); ok {
+					msgChan.(chan *broker.Message) <- unmarshal(d)
+				}
+			}
+		}()
+
+		r.replyCh = ch
+		r.replyQueue = q.Name
+		r.replyKeys = map[string]bool{q.Name: true}
+	}
+
+	if len(replyKey) == 0 {
+		return r.replyQueue, nil
+	}
+
+	// shared reply topics are received by every instance and filtered by correlation id
+	if !r.replyKeys[replyKey] {
+		if err := r.replyCh.QueueBind(r.replyQueue, replyKey, exchange, false, nil); err != nil {
+			return "", fmt.Errorf("failed to bind reply queue to %s: %w", replyKey, err)
+		}
+		r.replyKeys[replyKey] = true
+	}
+
+	return replyKey, nil
+}
+
+// Subscribe consumes the topic from a queue bound to the exchange. Subscribers of the same group
+// share a durable queue named `<topic>.<group>`, without group an exclusive queue is used.
+func (r *rBroker) Subscribe(topic string, handler broker.Handler, opts ...broker.SubscribeOption) (broker.Subscriber, error) {
+	opt := broker.SubscribeOptions{
+		AutoAck: true,
+	}
+
+	for _, o := range opts {
+		o(&opt)
+	}
+
+	r.mutex.Lock()
+	conn, connected := r.conn, r.connected
+	r.mutex.Unlock()
+	if !connected {
+		return nil, ErrNotConnected
+	}
+
+	sub := &subscriber{
+		r:       r,
+		t:       topic,
+		opts:    opt,
+		handler: broker.ChainSubscriptionHandler(handler, opt, r.opts.SubscribeMiddlewares...),
+		requeue: r.getRequeueOnError(opt),
+	}
+
+	if err := sub.consume(conn); err != nil {
+		return nil, err
+	}
+
+	r.mutex.Lock()
+	r.subs = append(r.subs, sub)
+	r.mutex.Unlock()
+
+	r.getLogger().Infof(r.opts.Context, "Subcribed to topic: %s. Queue: %s", topic, sub.queue)
+
+	return sub, nil
+}
+
+func (r *rBroker) handle(h broker.Handler, topic string, d amqp.Delivery, opt broker.SubscribeOptions, requeue bool) {
+	ctx := context.Background()
+	p := &publication{t: topic, d: d, m: unmarshal(d)}
+	ctx = broker.ContextWithEnvelope(ctx, p.m)
+
+	err := h(ctx, p)
+	if err == nil {
+		if opt.AutoAck {
+			if err := p.Ack(); err != nil {
+				r.getLogger().Errorf(ctx, "[rabbitmq] failed to ack message: %v", err)
+			}
+		}
+		return
+	}
+
+	p.err = err
+	if nackErr := p.Nack(requeue); nackErr != nil {
+		r.getLogger().Errorf(ctx, "[rabbitmq] failed to nack message: %v", nackErr)
+	}
+
+	if errHandler := r.opts.ErrorHandler; errHandler != nil {
+		errHandler(ctx, p)
+	} else {
+		r.getLogger().Errorf(ctx, "[rabbitmq] subscriber error: %v", err)
+	}
+}
+
+func (r *rBroker) getAddresses() []string {
+	var addrs []string
+	for _, addr := range r.opts.Addrs {
+		if len(addr) == 0 {
+			continue
+		}
+		addrs = append(addrs, addr)
+	}
+	if len(addrs) == 0 {
+		addrs = []string{DefaultRabbitMQBroker}
+	}
+	return addrs
+}
+
+func (r *rBroker) getAmqpConfig() amqp.Config {
+	config := amqp.Config{}
+	if c, ok := r.opts.Context.Value(amqpConfigKey{}).(amqp.Config); ok {
+		config = c
+	}
+	if r.opts.TLSConfig != nil && config.TLSClientConfig == nil {
+		config.TLSClientConfig = r.opts.TLSConfig
+	}
+	return config
+}
+
+func (r *rBroker) getDialer() dialer {
+	if d, ok := r.opts.Context.Value(dialerKey{}).(dialer); ok {
+		return d
+	}
+	return dial
+}
+
+func (r *rBroker) getExchange() exchange {
+	if e, ok := r.opts.Context.Value(exchangeKey{}).(exchange); ok {
+		return e
+	}
+	return exchange{name: DefaultExchange, kind: DefaultExchangeKind}
+}
+
+func (r *rBroker) getReconnectBackoff() (time.Duration, time.Duration) {
+	if b, ok := r.opts.Context.Value(reconnectBackoffKey{}).(reconnectBackoff); ok && b.backoff > 0 {
+		return b.backoff, max(b.backoff, b.max)
+	}
+	return DefaultReconnectBackoff, DefaultMaxReconnectBackoff
+}
+
+func (r *rBroker) getShutdownTimeout() time.Duration {
+	if t, ok := r.opts.Context.Value(shutdownTimeoutKey{}).(time.Duration); ok && t > 0 {
+		return t
+	}
+	return DefaultShutdownTimeout
+}
+
+func (r *rBroker) getPrefetchCount(opt broker.SubscribeOptions) int {
+	if opt.Context != nil {
+		if c, ok := opt.Context.Value(prefetchCountKey{}).(int); ok && c > 0 {
+			return c
+		}
+	}
+	return DefaultPrefetchCount
+}
+
+func (r *rBroker) getRequeueOnError(opt broker.SubscribeOptions) bool {
+	if opt.Context != nil {
+		if requeue, ok := opt.Context.Value(requeueOnErrorKey{}).(bool); ok {
+			return requeue
+		}
+	}
+	return false
+}
+
+func (r *rBroker) getLogger() logger.Logger {
+	logger := r.opts.Logger
+	if logger == nil {
+		logger = DefaultLogger
+	}
+	return logger
+}
+
+func (r *rBroker) String() string {
+	return "rabbitmq broker implementation"
+}
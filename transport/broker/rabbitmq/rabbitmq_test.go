@@ -0,0 +1,179 @@
+package rabbitmq
+
+import (
+	"context"
+	"errors"
+	"os"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/lengocson131002/go-clean-core/transport/broker"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// getRabbitMQBroker runs against RABBITMQ_URL when set, otherwise against the in-process stand-in
+func getRabbitMQBroker(t *testing.T) broker.Broker {
+	var br broker.Broker
+	if url := os.Getenv("RABBITMQ_URL"); len(url) != 0 {
+		br = NewRabbitMQBroker(broker.WithBrokerAddresses(url))
+	} else {
+		br = NewRabbitMQBroker(withDialer(newFakeServer().dial))
+	}
+
+	require.NoError(t, br.Connect())
+	t.Cleanup(func() {
+		br.Disconnect()
+	})
+	return br
+}
+
+func TestPublishSubscribe(t *testing.T) {
+	br := getRabbitMQBroker(t)
+
+	received := make(chan *broker.Message, 1)
+	_, err := br.Subscribe("go.clean.test.rabbitmq", func(ctx context.Context, e broker.Event) error {
+		received <- e.Message()
+		return nil
+	})
+	require.NoError(t, err)
+
+	err = br.Publish(context.Background(), "go.clean.test.rabbitmq", &broker.Message{
+		Headers: map[string]string{"key": "value"},
+		Body:    []byte("hello"),
+	})
+	require.NoError(t, err)
+
+	select {
+	case msg := <-received:
+		assert.Equal(t, "hello", string(msg.Body))
+		assert.Equal(t, "value", msg.Headers["key"])
+		assert.NotEmpty(t, msg.Headers[CorrelationIdHeader])
+	case <-time.After(5 * time.Second):
+		t.Fatal("message was not received")
+	}
+}
+
+func TestSubscribeGroupSharesQueue(t *testing.T) {
+	br := getRabbitMQBroker(t)
+
+	var (
+		first, second atomic.Int32
+		wg            sync.WaitGroup
+		total         = 10
+	)
+	wg.Add(total)
+
+	for _, counter := range []*atomic.Int32{&first, &second} {
+		counter := counter
+		_, err := br.Subscribe("go.clean.test.rabbitmq.group", func(ctx context.Context, e broker.Event) error {
+			counter.Add(1)
+			wg.Done()
+			return nil
+		}, broker.WithSubscribeGroup("workers"))
+		require.NoError(t, err)
+	}
+
+	for i := 0; i < total; i++ {
+		require.NoError(t, br.Publish(context.Background(), "go.clean.test.rabbitmq.group", &broker.Message{Body: []byte("job")}))
+	}
+
+	wg.Wait()
+	assert.Equal(t, int32(total), first.Load()+second.Load())
+	assert.NotZero(t, first.Load())
+	assert.NotZero(t, second.Load())
+}
+
+func TestPublishAndReceive(t *testing.T) {
+	br := getRabbitMQBroker(t)
+
+	_, err := br.Subscribe("go.clean.test.rabbitmq.request", func(ctx context.Context, e broker.Event) error {
+		msg := e.Message()
+		return br.Publish(ctx, msg.Headers[ReplyToHeader], &broker.Message{
+			Headers: map[string]string{CorrelationIdHeader: msg.Headers[CorrelationIdHeader]},
+			Body:    append([]byte("re: "), msg.Body...),
+		})
+	}, broker.WithSubscribeGroup("responders"))
+	require.NoError(t, err)
+
+	reply, err := br.PublishAndReceive(context.Background(), "go.clean.test.rabbitmq.request", &broker.Message{
+		Body: []byte("ping"),
+	}, broker.WithPublishTimeout(5*time.Second))
+	require.NoError(t, err)
+	assert.Equal(t, "re: ping", string(reply.Body))
+}
+
+func TestHandlerErrorNacksMessage(t *testing.T) {
+	server := newFakeServer()
+	br := NewRabbitMQBroker(withDialer(server.dial))
+	require.NoError(t, br.Connect())
+	defer br.Disconnect()
+
+	handled := make(chan struct{})
+	_, err := br.Subscribe("go.clean.test.rabbitmq.error", func(ctx context.Context, e broker.Event) error {
+		defer close(handled)
+		return errors.New("failed")
+	})
+	require.NoError(t, err)
+
+	require.NoError(t, br.Publish(context.Background(), "go.clean.test.rabbitmq.error", &broker.Message{Body: []byte("x")}))
+	<-handled
+
+	assert.Eventually(t, func() bool {
+		server.mutex.Lock()
+		defer server.mutex.Unlock()
+		return server.nacked == 1
+	}, time.Second, 10*time.Millisecond)
+}
+
+func TestUnsubscribeWaitsForInflightHandler(t *testing.T) {
+	br := getRabbitMQBroker(t)
+
+	var (
+		started  = make(chan struct{})
+		finished atomic.Bool
+	)
+	sub, err := br.Subscribe("go.clean.test.rabbitmq.drain", func(ctx context.Context, e broker.Event) error {
+		close(started)
+		time.Sleep(50 * time.Millisecond)
+		finished.Store(true)
+		return nil
+	})
+	require.NoError(t, err)
+
+	require.NoError(t, br.Publish(context.Background(), "go.clean.test.rabbitmq.drain", &broker.Message{Body: []byte("x")}))
+	<-started
+
+	require.NoError(t, sub.Unsubscribe())
+	assert.True(t, finished.Load())
+}
+
+func TestReconnect(t *testing.T) {
+	server := newFakeServer()
+	br := NewRabbitMQBroker(withDialer(server.dial), ReconnectBackoff(10*time.Millisecond, 50*time.Millisecond))
+	require.NoError(t, br.Connect())
+	defer br.Disconnect()
+
+	received := make(chan *broker.Message, 1)
+	_, err := br.Subscribe("go.clean.test.rabbitmq.reconnect", func(ctx context.Context, e broker.Event) error {
+		received <- e.Message()
+		return nil
+	}, broker.WithSubscribeGroup("workers"))
+	require.NoError(t, err)
+
+	server.drop()
+
+	// publishes fail until the broker is reconnected and the subscriber consumes again
+	assert.Eventually(t, func() bool {
+		return br.Publish(context.Background(), "go.clean.test.rabbitmq.reconnect", &broker.Message{Body: []byte("x")}) == nil
+	}, 5*time.Second, 10*time.Millisecond)
+
+	select {
+	case msg := <-received:
+		assert.Equal(t, "x", string(msg.Body))
+	case <-time.After(5 * time.Second):
+		t.Fatal("message was not received after reconnecting")
+	}
+}
@@ -0,0 +1,62 @@
+package redisstream
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/google/uuid"
+	"github.com/lengocson131002/go-clean-core/transport/broker"
+)
+
+const (
+	CorrelationIdHeader = broker.CorrelationIdHeader
+	ReplyToHeader       = broker.ReplyToHeader
+
+	headersField = "headers"
+	bodyField    = "body"
+
+	// fields added to the entries moved to the dead letter stream
+	deadLetterStreamField     = "dead_letter_stream"
+	deadLetterIdField         = "dead_letter_id"
+	deadLetterDeliveriesField = "dead_letter_deliveries"
+)
+
+// marshal maps the message to the fields of a stream entry
+func marshal(msg *broker.Message) (map[string]interface{}, error) {
+	if len(msg.Headers) == 0 {
+		msg.Headers = make(map[string]string)
+	}
+
+	correlationId, ok := msg.Headers[CorrelationIdHeader]
+	if !ok || len(correlationId) == 0 {
+		msg.Headers[CorrelationIdHeader] = uuid.New().String()
+	}
+
+	headers, err := json.Marshal(msg.Headers)
+	if err != nil {
+		return nil, err
+	}
+
+	return map[string]interface{}{
+		headersField: headers,
+		bodyField:    msg.Body,
+	}, nil
+}
+
+func unmarshal(values map[string]interface{}) (*broker.Message, error) {
+	msg := &broker.Message{
+		Headers: make(map[string]string),
+	}
+
+	if headers, ok := values[headersField]; ok {
+		if err := json.Unmarshal([]byte(fmt.Sprint(headers)), &msg.Headers); err != nil {
+			return nil, err
+		}
+	}
+
+	if body, ok := values[bodyField].(string); ok {
+		msg.Body = []byte(body)
+	}
+
+	return msg, nil
+}
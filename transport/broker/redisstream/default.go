@@ -0,0 +1,24 @@
+package redisstream
+
+import (
+	"time"
+
+	"github.com/lengocson131002/go-clean-core/logger/logrus"
+)
+
+var (
+	DefaultRedisBroker     = "127.0.0.1:6379"
+	DefaultLogger          = logrus.NewLogrusLogger()
+	DefaultBatchSize       = int64(10)
+	DefaultBlockTimeout    = time.Second * 2
+	DefaultClaimMinIdle    = time.Minute
+	DefaultClaimInterval   = time.Second * 30
+	DefaultMaxDeliveries   = int64(10)
+	DefaultShutdownTimeout = time.Second * 30
+	RequestReplyTimeout    = time.Second * 60
+
+	// DefaultReplyStreamPrefix prefixes the reply stream of each broker instance
+	DefaultReplyStreamPrefix = "reply."
+	// DefaultDeadLetterSuffix suffixes the topic to name its dead letter stream
+	DefaultDeadLetterSuffix = ".dlq"
+)
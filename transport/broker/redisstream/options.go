@@ -0,0 +1,90 @@
+package redisstream
+
+import (
+	"time"
+
+	"github.com/lengocson131002/go-clean-core/transport/broker"
+	"github.com/redis/go-redis/v9"
+)
+
+type clientKey struct{}
+
+// Client reuses an existing redis client instead of connecting to the broker addresses.
+// The client is not closed on disconnect.
+func Client(client redis.UniversalClient) broker.BrokerOption {
+	return setBrokerOption(clientKey{}, client)
+}
+
+type universalOptionsKey struct{}
+
+// UniversalOptions configures the redis client, e.g. password, database or sentinel master.
+// Broker addresses are used when the options have none.
+func UniversalOptions(opts *redis.UniversalOptions) broker.BrokerOption {
+	return setBrokerOption(universalOptionsKey{}, opts)
+}
+
+type maxLenKey struct{}
+
+// MaxLen approximately trims the streams to the given length on publish
+func MaxLen(maxLen int64) broker.BrokerOption {
+	return setBrokerOption(maxLenKey{}, maxLen)
+}
+
+type shutdownTimeoutKey struct{}
+
+// ShutdownTimeout is the maximum time Unsubscribe and Disconnect wait for in-flight handlers
+func ShutdownTimeout(timeout time.Duration) broker.BrokerOption {
+	return setBrokerOption(shutdownTimeoutKey{}, timeout)
+}
+
+type batchSizeKey struct{}
+
+// BatchSize is the maximum number of entries read at once by the subscriber
+func BatchSize(size int64) broker.SubscribeOption {
+	return setSubscribeOption(batchSizeKey{}, size)
+}
+
+type blockTimeoutKey struct{}
+
+// BlockTimeout is the maximum time a read blocks waiting for new entries
+func BlockTimeout(timeout time.Duration) broker.SubscribeOption {
+	return setSubscribeOption(blockTimeoutKey{}, timeout)
+}
+
+type claimMinIdleKey struct{}
+
+// ClaimMinIdle is the time an entry stays pending before it is reclaimed from a crashed
+// or failing consumer of the group and delivered again
+func ClaimMinIdle(idle time.Duration) broker.SubscribeOption {
+	return setSubscribeOption(claimMinIdleKey{}, idle)
+}
+
+type claimIntervalKey struct{}
+
+// ClaimInterval is how often the subscriber looks for pending entries to reclaim
+func ClaimInterval(interval time.Duration) broker.SubscribeOption {
+	return setSubscribeOption(claimIntervalKey{}, interval)
+}
+
+type maxDeliveriesKey struct{}
+
+// MaxDeliveries is the number of deliveries of an entry before it is moved to the dead letter stream
+// when reclaimed, DefaultMaxDeliveries by default. A negative max reclaims entries forever.
+func MaxDeliveries(max int64) broker.SubscribeOption {
+	return setSubscribeOption(maxDeliveriesKey{}, max)
+}
+
+type deadLetterStreamKey struct{}
+
+// DeadLetterStream is the stream receiving the entries delivered more than MaxDeliveries times,
+// the topic suffixed by DefaultDeadLetterSuffix by default
+func DeadLetterStream(stream string) broker.SubscribeOption {
+	return setSubscribeOption(deadLetterStreamKey{}, stream)
+}
+
+type startIdKey struct{}
+
+// StartId is the stream id a new consumer group starts from, `$` (new entries only) by default, `0` for the whole stream
+func StartId(id string) broker.SubscribeOption {
+	return setSubscribeOption(startIdKey{}, id)
+}
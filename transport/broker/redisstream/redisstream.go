@@ -0,0 +1,802 @@
+package redisstream
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/lengocson131002/go-clean-core/logger"
+	"github.com/lengocson131002/go-clean-core/transport/broker"
+	"github.com/redis/go-redis/v9"
+)
+
+var (
+	ErrNotConnected = errors.New("redis stream broker is not connected")
+)
+
+type rsBroker struct {
+	addrs []string
+	opts  broker.BrokerOptions
+
+	mutex     sync.Mutex
+	client    redis.UniversalClient
+	ownClient bool
+	connected bool
+	subs      []*subscriber
+
+	// request-reply pattern, replies are read from a stream per instance or from shared reply streams
+	replyMutex   sync.Mutex
+	replyCtx     context.Context
+	replyCancel  context.CancelFunc
+	replyStream  string
+	replyReaders map[string]bool
+	replyWg      sync.WaitGroup
+	resps        sync.Map
+}
+
+func NewRedisStreamBroker(opts ...broker.BrokerOption) broker.Broker {
+	options := broker.BrokerOptions{
+		Context: context.Background(),
+		Logger:  DefaultLogger,
+	}
+
+	for _, o := range opts {
+		o(&options)
+	}
+
+	r := &rsBroker{
+		opts: options,
+	}
+	r.addrs = r.getAddresses()
+
+	return r
+}
+
+type subscriber struct {
+	r         *rsBroker
+	t         string
+	group     string
+	consumer  string
+	ephemeral bool
+	opts      broker.SubscribeOptions
+	cancel    context.CancelFunc
+	done      chan struct{}
+
+	closeOnce sync.Once
+	closeErr  error
+}
+
+type publication struct {
+	t     string
+	id    string
+	err   error
+	m     *broker.Message
+	s     *subscriber
+	mutex sync.Mutex
+	acked bool
+}
+
+func (p *publication) Topic() string {
+	return p.t
+}
+
+func (p *publication) Message() *broker.Message {
+	return p.m
+}
+
+// Ack implements broker.Event. Entries not acknowledged stay pending and are reclaimed after ClaimMinIdle.
+func (p *publication) Ack() error {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	if p.acked {
+		return nil
+	}
+	p.acked = true
+	return p.s.r.client.XAck(context.Background(), p.t, p.s.group, p.id).Err()
+}
+
+func (p *publication) Error() error {
+	return p.err
+}
+
+func (s *subscriber) Options() broker.SubscribeOptions {
+	return s.opts
+}
+
+func (s *subscriber) Topic() string {
+	return s.t
+}
+
+// Unsubscribe stops reading the stream and waits for the in-flight handler.
+// The consumer group of a subscriber without group is destroyed.
+func (s *subscriber) Unsubscribe() error {
+	r := s.r
+	r.mutex.Lock()
+	for i, sub := range r.subs {
+		if sub == s {
+			r.subs = append(r.subs[:i], r.subs[i+1:]...)
+			break
+		}
+	}
+	r.mutex.Unlock()
+
+	return s.close()
+}
+
+func (s *subscriber) close() error {
+	s.closeOnce.Do(func() {
+		s.closeErr = s.drain()
+	})
+	return s.closeErr
+}
+
+func (s *subscriber) drain() error {
+	s.cancel()
+
+	timeout := s.r.getShutdownTimeout()
+	select {
+	case <-s.done:
+	case <-time.After(timeout):
+		return broker.DrainTimeoutError{Timeout: timeout}
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	client := s.r.client
+	if s.ephemeral {
+		return client.XGroupDestroy(ctx, s.t, s.group).Err()
+	}
+
+	// remove the consumer from the group unless it still owns pending entries, those are reclaimed by other members
+	pending, err := client.XPendingExt(ctx, &redis.XPendingExtArgs{
+		Stream:   s.t,
+		Group:    s.group,
+		Start:    "-",
+		End:      "+",
+		Count:    1,
+		Consumer: s.consumer,
+	}).Result()
+	if err != nil {
+		return err
+	}
+	if len(pending) == 0 {
+		return client.XGroupDelConsumer(ctx, s.t, s.group, s.consumer).Err()
+	}
+	return nil
+}
+
+// run reads new entries of the group and periodically reclaims entries pending for too long
+func (s *subscriber) run(ctx context.Context, h broker.Handler) {
+	defer close(s.done)
+
+	var (
+		r             = s.r
+		batchSize     = r.getBatchSize(s.opts)
+		blockTimeout  = r.getBlockTimeout(s.opts)
+		claimMinIdle  = r.getClaimMinIdle(s.opts)
+		claimInterval = r.getClaimInterval(s.opts)
+		lastClaim     time.Time
+	)
+
+	for ctx.Err() == nil {
+		if !s.ephemeral && time.Since(lastClaim) >= claimInterval {
+			s.claim(ctx, h, batchSize, claimMinIdle)
+			lastClaim = time.Now()
+		}
+
+		streams, err := r.client.XReadGroup(ctx, &redis.XReadGroupArgs{
+			Group:    s.group,
+			Consumer: s.consumer,
+			Streams:  []string{s.t, ">"},
+			Count:    batchSize,
+			Block:    blockTimeout,
+		}).Result()
+		if ctx.Err() != nil {
+			return
+		}
+		if err != nil {
+			if !errors.Is(err, redis.Nil) {
+				r.getLogger().Errorf(ctx, "[redis stream consumer] failed to read %s: %v", s.t, err)
+				sleep(ctx, blockTimeout)
+			}
+			continue
+		}
+
+		for _, stream := range streams {
+			s.handleAll(ctx, h, stream.Messages)
+		}
+	}
+}
+
+// claim takes over the entries pending longer than minIdle in other consumers of the group.
+// Entries delivered more than MaxDeliveries times are moved to the dead letter stream instead of being handled.
+func (s *subscriber) claim(ctx context.Context, h broker.Handler, batchSize int64, minIdle time.Duration) {
+	start := "0-0"
+	for ctx.Err() == nil {
+		msgs, next, err := s.r.client.XAutoClaim(ctx, &redis.XAutoClaimArgs{
+			Stream:   s.t,
+			Group:    s.group,
+			Consumer: s.consumer,
+			MinIdle:  minIdle,
+			Start:    start,
+			Count:    batchSize,
+		}).Result()
+		if err != nil {
+			if ctx.Err() == nil {
+				s.r.getLogger().Errorf(ctx, "[redis stream consumer] failed to claim pending entries of %s: %v", s.t, err)
+			}
+			return
+		}
+
+		s.handleAll(ctx, h, s.deadLetter(ctx, msgs))
+
+		if next == "0-0" || len(next) == 0 {
+			return
+		}
+		start = next
+	}
+}
+
+// deadLetter moves the claimed entries delivered too many times to the dead letter stream and acknowledges them,
+// it returns the entries to handle
+func (s *subscriber) deadLetter(ctx context.Context, msgs []redis.XMessage) []redis.XMessage {
+	maxDeliveries := s.r.getMaxDeliveries(s.opts)
+	if maxDeliveries <= 0 || len(msgs) == 0 {
+		return msgs
+	}
+
+	client := s.r.client
+	pending, err := client.XPendingExt(ctx, &redis.XPendingExtArgs{
+		Stream:   s.t,
+		Group:    s.group,
+		Start:    msgs[0].ID,
+		End:      msgs[len(msgs)-1].ID,
+		Count:    int64(len(msgs)),
+		Consumer: s.consumer,
+	}).Result()
+	if err != nil {
+		s.r.getLogger().Errorf(ctx, "[redis stream consumer] failed to read delivery counts of %s: %v", s.t, err)
+		return msgs
+	}
+
+	deliveries := make(map[string]int64, len(pending))
+	for _, p := range pending {
+		deliveries[p.ID] = p.RetryCount
+	}
+
+	var (
+		dlq    = s.r.getDeadLetterStream(s.t, s.opts)
+		handle = msgs[:0]
+	)
+	for _, msg := range msgs {
+		count := deliveries[msg.ID]
+		if count <= maxDeliveries {
+			handle = append(handle, msg)
+			continue
+		}
+
+		values := make(map[string]interface{}, len(msg.Values)+3)
+		for k, v := range msg.Values {
+			values[k] = v
+		}
+		values[deadLetterStreamField] = s.t
+		values[deadLetterIdField] = msg.ID
+		values[deadLetterDeliveriesField] = count
+
+		if err := client.XAdd(ctx, &redis.XAddArgs{Stream: dlq, Values: values}).Err(); err != nil {
+			s.r.getLogger().Errorf(ctx, "[redis stream consumer] failed to dead letter entry %s of %s: %v", msg.ID, s.t, err)
+			continue
+		}
+		if err := client.XAck(ctx, s.t, s.group, msg.ID).Err(); err != nil {
+			s.r.getLogger().Errorf(ctx, "[redis stream consumer] failed to ack dead lettered entry %s of %s: %v", msg.ID, s.t, err)
+			continue
+		}
+		s.r.getLogger().Errorf(ctx, "[redis stream consumer] entry %s of %s delivered %d times, moved to %s", msg.ID, s.t, count, dlq)
+	}
+
+	return handle
+}
+
+// handleAll handles the entries in order, the remaining entries stay pending once unsubscribing started
+func (s *subscriber) handleAll(ctx context.Context, h broker.Handler, msgs []redis.XMessage) {
+	for _, msg := range msgs {
+		if ctx.Err() != nil {
+			return
+		}
+		s.handle(h, msg)
+	}
+}
+
+func (s *subscriber) handle(h broker.Handler, msg redis.XMessage) {
+	ctx := context.Background()
+	r := s.r
+
+	m, err := unmarshal(msg.Values)
+	if err != nil {
+		r.getLogger().Errorf(ctx, "[redis stream consumer]: failed to unmarshal entry %s of %s: %v", msg.ID, s.t, err)
+		// the entry can never be handled, acknowledge it to not reclaim it forever
+		r.client.XAck(ctx, s.t, s.group, msg.ID)
+		return
+	}
+
+	p := &publication{t: s.t, id: msg.ID, m: m, s: s}
+	ctx = broker.ContextWithEnvelope(ctx, m)
+
+	err = h(ctx, p)
+	if err == nil {
+		if s.opts.AutoAck {
+			if err := p.Ack(); err != nil {
+				r.getLogger().Errorf(ctx, "[redis stream] failed to ack entry: %v", err)
+			}
+		}
+		return
+	}
+
+	p.err = err
+	if errHandler := r.opts.ErrorHandler; errHandler != nil {
+		errHandler(ctx, p)
+	} else {
+		r.getLogger().Errorf(ctx, "[redis stream] subscriber error: %v", err)
+	}
+}
+
+func (r *rsBroker) Address() string {
+	if len(r.addrs) > 0 {
+		return r.addrs[0]
+	}
+	return DefaultRedisBroker
+}
+
+func (r *rsBroker) Connect() error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	if r.connected {
+		return nil
+	}
+
+	client, own := r.getClient()
+	if err := client.Ping(r.opts.Context).Err(); err != nil {
+		if own {
+			client.Close()
+		}
+		return err
+	}
+
+	r.client = client
+	r.ownClient = own
+	r.subs = make([]*subscriber, 0)
+	r.connected = true
+
+	r.replyMutex.Lock()
+	r.replyCtx, r.replyCancel = context.WithCancel(context.Background())
+	r.replyStream = DefaultReplyStreamPrefix + uuid.New().String()
+	r.replyReaders = make(map[string]bool)
+	r.replyMutex.Unlock()
+
+	return nil
+}
+
+func (r *rsBroker) Disconnect() error {
+	r.mutex.Lock()
+	if !r.connected {
+		r.mutex.Unlock()
+		return nil
+	}
+	subs := r.subs
+	r.subs = nil
+	r.mutex.Unlock()
+
+	// drain subscribers before closing the client, handlers may still publish
+	var wg sync.WaitGroup
+	for _, sub := range subs {
+		wg.Add(1)
+		go func(sub *subscriber) {
+			defer wg.Done()
+			if err := sub.close(); err != nil {
+				r.getLogger().Errorf(r.opts.Context, "failed to close subscriber of topic %s: %s", sub.t, err)
+			}
+		}(sub)
+	}
+	wg.Wait()
+
+	r.replyMutex.Lock()
+	r.replyCancel()
+	r.replyWg.Wait()
+	if r.replyReaders[r.replyStream] {
+		r.client.Del(context.Background(), r.replyStream)
+	}
+	r.replyReaders = nil
+	r.replyMutex.Unlock()
+
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	r.connected = false
+	if r.ownClient {
+		return r.client.Close()
+	}
+	return nil
+}
+
+func (r *rsBroker) Init(opts ...broker.BrokerOption) error {
+	for _, o := range opts {
+		o(&r.opts)
+	}
+	r.addrs = r.getAddresses()
+	return nil
+}
+
+func (r *rsBroker) Options() broker.BrokerOptions {
+	return r.opts
+}
+
+func (r *rsBroker) Publish(ctx context.Context, topic string, msg *broker.Message, opts ...broker.PublishOption) error {
+	return r.getPublishFunc()(ctx, topic, msg, opts...)
+}
+
+// publish is the innermost PublishFunc wrapped by the publish middlewares, it appends the message to the topic stream
+func (r *rsBroker) publish(ctx context.Context, topic string, msg *broker.Message, opts ...broker.PublishOption) error {
+	options := broker.PublishOptions{}
+	for _, opt := range opts {
+		opt(&options)
+	}
+
+	if options.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, options.Timeout)
+		defer cancel()
+	}
+
+	r.mutex.Lock()
+	client, connected := r.client, r.connected
+	r.mutex.Unlock()
+	if !connected {
+		return ErrNotConnected
+	}
+
+	broker.PopulateEnvelope(ctx, msg)
+	values, err := marshal(msg)
+	if err != nil {
+		return err
+	}
+
+	maxLen := r.getMaxLen()
+	return client.XAdd(ctx, &redis.XAddArgs{
+		Stream: topic,
+		MaxLen: maxLen,
+		Approx: maxLen > 0,
+		Values: values,
+	}).Err()
+}
+
+func (r *rsBroker) getPublishFunc() broker.PublishFunc {
+	return broker.ChainPublishMiddlewares(r.publish, r.opts.PublishMiddlewares...)
+}
+
+// PublishAndReceive publishes the message with the reply stream in the ReplyToHeader header and waits for the reply.
+// Each instance reads replies from its own stream, the responder publishes the reply to that stream
+// with the same CorrelationIdHeader header.
+func (r *rsBroker) PublishAndReceive(ctx context.Context, topic string, msg *broker.Message, opts ...broker.PublishOption) (*broker.Message, error) {
+	options := broker.PublishOptions{
+		Timeout: RequestReplyTimeout,
+	}
+
+	for _, opt := range opts {
+		opt(&options)
+	}
+
+	replyTo, err := r.ensureReplyReader(options.ReplyToTopic)
+	if err != nil {
+		return nil, err
+	}
+
+	if msg.Headers == nil {
+		msg.Headers = make(map[string]string)
+	}
+
+	correlationId, ok := msg.Headers[CorrelationIdHeader]
+	if !ok || len(correlationId) == 0 {
+		correlationId = uuid.New().String()
+		msg.Headers[CorrelationIdHeader] = correlationId
+	}
+	msg.Headers[ReplyToHeader] = replyTo
+
+	msgChan := make(chan *broker.Message, 1)
+	r.resps.Store(correlationId, msgChan)
+	defer r.resps.Delete(correlationId)
+
+	if err := r.getPublishFunc()(ctx, topic, msg, opts...); err != nil {
+		return nil, err
+	}
+
+	select {
+	case reply := <-msgChan:
+		return reply, nil
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	case <-time.After(options.Timeout):
+		return nil, broker.RequestTimeoutResponse{
+			Timeout: options.Timeout,
+		}
+	}
+}
+
+// ensureReplyReader starts reading the reply stream once and returns its name
+func (r *rsBroker) ensureReplyReader(replyTo string) (string, error) {
+	r.replyMutex.Lock()
+	defer r.replyMutex.Unlock()
+
+	r.mutex.Lock()
+	client, connected := r.client, r.connected
+	r.mutex.Unlock()
+	if !connected {
+		return "", ErrNotConnected
+	}
+
+	// shared reply streams are read by every instance and filtered by correlation id
+	shared := len(replyTo) != 0
+	if !shared {
+		replyTo = r.replyStream
+	}
+
+	if r.replyReaders[replyTo] {
+		return replyTo, nil
+	}
+
+	// read from the current last entry, replies published before are not ours
+	lastId := "0-0"
+	entries, err := client.XRevRangeN(r.replyCtx, replyTo, "+", "-", 1).Result()
+	if err != nil {
+		return "", err
+	}
+	if len(entries) > 0 {
+		lastId = entries[0].ID
+	}
+
+	r.replyWg.Add(1)
+	go func() {
+		defer r.replyWg.Done()
+		r.readReplies(client, replyTo, lastId, !shared)
+	}()
+
+	r.replyReaders[replyTo] = true
+	return replyTo, nil
+}
+
+func (r *rsBroker) readReplies(client redis.UniversalClient, stream string, lastId string, own bool) {
+	ctx := r.replyCtx
+	for ctx.Err() == nil {
+		streams, err := client.XRead(ctx, &redis.XReadArgs{
+			Streams: []string{stream, lastId},
+			Count:   DefaultBatchSize,
+			Block:   DefaultBlockTimeout,
+		}).Result()
+		if ctx.Err() != nil {
+			return
+		}
+		if err != nil {
+			if !errors.Is(err, redis.Nil) {
+				r.getLogger().Errorf(ctx, "[redis stream] failed to read replies of %s: %v", stream, err)
+				sleep(ctx, DefaultBlockTimeout)
+			}
+			continue
+		}
+
+		for _, s := range streams {
+			for _, entry := range s.Messages {
+				lastId = entry.ID
+
+				reply, err := unmarshal(entry.Values)
+				if err != nil {
+					r.getLogger().Errorf(ctx, "[redis stream] failed to unmarshal reply: %v", err)
+					continue
+				}
+				if msgChan, ok := r.resps.LoadAndDelete(reply.Headers[CorrelationIdHeader]); ok {
+					msgChan.(chan *broker.Message) <- reply
+				}
+			}
+
+			// nobody else reads the stream of this instance, keep it small
+			if own && len(s.Messages) > 0 {
+				ids := make([]string, 0, len(s.Messages))
+				for _, entry := range s.Messages {
+					ids = append(ids, entry.ID)
+				}
+				client.XDel(ctx, stream, ids...)
+			}
+		}
+	}
+}
+
+// Subscribe reads the topic stream with a consumer group. Subscribers of the same group share
+// the entries of the stream, without group every subscriber gets its own consumer group.
+func (r *rsBroker) Subscribe(topic string, handler broker.Handler, opts ...broker.SubscribeOption) (broker.Subscriber, error) {
+	opt := broker.SubscribeOptions{
+		AutoAck: true,
+	}
+
+	for _, o := range opts {
+		o(&opt)
+	}
+
+	r.mutex.Lock()
+	client, connected := r.client, r.connected
+	r.mutex.Unlock()
+	if !connected {
+		return nil, ErrNotConnected
+	}
+
+	sub := &subscriber{
+		r:        r,
+		t:        topic,
+		group:    opt.Group,
+		consumer: uuid.New().String(),
+		opts:     opt,
+		done:     make(chan struct{}),
+	}
+
+	if len(sub.group) == 0 {
+		sub.group = uuid.New().String()
+		sub.ephemeral = true
+	}
+
+	err := client.XGroupCreateMkStream(r.opts.Context, topic, sub.group, r.getStartId(opt)).Err()
+	if err != nil && !isBusyGroup(err) {
+		return nil, err
+	}
+
+	var ctx context.Context
+	ctx, sub.cancel = context.WithCancel(context.Background())
+
+	h := broker.ChainSubscriptionHandler(handler, opt, r.opts.SubscribeMiddlewares...)
+	go sub.run(ctx, h)
+
+	r.mutex.Lock()
+	r.subs = append(r.subs, sub)
+	r.mutex.Unlock()
+
+	r.getLogger().Infof(r.opts.Context, "Subcribed to topic: %s. Group: %s", topic, sub.group)
+
+	return sub, nil
+}
+
+// isBusyGroup reports whether the consumer group already exists
+func isBusyGroup(err error) bool {
+	return strings.HasPrefix(err.Error(), "BUSYGROUP")
+}
+
+func sleep(ctx context.Context, d time.Duration) {
+	select {
+	case <-ctx.Done():
+	case <-time.After(d):
+	}
+}
+
+func (r *rsBroker) getClient() (redis.UniversalClient, bool) {
+	if c, ok := r.opts.Context.Value(clientKey{}).(redis.UniversalClient); ok && c != nil {
+		return c, false
+	}
+
+	options := &redis.UniversalOptions{}
+	if o, ok := r.opts.Context.Value(universalOptionsKey{}).(*redis.UniversalOptions); ok && o != nil {
+		copied := *o
+		options = &copied
+	}
+	if len(options.Addrs) == 0 {
+		options.Addrs = r.addrs
+	}
+	if options.TLSConfig == nil {
+		options.TLSConfig = r.opts.TLSConfig
+	}
+	// blocking reads are interrupted on unsubscribe
+	options.ContextTimeoutEnabled = true
+
+	return redis.NewUniversalClient(options), true
+}
+
+func (r *rsBroker) getAddresses() []string {
+	var addrs []string
+	for _, addr := range r.opts.Addrs {
+		if len(addr) == 0 {
+			continue
+		}
+		addrs = append(addrs, addr)
+	}
+	if len(addrs) == 0 {
+		addrs = []string{DefaultRedisBroker}
+	}
+	return addrs
+}
+
+func (r *rsBroker) getMaxLen() int64 {
+	if l, ok := r.opts.Context.Value(maxLenKey{}).(int64); ok && l > 0 {
+		return l
+	}
+	return 0
+}
+
+func (r *rsBroker) getShutdownTimeout() time.Duration {
+	if t, ok := r.opts.Context.Value(shutdownTimeoutKey{}).(time.Duration); ok && t > 0 {
+		return t
+	}
+	return DefaultShutdownTimeout
+}
+
+func (r *rsBroker) getBatchSize(opt broker.SubscribeOptions) int64 {
+	if opt.Context != nil {
+		if s, ok := opt.Context.Value(batchSizeKey{}).(int64); ok && s > 0 {
+			return s
+		}
+	}
+	return DefaultBatchSize
+}
+
+func (r *rsBroker) getBlockTimeout(opt broker.SubscribeOptions) time.Duration {
+	if opt.Context != nil {
+		if t, ok := opt.Context.Value(blockTimeoutKey{}).(time.Duration); ok && t > 0 {
+			return t
+		}
+	}
+	return DefaultBlockTimeout
+}
+
+func (r *rsBroker) getClaimMinIdle(opt broker.SubscribeOptions) time.Duration {
+	if opt.Context != nil {
+		if t, ok := opt.Context.Value(claimMinIdleKey{}).(time.Duration); ok && t > 0 {
+			return t
+		}
+	}
+	return DefaultClaimMinIdle
+}
+
+func (r *rsBroker) getClaimInterval(opt broker.SubscribeOptions) time.Duration {
+	if opt.Context != nil {
+		if t, ok := opt.Context.Value(claimIntervalKey{}).(time.Duration); ok && t > 0 {
+			return t
+		}
+	}
+	return DefaultClaimInterval
+}
+
+func (r *rsBroker) getMaxDeliveries(opt broker.SubscribeOptions) int64 {
+	if opt.Context != nil {
+		if m, ok := opt.Context.Value(maxDeliveriesKey{}).(int64); ok && m != 0 {
+			return m
+		}
+	}
+	return DefaultMaxDeliveries
+}
+
+func (r *rsBroker) getDeadLetterStream(topic string, opt broker.SubscribeOptions) string {
+	if opt.Context != nil {
+		if s, ok := opt.Context.Value(deadLetterStreamKey{}).(string); ok && len(s) > 0 {
+			return s
+		}
+	}
+	return topic + DefaultDeadLetterSuffix
+}
+
+func (r *rsBroker) getStartId(opt broker.SubscribeOptions) string {
+	if opt.Context != nil {
+		if id, ok := opt.Context.Value(startIdKey{}).(string); ok && len(id) > 0 {
+			return id
+		}
+	}
+	return "$"
+}
+
+func (r *rsBroker) getLogger() logger.Logger {
+	logger := r.opts.Logger
+	if logger == nil {
+		logger = DefaultLogger
+	}
+	return logger
+}
+
+func (r *rsBroker) String() string {
+	return "redis stream broker implementation"
+}
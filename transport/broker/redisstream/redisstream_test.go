@@ -0,0 +1,191 @@
+package redisstream
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/lengocson131002/go-clean-core/transport/broker"
+	"github.com/redis/go-redis/v9"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func getRedisStreamBroker(t *testing.T) (broker.Broker, *miniredis.Miniredis) {
+	s := miniredis.RunT(t)
+
+	br := NewRedisStreamBroker(broker.WithBrokerAddresses(s.Addr()))
+	require.NoError(t, br.Connect())
+	t.Cleanup(func() {
+		br.Disconnect()
+	})
+	return br, s
+}
+
+func TestPublishSubscribe(t *testing.T) {
+	br, _ := getRedisStreamBroker(t)
+
+	received := make(chan *broker.Message, 1)
+	_, err := br.Subscribe("go.clean.test.redis", func(ctx context.Context, e broker.Event) error {
+		received <- e.Message()
+		return nil
+	}, BlockTimeout(50*time.Millisecond))
+	require.NoError(t, err)
+
+	err = br.Publish(context.Background(), "go.clean.test.redis", &broker.Message{
+		Headers: map[string]string{"key": "value"},
+		Body:    []byte("hello"),
+	})
+	require.NoError(t, err)
+
+	select {
+	case msg := <-received:
+		assert.Equal(t, "hello", string(msg.Body))
+		assert.Equal(t, "value", msg.Headers["key"])
+		assert.NotEmpty(t, msg.Headers[CorrelationIdHeader])
+	case <-time.After(5 * time.Second):
+		t.Fatal("message was not received")
+	}
+}
+
+func TestSubscribeGroupSharesEntries(t *testing.T) {
+	br, _ := getRedisStreamBroker(t)
+
+	var (
+		first, second atomic.Int32
+		wg            sync.WaitGroup
+		total         = 20
+	)
+	wg.Add(total)
+
+	for _, counter := range []*atomic.Int32{&first, &second} {
+		counter := counter
+		_, err := br.Subscribe("go.clean.test.redis.group", func(ctx context.Context, e broker.Event) error {
+			counter.Add(1)
+			wg.Done()
+			time.Sleep(5 * time.Millisecond)
+			return nil
+		}, broker.WithSubscribeGroup("workers"), BatchSize(1), BlockTimeout(50*time.Millisecond))
+		require.NoError(t, err)
+	}
+
+	for i := 0; i < total; i++ {
+		require.NoError(t, br.Publish(context.Background(), "go.clean.test.redis.group", &broker.Message{Body: []byte("job")}))
+	}
+
+	wg.Wait()
+	assert.Equal(t, int32(total), first.Load()+second.Load())
+	assert.NotZero(t, first.Load())
+	assert.NotZero(t, second.Load())
+}
+
+func TestReclaimPendingEntriesOfCrashedConsumer(t *testing.T) {
+	br, s := getRedisStreamBroker(t)
+	ctx := context.Background()
+
+	client := redis.NewClient(&redis.Options{Addr: s.Addr()})
+	defer client.Close()
+
+	// a consumer of the group reads the entry and crashes before acknowledging it
+	require.NoError(t, client.XGroupCreateMkStream(ctx, "go.clean.test.redis.claim", "workers", "$").Err())
+	require.NoError(t, br.Publish(ctx, "go.clean.test.redis.claim", &broker.Message{Body: []byte("orphan")}))
+	_, err := client.XReadGroup(ctx, &redis.XReadGroupArgs{
+		Group:    "workers",
+		Consumer: "crashed",
+		Streams:  []string{"go.clean.test.redis.claim", ">"},
+	}).Result()
+	require.NoError(t, err)
+
+	received := make(chan *broker.Message, 1)
+	_, err = br.Subscribe("go.clean.test.redis.claim", func(ctx context.Context, e broker.Event) error {
+		received <- e.Message()
+		return nil
+	}, broker.WithSubscribeGroup("workers"), ClaimMinIdle(time.Millisecond), ClaimInterval(10*time.Millisecond), BlockTimeout(20*time.Millisecond))
+	require.NoError(t, err)
+
+	select {
+	case msg := <-received:
+		assert.Equal(t, "orphan", string(msg.Body))
+	case <-time.After(5 * time.Second):
+		t.Fatal("pending entry was not reclaimed")
+	}
+
+	assert.Eventually(t, func() bool {
+		pending, err := client.XPending(ctx, "go.clean.test.redis.claim", "workers").Result()
+		return err == nil && pending.Count == 0
+	}, time.Second, 10*time.Millisecond)
+}
+
+func TestDeadLetterEntriesDeliveredTooManyTimes(t *testing.T) {
+	br, s := getRedisStreamBroker(t)
+	ctx := context.Background()
+
+	client := redis.NewClient(&redis.Options{Addr: s.Addr()})
+	defer client.Close()
+
+	var handled atomic.Int32
+	_, err := br.Subscribe("go.clean.test.redis.dlq", func(ctx context.Context, e broker.Event) error {
+		handled.Add(1)
+		return errors.New("failed")
+	}, broker.WithSubscribeGroup("workers"), MaxDeliveries(2), ClaimMinIdle(time.Millisecond), ClaimInterval(10*time.Millisecond), BlockTimeout(20*time.Millisecond))
+	require.NoError(t, err)
+
+	require.NoError(t, br.Publish(ctx, "go.clean.test.redis.dlq", &broker.Message{Body: []byte("poison")}))
+
+	var entries []redis.XMessage
+	require.Eventually(t, func() bool {
+		entries, err = client.XRange(ctx, "go.clean.test.redis.dlq"+DefaultDeadLetterSuffix, "-", "+").Result()
+		return err == nil && len(entries) == 1
+	}, 5*time.Second, 10*time.Millisecond)
+
+	msg, err := unmarshal(entries[0].Values)
+	require.NoError(t, err)
+	assert.Equal(t, "poison", string(msg.Body))
+	assert.Equal(t, "go.clean.test.redis.dlq", entries[0].Values[deadLetterStreamField])
+	assert.Equal(t, int32(2), handled.Load())
+
+	pending, err := client.XPending(ctx, "go.clean.test.redis.dlq", "workers").Result()
+	require.NoError(t, err)
+	assert.Zero(t, pending.Count)
+}
+
+func TestPublishAndReceive(t *testing.T) {
+	br, _ := getRedisStreamBroker(t)
+
+	_, err := br.Subscribe("go.clean.test.redis.request", func(ctx context.Context, e broker.Event) error {
+		msg := e.Message()
+		return br.Publish(ctx, msg.Headers[ReplyToHeader], &broker.Message{
+			Headers: map[string]string{CorrelationIdHeader: msg.Headers[CorrelationIdHeader]},
+			Body:    append([]byte("re: "), msg.Body...),
+		})
+	}, broker.WithSubscribeGroup("responders"), BlockTimeout(50*time.Millisecond))
+	require.NoError(t, err)
+
+	reply, err := br.PublishAndReceive(context.Background(), "go.clean.test.redis.request", &broker.Message{
+		Body: []byte("ping"),
+	}, broker.WithPublishTimeout(5*time.Second))
+	require.NoError(t, err)
+	assert.Equal(t, "re: ping", string(reply.Body))
+}
+
+func TestUnsubscribeDestroysEphemeralGroup(t *testing.T) {
+	br, s := getRedisStreamBroker(t)
+	ctx := context.Background()
+
+	sub, err := br.Subscribe("go.clean.test.redis.drain", func(ctx context.Context, e broker.Event) error {
+		return nil
+	}, BlockTimeout(20*time.Millisecond))
+	require.NoError(t, err)
+	require.NoError(t, sub.Unsubscribe())
+
+	client := redis.NewClient(&redis.Options{Addr: s.Addr()})
+	defer client.Close()
+
+	groups, err := client.XInfoGroups(ctx, "go.clean.test.redis.drain").Result()
+	require.NoError(t, err)
+	assert.Empty(t, groups)
+}
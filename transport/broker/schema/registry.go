@@ -0,0 +1,295 @@
+package schema
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strconv"
+	"sync"
+
+	"github.com/lengocson131002/go-clean-core/pipeline"
+	"github.com/lengocson131002/go-clean-core/transport/broker"
+)
+
+const (
+	// VersionHeader carries the schema version of the payload, messages without it are version 1
+	VersionHeader = "schemaVersion"
+
+	ContentTypeJSON = "application/json"
+)
+
+// Upcaster migrates a payload of a version to the next version
+type Upcaster func(payload []byte) ([]byte, error)
+
+type UnknownEventTypeError struct {
+	Type string
+}
+
+func (e UnknownEventTypeError) Error() string {
+	return fmt.Sprintf("Event type %q is not registered", e.Type)
+}
+
+type UnsupportedVersionError struct {
+	Type    string
+	Version int
+	Current int
+}
+
+func (e UnsupportedVersionError) Error() string {
+	return fmt.Sprintf("Event type %q version %d is not supported, current version is %d", e.Type, e.Version, e.Current)
+}
+
+type eventSchema struct {
+	eventType string
+	version   int
+	goType    reflect.Type
+	upcasters map[int]Upcaster
+	decode    func(payload []byte) (interface{}, error)
+	notify    func(ctx context.Context, event interface{}) error
+}
+
+// Registry maps event types and versions to Go types. Payloads of old versions are migrated to the
+// current version by the chain of upcasters on consume.
+type Registry struct {
+	mutex  sync.RWMutex
+	events map[string]*eventSchema
+	types  map[reflect.Type]*eventSchema
+}
+
+func NewRegistry() *Registry {
+	return &Registry{
+		events: make(map[string]*eventSchema),
+		types:  make(map[reflect.Type]*eventSchema),
+	}
+}
+
+// Register maps the event type to T at its current version. Consumed events are published to the
+// notification handlers of T registered in the pipeline.
+func Register[T any](r *Registry, eventType string, version int) error {
+	if version < 1 {
+		return fmt.Errorf("invalid version %d of event type %q: versions start at 1", version, eventType)
+	}
+
+	goType := reflect.TypeOf((*T)(nil)).Elem()
+
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	if _, ok := r.events[eventType]; ok {
+		return fmt.Errorf("event type %q is already registered", eventType)
+	}
+	if s, ok := r.types[goType]; ok {
+		return fmt.Errorf("type %s is already registered as event type %q", goType, s.eventType)
+	}
+
+	s := &eventSchema{
+		eventType: eventType,
+		version:   version,
+		goType:    goType,
+		upcasters: make(map[int]Upcaster),
+		decode: func(payload []byte) (interface{}, error) {
+			var event T
+			if err := json.Unmarshal(payload, &event); err != nil {
+				return nil, err
+			}
+			return event, nil
+		},
+		notify: func(ctx context.Context, event interface{}) error {
+			return pipeline.Publish[T](ctx, event.(T))
+		},
+	}
+
+	r.events[eventType] = s
+	r.types[goType] = s
+	return nil
+}
+
+// RegisterUpcaster registers the migration of the event type payload from a version to the next one
+func (r *Registry) RegisterUpcaster(eventType string, from int, upcaster Upcaster) error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	s, ok := r.events[eventType]
+	if !ok {
+		return UnknownEventTypeError{Type: eventType}
+	}
+
+	if from < 1 || from >= s.version {
+		return fmt.Errorf("invalid upcaster of event type %q from version %d, current version is %d", eventType, from, s.version)
+	}
+
+	if _, ok := s.upcasters[from]; ok {
+		return fmt.Errorf("upcaster of event type %q from version %d is already registered", eventType, from)
+	}
+
+	s.upcasters[from] = upcaster
+	return nil
+}
+
+func (r *Registry) lookup(eventType string) (*eventSchema, error) {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+
+	s, ok := r.events[eventType]
+	if !ok {
+		return nil, UnknownEventTypeError{Type: eventType}
+	}
+	return s, nil
+}
+
+// Upcast migrates the payload of the version to the current version of the event type
+func (r *Registry) Upcast(eventType string, version int, payload []byte) ([]byte, int, error) {
+	s, err := r.lookup(eventType)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	if version < 1 || version > s.version {
+		return nil, 0, UnsupportedVersionError{Type: eventType, Version: version, Current: s.version}
+	}
+
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+
+	for v := version; v < s.version; v++ {
+		upcaster, ok := s.upcasters[v]
+		if !ok {
+			return nil, 0, fmt.Errorf("missing upcaster of event type %q from version %d", eventType, v)
+		}
+
+		payload, err = upcaster(payload)
+		if err != nil {
+			return nil, 0, fmt.Errorf("failed to upcast event type %q from version %d: %w", eventType, v, err)
+		}
+	}
+
+	return payload, s.version, nil
+}
+
+// Encode marshals the event to a message carrying its event type and current version
+func (r *Registry) Encode(event interface{}) (*broker.Message, error) {
+	r.mutex.RLock()
+	s, ok := r.types[reflect.TypeOf(event)]
+	r.mutex.RUnlock()
+	if !ok {
+		return nil, fmt.Errorf("type %T is not registered", event)
+	}
+
+	payload, err := json.Marshal(event)
+	if err != nil {
+		return nil, err
+	}
+
+	m := &broker.Message{Body: payload}
+	m.SetEnvelope(broker.Envelope{
+		Type:        s.eventType,
+		ContentType: ContentTypeJSON,
+	})
+	m.SetHeader(VersionHeader, strconv.Itoa(s.version))
+	return m, nil
+}
+
+// Decode upcasts the payload of the message and unmarshals it to the registered type
+func (r *Registry) Decode(m *broker.Message) (interface{}, error) {
+	s, payload, err := r.upcastMessage(m)
+	if err != nil {
+		return nil, err
+	}
+	return s.decode(payload)
+}
+
+func (r *Registry) upcastMessage(m *broker.Message) (*eventSchema, []byte, error) {
+	eventType := m.Header(broker.MessageTypeHeader)
+	s, err := r.lookup(eventType)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	version, err := messageVersion(m)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	payload, _, err := r.Upcast(eventType, version, m.Body)
+	if err != nil {
+		return nil, nil, err
+	}
+	return s, payload, nil
+}
+
+// Publish encodes the event and publishes it to the topic
+func (r *Registry) Publish(ctx context.Context, b broker.Broker, topic string, event interface{}, opts ...broker.PublishOption) error {
+	m, err := r.Encode(event)
+	if err != nil {
+		return err
+	}
+	return b.Publish(ctx, topic, m, opts...)
+}
+
+// Handler decodes consumed events and publishes them to the pipeline notification handlers of their type
+func (r *Registry) Handler() broker.Handler {
+	return func(ctx context.Context, e broker.Event) error {
+		m := e.Message()
+		if m == nil {
+			return broker.EmptyMessageError{}
+		}
+
+		s, payload, err := r.upcastMessage(m)
+		if err != nil {
+			return err
+		}
+
+		event, err := s.decode(payload)
+		if err != nil {
+			return err
+		}
+
+		return s.notify(ctx, event)
+	}
+}
+
+// SubscribeMiddleware migrates the payload of consumed messages of registered event types to their current version,
+// messages of unknown types are passed as they are
+func (r *Registry) SubscribeMiddleware() broker.SubscribeMiddleware {
+	return func(next broker.Handler) broker.Handler {
+		return func(ctx context.Context, e broker.Event) error {
+			m := e.Message()
+			if m == nil {
+				return next(ctx, e)
+			}
+
+			eventType := m.Header(broker.MessageTypeHeader)
+			if _, err := r.lookup(eventType); err != nil {
+				return next(ctx, e)
+			}
+
+			version, err := messageVersion(m)
+			if err != nil {
+				return err
+			}
+
+			payload, current, err := r.Upcast(eventType, version, m.Body)
+			if err != nil {
+				return err
+			}
+
+			m.Body = payload
+			m.SetHeader(VersionHeader, strconv.Itoa(current))
+			return next(ctx, e)
+		}
+	}
+}
+
+func messageVersion(m *broker.Message) (int, error) {
+	v := m.Header(VersionHeader)
+	if len(v) == 0 {
+		return 1, nil
+	}
+
+	version, err := strconv.Atoi(v)
+	if err != nil {
+		return 0, fmt.Errorf("invalid schema version %q: %w", v, err)
+	}
+	return version, nil
+}
@@ -0,0 +1,155 @@
+package schema
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	"github.com/lengocson131002/go-clean-core/pipeline"
+	"github.com/lengocson131002/go-clean-core/transport/broker"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// OrderCreated v1 had a single "name" field, v2 split it into first and last name
+type OrderCreated struct {
+	OrderId   string `json:"orderId"`
+	FirstName string `json:"firstName"`
+	LastName  string `json:"lastName"`
+}
+
+type orderCreatedHandler struct {
+	received []OrderCreated
+}
+
+func (h *orderCreatedHandler) Handle(ctx context.Context, event OrderCreated) error {
+	h.received = append(h.received, event)
+	return nil
+}
+
+type testEvent struct {
+	message *broker.Message
+}
+
+func (e *testEvent) Topic() string            { return "orders" }
+func (e *testEvent) Message() *broker.Message { return e.message }
+func (e *testEvent) Ack() error               { return nil }
+func (e *testEvent) Error() error             { return nil }
+
+func splitName(payload []byte) ([]byte, error) {
+	var v1 struct {
+		OrderId string `json:"orderId"`
+		Name    string `json:"name"`
+	}
+	if err := json.Unmarshal(payload, &v1); err != nil {
+		return nil, err
+	}
+
+	first, last := v1.Name, ""
+	for i := range v1.Name {
+		if v1.Name[i] == ' ' {
+			first, last = v1.Name[:i], v1.Name[i+1:]
+			break
+		}
+	}
+
+	return json.Marshal(OrderCreated{OrderId: v1.OrderId, FirstName: first, LastName: last})
+}
+
+func newTestRegistry(t *testing.T) *Registry {
+	r := NewRegistry()
+	require.NoError(t, Register[OrderCreated](r, "order.created", 2))
+	require.NoError(t, r.RegisterUpcaster("order.created", 1, splitName))
+	return r
+}
+
+func TestRegister(t *testing.T) {
+	r := newTestRegistry(t)
+
+	assert.Error(t, Register[OrderCreated](r, "order.updated", 1), "type already registered")
+	assert.Error(t, Register[struct{}](r, "order.created", 1), "event type already registered")
+	assert.Error(t, Register[struct{}](r, "order.deleted", 0), "invalid version")
+
+	assert.Error(t, r.RegisterUpcaster("order.created", 1, splitName), "upcaster already registered")
+	assert.Error(t, r.RegisterUpcaster("order.created", 2, splitName), "no upcaster from the current version")
+	assert.ErrorAs(t, r.RegisterUpcaster("order.deleted", 1, splitName), &UnknownEventTypeError{})
+}
+
+func TestEncodeDecode(t *testing.T) {
+	r := newTestRegistry(t)
+
+	m, err := r.Encode(OrderCreated{OrderId: "1", FirstName: "John", LastName: "Doe"})
+	require.NoError(t, err)
+	assert.Equal(t, "order.created", m.Header(broker.MessageTypeHeader))
+	assert.Equal(t, "2", m.Header(VersionHeader))
+	assert.Equal(t, ContentTypeJSON, m.Header(broker.ContentTypeHeader))
+
+	event, err := r.Decode(m)
+	require.NoError(t, err)
+	assert.Equal(t, OrderCreated{OrderId: "1", FirstName: "John", LastName: "Doe"}, event)
+
+	_, err = r.Encode(struct{}{})
+	assert.Error(t, err)
+}
+
+func TestDecodeOldVersion(t *testing.T) {
+	r := newTestRegistry(t)
+
+	// messages without version header are version 1
+	m := &broker.Message{
+		Headers: map[string]string{broker.MessageTypeHeader: "order.created"},
+		Body:    []byte(`{"orderId":"1","name":"John Doe"}`),
+	}
+
+	event, err := r.Decode(m)
+	require.NoError(t, err)
+	assert.Equal(t, OrderCreated{OrderId: "1", FirstName: "John", LastName: "Doe"}, event)
+
+	m.SetHeader(VersionHeader, "3")
+	_, err = r.Decode(m)
+	assert.ErrorAs(t, err, &UnsupportedVersionError{})
+
+	m.SetHeader(broker.MessageTypeHeader, "order.deleted")
+	_, err = r.Decode(m)
+	assert.ErrorAs(t, err, &UnknownEventTypeError{})
+}
+
+func TestHandlerPublishesNotifications(t *testing.T) {
+	defer pipeline.ClearNotificationRegistrations()
+
+	handler := &orderCreatedHandler{}
+	require.NoError(t, pipeline.RegisterNotificationHandler[OrderCreated](handler))
+
+	r := newTestRegistry(t)
+	m := &broker.Message{
+		Headers: map[string]string{broker.MessageTypeHeader: "order.created", VersionHeader: "1"},
+		Body:    []byte(`{"orderId":"1","name":"John Doe"}`),
+	}
+
+	require.NoError(t, r.Handler()(context.Background(), &testEvent{message: m}))
+	require.Len(t, handler.received, 1)
+	assert.Equal(t, OrderCreated{OrderId: "1", FirstName: "John", LastName: "Doe"}, handler.received[0])
+}
+
+func TestSubscribeMiddleware(t *testing.T) {
+	r := newTestRegistry(t)
+
+	var received *broker.Message
+	handler := broker.ChainSubscribeMiddlewares(func(ctx context.Context, e broker.Event) error {
+		received = e.Message()
+		return nil
+	}, r.SubscribeMiddleware())
+
+	m := &broker.Message{
+		Headers: map[string]string{broker.MessageTypeHeader: "order.created"},
+		Body:    []byte(`{"orderId":"1","name":"John Doe"}`),
+	}
+	require.NoError(t, handler(context.Background(), &testEvent{message: m}))
+	assert.Equal(t, "2", received.Header(VersionHeader))
+	assert.JSONEq(t, `{"orderId":"1","firstName":"John","lastName":"Doe"}`, string(received.Body))
+
+	// unknown event types are passed as they are
+	other := &broker.Message{Body: []byte("raw")}
+	require.NoError(t, handler(context.Background(), &testEvent{message: other}))
+	assert.Equal(t, "raw", string(received.Body))
+}
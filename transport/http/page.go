@@ -0,0 +1,55 @@
+package http
+
+import "github.com/lengocson131002/go-clean-core/pagination"
+
+type Pagination struct {
+	Page       int    `json:"page,omitempty"`
+	Size       int    `json:"size"`
+	Total      *int64 `json:"total,omitempty"`
+	TotalPages *int64 `json:"totalPages,omitempty"`
+	HasNext    bool   `json:"hasNext"`
+	NextCursor string `json:"nextCursor,omitempty"`
+}
+
+type PageData[T any] struct {
+	Items      []T        `json:"items"`
+	Pagination Pagination `json:"pagination"`
+}
+
+// PageResponse returns the success response of a page of the offset pagination
+func PageResponse[T any](page pagination.Page[T]) Response[PageData[T]] {
+	pagination := Pagination{
+		Page:    page.Page,
+		Size:    page.Size,
+		Total:   page.Total,
+		HasNext: page.HasNext,
+	}
+	if pages, ok := page.TotalPages(); ok {
+		pagination.TotalPages = &pages
+	}
+
+	return SuccessResponse(PageData[T]{
+		Items:      items(page.Items),
+		Pagination: pagination,
+	})
+}
+
+// CursorPageResponse returns the success response of a page of the keyset pagination
+func CursorPageResponse[T any](page pagination.CursorPage[T]) Response[PageData[T]] {
+	return SuccessResponse(PageData[T]{
+		Items: items(page.Items),
+		Pagination: Pagination{
+			Size:       page.Size,
+			HasNext:    page.HasNext,
+			NextCursor: page.NextCursor,
+		},
+	})
+}
+
+// items returns an empty slice instead of nil, so the items are an empty JSON array
+func items[T any](items []T) []T {
+	if items == nil {
+		return []T{}
+	}
+	return items
+}